@@ -1,44 +1,186 @@
-package main
-
-import (
-	"log"
-	"os"
-	"path"
-	"sort"
-)
-
-func gitAdd(files []string) {
-	indexEntries := make([]indexEntry, 0)
-	filemap := make(map[string]bool)
-	for _, filename := range files {
-		filemap[filename] = true
-	}
-	indexes := readIndex()
-	for _, index := range indexes {
-		if _, ok := filemap[index.path]; !ok {
-			indexEntries = append(indexEntries, index)
-		}
-	}
-	for _, filename := range files {
-
-		// create a file
-		filename = path.Join(filename)
-		file, err := os.Open(filename)
-		if err != nil {
-			log.Fatalf("Failed to open file %s: %v", filename, err)
-		}
-		defer file.Close()
-
-		sz := getFileSize(file)
-		fileHash := hashObject(file, "blob", sz)
-		file.Seek(0, 0)
-		writeToObjectFile(file, fileHash, "blob", sz)
-
-		indexEntry := createIndexEntry(filename, fileHash)
-
-		indexEntries = append(indexEntries, indexEntry)
-
-	}
-	sort.Slice(indexEntries, func(i, j int) bool { return indexEntries[i].path < indexEntries[j].path })
-	writeToIndexFile(indexEntries)
-}
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/tamimehsan/gogit/repo"
+)
+
+func runAdd(args []string) {
+	addCmd := flag.NewFlagSet("add", flag.ExitOnError)
+	patch := addCmd.Bool("p", false, "Choose the hunks of the changes to stage")
+	addCmd.BoolVar(patch, "patch", false, "Choose the hunks of the changes to stage")
+	hunkFile := addCmd.String("hunks", "", "Stage the hunks this file selects instead of asking, - for stdin")
+	args = parseArgs(addCmd, args)
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+
+	r := openRepo()
+	files := make([]string, 0, len(args))
+	for _, file := range args {
+		rel, err := r.Rel(file)
+		check(err)
+		files = append(files, rel)
+	}
+	if !*patch && *hunkFile == "" {
+		check(r.Add(files))
+		return
+	}
+
+	patches, err := r.UnstagedPatches(files)
+	check(err)
+	if len(patches) == 0 {
+		fmt.Println("No changes.")
+		return
+	}
+	if *hunkFile != "" {
+		data, err := readInput(*hunkFile)
+		check(err)
+		selections, err := parseHunkSelections(r, string(data))
+		check(err)
+		for _, patch := range patches {
+			selected, err := selectHunks(selections[patch.Path], len(patch.Hunks))
+			if err != nil {
+				check(fmt.Errorf("%s: %w", patch.Path, err))
+			}
+			check(r.StageHunks(patch, selected))
+		}
+		return
+	}
+	stageHunksInteractively(r, patches, bufio.NewReader(os.Stdin))
+}
+
+// parseHunkSelections reads a hunk selection file. Each line names a path,
+// relative to the current directory, and after its last colon the hunks of
+// it to stage, counted from 1 in diff order: a comma separated list of
+// numbers and ranges like 2-4, or * for all. Files without a line are not
+// staged. Empty lines and lines starting with # are ignored.
+func parseHunkSelections(r *repo.Repository, data string) (map[string]string, error) {
+	selections := map[string]string{}
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ':')
+		if i < 0 {
+			return nil, fmt.Errorf("invalid hunk selection '%s', expected <path>:<hunks>", line)
+		}
+		rel, err := r.Rel(line[:i])
+		if err != nil {
+			return nil, err
+		}
+		selections[rel] = line[i+1:]
+	}
+	return selections, nil
+}
+
+// selectHunks returns the sorted positions, from 0, of the hunks a
+// selection like "1,3-4" or "*" picks out of count.
+func selectHunks(selection string, count int) ([]int, error) {
+	picked := make([]bool, count)
+	for _, part := range strings.Split(selection, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if part == "*" {
+			for i := range picked {
+				picked[i] = true
+			}
+			continue
+		}
+		first, last, isRange := strings.Cut(part, "-")
+		from, err := strconv.Atoi(first)
+		to := from
+		if err == nil && isRange {
+			to, err = strconv.Atoi(last)
+		}
+		if err != nil || from < 1 || to < from {
+			return nil, fmt.Errorf("invalid hunk selection '%s'", part)
+		}
+		if to > count {
+			return nil, fmt.Errorf("there is no hunk %d, only %d", to, count)
+		}
+		for i := from; i <= to; i++ {
+			picked[i-1] = true
+		}
+	}
+	selected := []int{}
+	for i, pick := range picked {
+		if pick {
+			selected = append(selected, i)
+		}
+	}
+	return selected, nil
+}
+
+const addPatchHelp = `y - stage this hunk
+n - do not stage this hunk
+q - quit; do not stage this hunk or any of the remaining ones
+a - stage this hunk and all later hunks in the file
+d - do not stage this hunk or any of the later hunks in the file
+? - print help
+`
+
+// stageHunksInteractively shows the hunks of every patch and asks whether
+// to stage each, as git add -p does. Answers are read from input, so they
+// can also be piped in.
+func stageHunksInteractively(r *repo.Repository, patches []repo.FilePatch, input *bufio.Reader) {
+	for _, patch := range patches {
+		fmt.Printf("diff --git a/%s b/%s\n", patch.Path, patch.Path)
+		fmt.Printf("index %s..%s %06o\n", patch.Staged[:7], patch.Worktree[:7], patch.Mode)
+		fmt.Printf("--- a/%s\n+++ b/%s\n", patch.Path, patch.Path)
+		selected := []int{}
+		quit := false
+	hunks:
+		for i := 0; i < len(patch.Hunks); i++ {
+			fmt.Print(patch.Hunks[i])
+			for {
+				fmt.Printf("(%d/%d) Stage this hunk [y,n,q,a,d,?]? ", i+1, len(patch.Hunks))
+				answer, err := input.ReadString('\n')
+				if err != nil && !errors.Is(err, io.EOF) {
+					check(err)
+				}
+				answer = strings.TrimSpace(answer)
+				if answer == "" && errors.Is(err, io.EOF) {
+					// no more answers, as if the user quit
+					fmt.Println()
+					quit = true
+					break hunks
+				}
+				switch answer {
+				case "y":
+					selected = append(selected, i)
+				case "n":
+				case "q":
+					quit = true
+					break hunks
+				case "a":
+					for ; i < len(patch.Hunks); i++ {
+						selected = append(selected, i)
+					}
+					break hunks
+				case "d":
+					break hunks
+				default:
+					fmt.Print(addPatchHelp)
+					continue
+				}
+				break
+			}
+		}
+		check(r.StageHunks(patch, selected))
+		if quit {
+			return
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/tamimehsan/gogit/repo"
+)
+
+// defaultBatchFormat is what --batch and --batch-check print for each
+// object when no format is given.
+const defaultBatchFormat = "%(objectname) %(objecttype) %(objectsize)"
+
+const catFileUsage = "usage: gogit cat-file (-t | -s | -e | -p | <type>) <object>\n" +
+	"   or: gogit cat-file (--batch | --batch-check)[=<format>] [--buffer]"
+
+// runCatFile shows objects. Options are parsed by hand because --batch and
+// --batch-check take an optional format.
+func runCatFile(args []string) {
+	mode, format, buffer := "", "", false
+	batch, checkOnly := false, false
+	positional := []string{}
+	for _, arg := range args {
+		switch {
+		case arg == "-t" || arg == "-s" || arg == "-e" || arg == "-p":
+			mode = arg
+		case arg == "--batch" || strings.HasPrefix(arg, "--batch="):
+			batch, checkOnly = true, false
+			format = strings.TrimPrefix(strings.TrimPrefix(arg, "--batch"), "=")
+		case arg == "--batch-check" || strings.HasPrefix(arg, "--batch-check="):
+			batch, checkOnly = true, true
+			format = strings.TrimPrefix(strings.TrimPrefix(arg, "--batch-check"), "=")
+		case arg == "--buffer":
+			buffer = true
+		case strings.HasPrefix(arg, "-"):
+			usageError(catFileUsage)
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	r := openRepo()
+	if batch {
+		if mode != "" || len(positional) > 0 {
+			usageError(catFileUsage)
+		}
+		if format == "" {
+			format = defaultBatchFormat
+		}
+		catFileBatch(r, format, !checkOnly, buffer)
+		return
+	}
+
+	switch {
+	case mode == "" && len(positional) == 2:
+		objectType := positional[0]
+		// peel the resolved name, a suffix would end up in the path of
+		// rev:path arguments
+		object, err := r.ResolveRevision(positional[1])
+		check(err)
+		object, err = r.ResolveRevision(object + "^{" + objectType + "}")
+		check(err)
+		_, data, err := r.ReadObject(object)
+		check(err)
+		_, err = os.Stdout.Write(data)
+		check(err)
+		return
+	case mode == "" || len(positional) != 1:
+		usageError(catFileUsage)
+	}
+
+	object, err := r.ResolveRevision(positional[0])
+	if mode == "-e" {
+		if err != nil || !r.HasObject(object) {
+			os.Exit(1)
+		}
+		return
+	}
+	if err != nil {
+		check(fmt.Errorf("not a valid object name %s", positional[0]))
+	}
+	switch mode {
+	case "-t", "-s":
+		objectType, size, err := r.ReadObjectInfo(object)
+		check(err)
+		if mode == "-t" {
+			fmt.Println(objectType)
+		} else {
+			fmt.Println(size)
+		}
+	case "-p":
+		check(r.CatFile(object, os.Stdout))
+	}
+}
+
+// catFileBatch reads object names from stdin, one per line, and prints
+// format for each, followed by the contents of the object when contents is
+// set. Unless buffer is set the output is flushed after every object so
+// the other end of a pipe can answer as it goes.
+func catFileBatch(r *repo.Repository, format string, contents, buffer bool) {
+	splitRest := strings.Contains(format, "%(rest)")
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	input := bufio.NewScanner(os.Stdin)
+	input.Buffer(make([]byte, 64*1024), 1024*1024)
+	for input.Scan() {
+		name, rest := input.Text(), ""
+		if splitRest {
+			if i := strings.IndexAny(name, " \t"); i >= 0 {
+				name, rest = name[:i], strings.TrimLeft(name[i:], " \t")
+			}
+		}
+		// like git, names that resolve to nothing are reported, not fatal
+		object, err := r.ResolveRevision(name)
+		var objectType string
+		var size int
+		if err == nil {
+			objectType, size, err = r.ReadObjectInfo(object)
+		} else if !errors.Is(err, repo.ErrAmbiguousRevision) {
+			err = repo.ErrObjectNotFound
+		}
+		switch {
+		case errors.Is(err, repo.ErrAmbiguousRevision):
+			fmt.Fprintf(out, "%s ambiguous\n", name)
+		case errors.Is(err, repo.ErrObjectNotFound):
+			fmt.Fprintf(out, "%s missing\n", name)
+		case err != nil:
+			out.Flush()
+			check(err)
+		default:
+			line, err := expandBatchFormat(format, object, objectType, size, rest)
+			check(err)
+			out.WriteString(line + "\n")
+			if contents {
+				_, data, err := r.ReadObject(object)
+				check(err)
+				out.Write(data)
+				out.WriteString("\n")
+			}
+		}
+		if !buffer {
+			check(out.Flush())
+		}
+	}
+	check(input.Err())
+}
+
+// expandBatchFormat replaces the %(atom) placeholders of format.
+func expandBatchFormat(format, object, objectType string, size int, rest string) (string, error) {
+	var expanded strings.Builder
+	for {
+		start := strings.Index(format, "%(")
+		if start < 0 {
+			expanded.WriteString(format)
+			return expanded.String(), nil
+		}
+		end := strings.IndexByte(format[start:], ')')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated format element: %s", format[start:])
+		}
+		expanded.WriteString(format[:start])
+		switch atom := format[start+2 : start+end]; atom {
+		case "objectname":
+			expanded.WriteString(object)
+		case "objecttype":
+			expanded.WriteString(objectType)
+		case "objectsize":
+			expanded.WriteString(strconv.Itoa(size))
+		case "rest":
+			expanded.WriteString(rest)
+		default:
+			return "", fmt.Errorf("unknown format element: %%(%s)", atom)
+		}
+		format = format[start+end+1:]
+	}
+}
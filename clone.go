@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/tamimehsan/gogit/repo"
+)
+
+func runClone(args []string) {
+	cloneCmd := flag.NewFlagSet("clone", flag.ExitOnError)
+	userName := cloneCmd.String("u", "", "The username for the remote repository")
+	opts := repo.CloneOptions{}
+	cloneCmd.BoolVar(&opts.Bare, "bare", false, "Create a bare repository")
+	cloneCmd.StringVar(&opts.Branch, "branch", "", "Check out this branch instead of the remote HEAD")
+	cloneCmd.StringVar(&opts.Branch, "b", "", "Shorthand for --branch")
+	cloneCmd.StringVar(&opts.Origin, "origin", "", "Name the remote instead of origin")
+	cloneCmd.StringVar(&opts.Origin, "o", "", "Shorthand for --origin")
+	args = parseArgs(cloneCmd, args)
+
+	if len(args) < 1 || len(args) > 2 {
+		usageError("usage: gogit clone [--bare] [-b <branch>] [-o <name>] <repository> [<directory>]")
+	}
+	opts.URL = args[0]
+	if len(args) == 2 {
+		opts.Directory = args[1]
+	}
+	opts.UserName = *userName
+	opts.Progress = os.Stderr
+
+	directory := opts.Directory
+	if directory == "" {
+		directory = repo.CloneDirectory(opts.URL, opts.Bare)
+	}
+	if opts.Bare {
+		fmt.Fprintf(os.Stderr, "Cloning into bare repository '%s'...\n", directory)
+	} else {
+		fmt.Fprintf(os.Stderr, "Cloning into '%s'...\n", directory)
+	}
+
+	r, err := repo.Clone(opts)
+	check(err)
+	if _, err := r.ReadRef("HEAD"); err != nil {
+		fmt.Fprintln(os.Stderr, "warning: You appear to have cloned an empty repository.")
+	}
+}
@@ -1,69 +1,197 @@
-package main
-
-import (
-	"fmt"
-	"log"
-	"os"
-	"path"
-	"strings"
-	"time"
-)
-
-func createCommit(msg string) {
-
-	now := time.Now()
-	timestamp := now.Unix()
-	timezone := now.Format("-0700")
-
-	treeHash := createTree()
-	commitContent := ""
-	commitContent += fmt.Sprintf("tree %s\n", treeHash)
-	currentCommit := getLocalMasterCommit()
-	if currentCommit != "" {
-		commitContent += fmt.Sprintf("parent %s\n", currentCommit)
-	}
-
-	name := getUserName()
-	email := getEmail()
-
-	commitContent += fmt.Sprintf("author %s <%s> %d %s\n", name, email, timestamp, timezone)
-	commitContent += fmt.Sprintf("committer %s <%s> %d %s\n", name, email, timestamp, timezone)
-	commitContent += "\n"
-	commitContent += msg
-	commitContent += "\n"
-
-	hash := hashObject(strings.NewReader(commitContent), "commit", len(commitContent))
-	writeToObjectFile(strings.NewReader(commitContent), hash, "commit", len(commitContent))
-	writeCommit(hash)
-}
-
-func getLocalMasterCommit() string {
-	file, err := os.Open(path.Join(".git", "refs", "heads", "master"))
-	if err != nil {
-		// return 0000... if the file does not exist
-		return ""
-	}
-	defer file.Close()
-	hash := make([]byte, 40)
-	file.Read(hash)
-	return string(hash)
-}
-
-func getRemoteMasterCommit(url, userName, password string) string {
-	url = url + "/info/refs?service=git-receive-pack"
-
-	lines := gitGetPack(url, userName, password)
-
-	hash := lines[1][8:48]
-	return hash
-}
-
-func writeCommit(commit string) {
-	file, err := os.OpenFile(path.Join(".git", "refs", "heads", "master"), os.O_RDWR|os.O_CREATE, 0644)
-	if err != nil {
-		log.Fatalf("Failed to open commit file: %v", err)
-	}
-	defer file.Close()
-	file.Truncate(0)
-	file.Write([]byte(commit))
-}
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tamimehsan/gogit/repo"
+)
+
+const commitUsage = "usage: gogit commit [-a] [--amend] [--allow-empty] [-m <msg>... | -F <file>] [--author=<author>] [--date=<date>]"
+
+// commitTemplate is appended to the message edited in the editor.
+const commitTemplate = "\n# Please enter the commit message for your changes. Lines starting\n" +
+	"# with '#' will be ignored, and an empty message aborts the commit.\n"
+
+func runCommit(args []string) {
+	commitCmd := flag.NewFlagSet("commit", flag.ExitOnError)
+	opts := repo.CommitOptions{}
+	var messages stringsFlag
+	commitCmd.Var(&messages, "m", "The commit message, each one a paragraph")
+	messageFile := commitCmd.String("F", "", "Read the commit message from a file, - for stdin")
+	commitCmd.BoolVar(&opts.All, "a", false, "Stage the changes of all tracked files first")
+	commitCmd.BoolVar(&opts.All, "all", false, "Stage the changes of all tracked files first")
+	commitCmd.BoolVar(&opts.Amend, "amend", false, "Replace the last commit")
+	commitCmd.StringVar(&opts.Author, "author", "", "Override the author, as 'Name <email>'")
+	date := commitCmd.String("date", "", "Override the author date")
+	commitCmd.BoolVar(&opts.AllowEmpty, "allow-empty", false, "Record a commit with the same tree as its parent")
+	args = parseArgs(commitCmd, args)
+	if len(args) > 0 {
+		usageError(commitUsage)
+	}
+	if len(messages) > 0 && *messageFile != "" {
+		check(errors.New("option -m cannot be combined with -F"))
+	}
+
+	r := openRepo()
+	if *date != "" {
+		when, err := parseCommitDate(*date)
+		check(err)
+		opts.Date = when
+	}
+	switch {
+	case len(messages) > 0:
+		opts.Message = cleanupMessage(strings.Join(messages, "\n\n"), false)
+	case *messageFile != "":
+		data, err := readInput(*messageFile)
+		check(err)
+		opts.Message = cleanupMessage(string(data), false)
+	default:
+		initial := ""
+		if opts.Amend {
+			if head, err := r.ResolveRevision("HEAD"); err == nil {
+				commit, err := r.ReadCommit(head)
+				check(err)
+				initial = commit.Message
+			}
+		}
+		message, err := editMessage(r, initial)
+		check(err)
+		opts.Message = cleanupMessage(message, true)
+	}
+
+	hash, err := r.Commit(opts)
+	switch {
+	case errors.Is(err, repo.ErrEmptyMessage):
+		check(errors.New("aborting commit due to empty commit message"))
+	case errors.Is(err, repo.ErrNothingToCommit) && opts.Amend:
+		check(errors.New("amending the most recent commit would make it empty, use --allow-empty to amend it anyway"))
+	case errors.Is(err, repo.ErrNothingToCommit):
+		fmt.Println("nothing to commit, use --allow-empty to record a commit anyway")
+		os.Exit(1)
+	}
+	check(err)
+	printCommitSummary(r, hash)
+}
+
+// printCommitSummary prints the line git shows after committing, like
+// "[main (root-commit) 1a2b3c4] Subject".
+func printCommitSummary(r *repo.Repository, hash string) {
+	commit, err := r.ReadCommit(hash)
+	check(err)
+	branch, err := r.HeadBranch()
+	check(err)
+	where := "detached HEAD"
+	if branch != "" {
+		where = repo.ShortRefName(branch)
+	}
+	if len(commit.Parents) == 0 {
+		where += " (root-commit)"
+	}
+	short, err := r.AbbreviateHash(hash, 7)
+	check(err)
+	subject, _, _ := strings.Cut(commit.Message, "\n")
+	fmt.Printf("[%s %s] %s\n", where, short, subject)
+}
+
+// editMessage lets the user write a message in their editor, starting
+// from initial, and returns what they saved.
+func editMessage(r *repo.Repository, initial string) (string, error) {
+	editor := os.Getenv("GIT_EDITOR")
+	if editor == "" {
+		config, err := r.Config()
+		if err != nil {
+			return "", err
+		}
+		editor, _ = config.Get("core.editor")
+	}
+	for _, variable := range []string{"VISUAL", "EDITOR"} {
+		if editor == "" {
+			editor = os.Getenv(variable)
+		}
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	file := filepath.Join(r.GitDir(), "COMMIT_EDITMSG")
+	if err := os.WriteFile(file, []byte(initial+commitTemplate), 0644); err != nil {
+		return "", err
+	}
+	// like git, the editor is a shell command that gets the file appended
+	cmd := exec.Command("sh", "-c", editor+` "$@"`, editor, file)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("there was a problem with the editor '%s': %w", editor, err)
+	}
+	data, err := os.ReadFile(file)
+	return string(data), err
+}
+
+// cleanupMessage tidies a commit message as git does: trailing whitespace
+// is removed from every line, runs of empty lines are collapsed and empty
+// lines at the start and end are dropped. Lines starting with # are
+// dropped too if stripComments is set.
+func cleanupMessage(message string, stripComments bool) string {
+	lines := []string{}
+	empty := false
+	for _, line := range strings.Split(message, "\n") {
+		if stripComments && strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			empty = len(lines) > 0
+			continue
+		}
+		if empty {
+			lines = append(lines, "")
+			empty = false
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// parseCommitDate reads the dates --date takes: git's internal format
+// "<seconds> <offset>", optionally with a leading @, RFC 2822 and ISO 8601
+// dates, and the relative dates reflog expire takes.
+func parseCommitDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	seconds, offset, hasOffset := strings.Cut(strings.TrimPrefix(value, "@"), " ")
+	if n, err := strconv.ParseInt(seconds, 10, 64); err == nil {
+		if !hasOffset {
+			return time.Unix(n, 0), nil
+		}
+		if zone, err := time.Parse("-0700", offset); err == nil {
+			return time.Unix(n, 0).In(zone.Location()), nil
+		}
+	}
+	for _, layout := range []string{
+		time.RFC1123Z,
+		"Mon Jan 2 15:04:05 2006 -0700",
+		time.RFC3339,
+		"2006-01-02 15:04:05 -0700",
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04:05",
+		"2006-01-02",
+	} {
+		if when, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return when, nil
+		}
+	}
+	if when, err := parseExpiry(value, time.Now()); err == nil && !when.IsZero() {
+		return when, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date format: %s", value)
+}
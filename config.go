@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/tamimehsan/gogit/repo"
+)
+
+func runConfig(args []string) {
+	configCmd := flag.NewFlagSet("config", flag.ExitOnError)
+	global := configCmd.Bool("global", false, "Use the global config file")
+	system := configCmd.Bool("system", false, "Use the system config file")
+	local := configCmd.Bool("local", false, "Use the repository config file")
+	file := configCmd.String("file", "", "Use the given config file")
+	get := configCmd.Bool("get", false, "Get the value of a key")
+	getAll := configCmd.Bool("get-all", false, "Get all values of a multi-valued key")
+	set := configCmd.Bool("set", false, "Set the value of a key")
+	add := configCmd.Bool("add", false, "Add a value to a key without replacing existing ones")
+	unset := configCmd.Bool("unset", false, "Remove a key")
+	unsetAll := configCmd.Bool("unset-all", false, "Remove all values of a key")
+	list := configCmd.Bool("list", false, "List all variables")
+	showOrigin := configCmd.Bool("show-origin", false, "Show the file each variable comes from")
+	configCmd.BoolVar(list, "l", false, "Shorthand for --list")
+	configCmd.Parse(args)
+	args = configCmd.Args()
+
+	gitDir := ""
+	if r, err := repo.Discover("."); err == nil {
+		gitDir = r.GitDir()
+	}
+
+	scopeGiven := *global || *system || *local || *file != ""
+	openFile := func() *repo.ConfigFile {
+		var configFile *repo.ConfigFile
+		var err error
+		switch {
+		case *file != "":
+			configFile, err = repo.ReadConfigFile(*file)
+		case *global:
+			configFile, err = repo.OpenConfigFile(repo.ScopeGlobal, gitDir)
+		case *system:
+			configFile, err = repo.OpenConfigFile(repo.ScopeSystem, gitDir)
+		default:
+			configFile, err = repo.OpenConfigFile(repo.ScopeLocal, gitDir)
+		}
+		check(err)
+		return configFile
+	}
+	// readEntries returns the entries to query: one file if a scope was
+	// given, otherwise the merged configuration.
+	readEntries := func() []repo.ConfigEntry {
+		if scopeGiven {
+			return openFile().Entries()
+		}
+		config, err := repo.ReadConfig(gitDir)
+		check(err)
+		return config.Entries()
+	}
+
+	switch {
+	case *list:
+		for _, entry := range readEntries() {
+			if *showOrigin {
+				fmt.Printf("file:%s\t", entry.Origin)
+			}
+			fmt.Printf("%s=%s\n", entry.Name(), entry.Value)
+		}
+	case *get || *getAll || (len(args) == 1 && !*set && !*add && !*unset && !*unsetAll):
+		if len(args) != 1 {
+			usageError("usage: gogit config --get <name>")
+		}
+		var values []string
+		if scopeGiven {
+			values = openFile().GetAll(args[0])
+		} else {
+			config, err := repo.ReadConfig(gitDir)
+			check(err)
+			values = config.GetAll(args[0])
+		}
+		if len(values) == 0 {
+			os.Exit(1)
+		}
+		if !*getAll {
+			values = values[len(values)-1:]
+		}
+		for _, value := range values {
+			fmt.Println(value)
+		}
+	case *set || *add || len(args) == 2:
+		if len(args) != 2 {
+			usageError("usage: gogit config --set <name> <value>")
+		}
+		configFile := openFile()
+		if *add {
+			check(configFile.Add(args[0], args[1]))
+		} else {
+			checkMultiple(configFile.Set(args[0], args[1]), args[0])
+		}
+		check(configFile.Save())
+	case *unset || *unsetAll:
+		if len(args) != 1 {
+			usageError("usage: gogit config --unset <name>")
+		}
+		configFile := openFile()
+		if len(configFile.GetAll(args[0])) == 0 {
+			os.Exit(5)
+		}
+		if *unsetAll {
+			_, err := configFile.UnsetAll(args[0])
+			check(err)
+		} else {
+			checkMultiple(configFile.Unset(args[0]), args[0])
+		}
+		check(configFile.Save())
+	default:
+		usageError("usage: gogit config [--global|--system|--local|--file <file>] [--get|--get-all|--set|--add|--unset|--unset-all|--list] [<name> [<value>]]")
+	}
+}
+
+// checkMultiple exits with the status git uses when a single value was
+// expected for a multi-valued key, and otherwise behaves like check.
+func checkMultiple(err error, key string) {
+	if errors.Is(err, repo.ErrMultipleValues) {
+		fmt.Fprintf(os.Stderr, "warning: %s has multiple values\n", key)
+		os.Exit(5)
+	}
+	check(err)
+}
+
+// usageError prints usage and exits with the status git uses for bad usage.
+func usageError(usage string) {
+	fmt.Fprintln(os.Stderr, usage)
+	os.Exit(129)
+}
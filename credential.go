@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/tamimehsan/gogit/repo"
+)
+
+// runCredential implements the credential command, which lets scripts use
+// the configured credential helpers: fill prints the credential completed
+// from the one read on stdin, approve and reject pass it on to the helpers.
+func runCredential(args []string) {
+	if len(args) != 1 {
+		usageError("usage: gogit credential (fill|approve|reject)")
+	}
+	gitDir := ""
+	if r, err := repo.Discover("."); err == nil {
+		gitDir = r.GitDir()
+	}
+	config, err := repo.ReadConfig(gitDir)
+	check(err)
+	credential, err := repo.ParseCredential(os.Stdin)
+	check(err)
+
+	switch args[0] {
+	case "fill":
+		check(repo.FillCredential(config, credential))
+		check(credential.Encode(os.Stdout))
+	case "approve":
+		repo.ApproveCredential(config, credential)
+	case "reject":
+		repo.RejectCredential(config, credential)
+	default:
+		usageError("usage: gogit credential (fill|approve|reject)")
+	}
+}
+
+// runCredentialStore runs the store helper by hand, or from git through
+// credential.helper = "!gogit credential-store".
+func runCredentialStore(args []string) {
+	storeCmd := flag.NewFlagSet("credential-store", flag.ExitOnError)
+	store := &repo.CredentialStore{}
+	storeCmd.StringVar(&store.File, "file", "", "The file credentials are kept in")
+	args = parseArgs(storeCmd, args)
+	if len(args) != 1 {
+		usageError("usage: gogit credential-store [--file <path>] (get|store|erase)")
+	}
+	runHelperAction(store, args[0])
+}
+
+// runCredentialCache runs the cache helper by hand, or from git through
+// credential.helper = "!gogit credential-cache".
+func runCredentialCache(args []string) {
+	cacheCmd := flag.NewFlagSet("credential-cache", flag.ExitOnError)
+	cache := &repo.CredentialCache{}
+	cacheCmd.IntVar(&cache.Timeout, "timeout", 0, "How many seconds credentials are kept")
+	cacheCmd.StringVar(&cache.Socket, "socket", "", "The socket of the cache daemon")
+	args = parseArgs(cacheCmd, args)
+	if len(args) != 1 {
+		usageError("usage: gogit credential-cache [--timeout <seconds>] [--socket <path>] (get|store|erase|exit)")
+	}
+	if args[0] == "exit" {
+		cache.Exit()
+		return
+	}
+	runHelperAction(cache, args[0])
+}
+
+// runCredentialCacheDaemon is started by the cache helper to hold the
+// credentials.
+func runCredentialCacheDaemon(args []string) {
+	if len(args) != 1 {
+		usageError("usage: gogit credential-cache--daemon <socket>")
+	}
+	check(repo.ServeCredentialCache(args[0]))
+}
+
+// runHelperAction runs a helper action on the credential read from stdin,
+// printing what get finds.
+func runHelperAction(helper repo.CredentialHelper, action string) {
+	credential, err := repo.ParseCredential(os.Stdin)
+	check(err)
+	switch action {
+	case "get":
+		answer, err := helper.Get(credential)
+		check(err)
+		if answer != nil {
+			check(answer.Encode(os.Stdout))
+		}
+	case "store":
+		check(helper.Store(credential))
+	case "erase":
+		check(helper.Erase(credential))
+	default:
+		// unknown actions are ignored so newer callers keep working
+	}
+}
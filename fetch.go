@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tamimehsan/gogit/repo"
+)
+
+func runFetch(args []string) {
+	fetchCmd := flag.NewFlagSet("fetch", flag.ExitOnError)
+	userName := fetchCmd.String("username", "", "The username for the remote repository")
+	opts := repo.FetchOptions{}
+	fetchCmd.BoolVar(&opts.UpdateHeadOK, "update-head-ok", false, "Allow updating the checked out branch")
+	fetchCmd.BoolVar(&opts.UpdateHeadOK, "u", false, "Shorthand for --update-head-ok")
+	fetchCmd.BoolVar(&opts.Tags, "tags", false, "Fetch all tags")
+	fetchCmd.BoolVar(&opts.Force, "force", false, "Allow non-fast-forward updates")
+	fetchCmd.BoolVar(&opts.Force, "f", false, "Shorthand for --force")
+	args = parseArgs(fetchCmd, args)
+
+	opts.Remote = remoteArg("", args)
+	if len(args) > 0 {
+		args = args[1:]
+	}
+	opts.Refspecs = args
+	opts.UserName = *userName
+	opts.Progress = os.Stderr
+
+	result, err := openRepo().Fetch(opts)
+	if result != nil {
+		printFetchResult(result)
+	}
+	check(err)
+}
+
+func printFetchResult(result *repo.FetchResult) {
+	header := false
+	for _, update := range result.Updates {
+		if update.Status == repo.RefUpdateUpToDate {
+			continue
+		}
+		if !header {
+			fmt.Fprintln(os.Stderr, "From", result.URL)
+			header = true
+		}
+		if update.Dst == "" {
+			kind := "branch"
+			if strings.HasPrefix(update.Src, "refs/tags/") {
+				kind = "tag"
+			}
+			src := repo.ShortRefName(update.Src)
+			if src == "" {
+				src = update.New[:7]
+			}
+			fmt.Fprintf(os.Stderr, " * %-17s %s -> FETCH_HEAD\n", kind, src)
+			continue
+		}
+		fmt.Fprintln(os.Stderr, formatRefUpdate(update, update.Src, update.Dst))
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tamimehsan/gogit/repo"
+)
+
+func runLsFiles(args []string) {
+	lsFilesCmd := flag.NewFlagSet("ls-files", flag.ExitOnError)
+	cached := lsFilesCmd.Bool("c", false, "Show the files in the index")
+	lsFilesCmd.BoolVar(cached, "cached", false, "Show the files in the index")
+	stage := lsFilesCmd.Bool("s", false, "Show the mode, object name and stage of the files in the index")
+	lsFilesCmd.BoolVar(stage, "stage", false, "Show the mode, object name and stage of the files in the index")
+	modified := lsFilesCmd.Bool("m", false, "Show the files that differ from the index, deleted ones included")
+	lsFilesCmd.BoolVar(modified, "modified", false, "Show the files that differ from the index, deleted ones included")
+	deleted := lsFilesCmd.Bool("d", false, "Show the files of the index missing from the working tree")
+	lsFilesCmd.BoolVar(deleted, "deleted", false, "Show the files of the index missing from the working tree")
+	others := lsFilesCmd.Bool("o", false, "Show the untracked files")
+	lsFilesCmd.BoolVar(others, "others", false, "Show the untracked files")
+	var excludes, excludeFiles stringsFlag
+	lsFilesCmd.Var(&excludes, "x", "Skip untracked files matching the pattern")
+	lsFilesCmd.Var(&excludes, "exclude", "Skip untracked files matching the pattern")
+	lsFilesCmd.Var(&excludeFiles, "X", "Read exclude patterns from the file")
+	lsFilesCmd.Var(&excludeFiles, "exclude-from", "Read exclude patterns from the file")
+	perDirectory := lsFilesCmd.String("exclude-per-directory", "", "Read exclude patterns from the file of that name in each directory")
+	standard := lsFilesCmd.Bool("exclude-standard", false, "Use .gitignore, .git/info/exclude and core.excludesFile")
+	nulTerminated := lsFilesCmd.Bool("z", false, "Terminate entries with NUL and do not quote paths")
+	fullName := lsFilesCmd.Bool("full-name", false, "Show paths from the top of the working tree")
+	args = parseArgs(lsFilesCmd, args)
+
+	r := openRepo()
+	prefix, err := r.Rel(".")
+	check(err)
+	paths := []string{}
+	for _, path := range args {
+		if path == "--" {
+			continue
+		}
+		rel, err := r.Rel(path)
+		check(err)
+		paths = append(paths, rel)
+	}
+	// like git, a subdirectory lists what is below it
+	if len(paths) == 0 && prefix != "." {
+		paths = append(paths, prefix)
+	}
+	if *fullName {
+		prefix = "."
+	}
+	if !*modified && !*deleted && !*others {
+		*cached = true
+	}
+
+	out := newPathWriter(r, prefix, *nulTerminated)
+	defer out.Flush()
+	if *others {
+		ignore := &repo.Ignore{}
+		if *standard {
+			var err error
+			ignore, err = r.StandardIgnore()
+			check(err)
+		}
+		if *perDirectory != "" {
+			ignore.PerDirectory = *perDirectory
+		}
+		for _, file := range excludeFiles {
+			check(ignore.AddFile(file))
+		}
+		for _, pattern := range excludes {
+			ignore.AddPattern(pattern)
+		}
+		untracked, err := r.UntrackedFiles(ignore)
+		check(err)
+		for _, file := range untracked {
+			if repo.MatchPathspec(paths, file) {
+				out.WritePath("", file)
+			}
+		}
+	}
+
+	entries, err := r.ReadIndex()
+	check(err)
+	if *cached || *stage {
+		for _, entry := range entries {
+			if !repo.MatchPathspec(paths, entry.Path) {
+				continue
+			}
+			if *stage {
+				out.WritePath(fmt.Sprintf("%06o %s %d\t", entry.Mode, entry.Hash(), entry.Stage()), entry.Path)
+			} else {
+				out.WritePath("", entry.Path)
+			}
+		}
+	}
+	if *modified || *deleted {
+		status, err := r.Status()
+		check(err)
+		changed := map[string]bool{}
+		for _, file := range status.Modified {
+			changed[file] = true
+		}
+		missing := map[string]bool{}
+		for _, file := range status.Deleted {
+			missing[file] = true
+		}
+		for _, entry := range entries {
+			if !repo.MatchPathspec(paths, entry.Path) {
+				continue
+			}
+			if *deleted && missing[entry.Path] {
+				out.WritePath("", entry.Path)
+			}
+			if *modified && (missing[entry.Path] || changed[entry.Path]) {
+				out.WritePath("", entry.Path)
+			}
+		}
+	}
+}
+
+// pathWriter prints records that end with a path, shown relative to a
+// directory of the working tree. Unless records end with NUL, paths with
+// unusual characters are quoted as git does.
+type pathWriter struct {
+	*bufio.Writer
+	dir      string
+	nul      bool
+	quoteAll bool
+}
+
+// newPathWriter returns a pathWriter showing paths relative to dir, which
+// is relative to the top of the working tree.
+func newPathWriter(r *repo.Repository, dir string, nul bool) *pathWriter {
+	quoteAll := true
+	if config, err := r.Config(); err == nil {
+		quoteAll = config.GetBool("core.quotepath", true)
+	}
+	return &pathWriter{Writer: bufio.NewWriter(os.Stdout), dir: dir, nul: nul, quoteAll: quoteAll}
+}
+
+// WritePath writes prefix and path as one record.
+func (w *pathWriter) WritePath(prefix, path string) {
+	if w.dir != "." {
+		if rel, err := filepath.Rel(w.dir, path); err == nil {
+			path = filepath.ToSlash(rel)
+		}
+	}
+	if w.nul {
+		w.WriteString(prefix + path + "\x00")
+		return
+	}
+	w.WriteString(prefix + quotePath(path, w.quoteAll) + "\n")
+}
+
+// quotePath returns path in double quotes with C escapes if it holds
+// control characters, quotes or backslashes, or bytes above 0x7f when
+// quoteAll is set. Other paths are returned as they are.
+func quotePath(path string, quoteAll bool) string {
+	needsQuotes := false
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c < 0x20 || c == '"' || c == '\\' || c == 0x7f || (c >= 0x80 && quoteAll) {
+			needsQuotes = true
+			break
+		}
+	}
+	if !needsQuotes {
+		return path
+	}
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; {
+		case c == '"' || c == '\\':
+			quoted.WriteByte('\\')
+			quoted.WriteByte(c)
+		case c == '\a':
+			quoted.WriteString(`\a`)
+		case c == '\b':
+			quoted.WriteString(`\b`)
+		case c == '\t':
+			quoted.WriteString(`\t`)
+		case c == '\n':
+			quoted.WriteString(`\n`)
+		case c == '\v':
+			quoted.WriteString(`\v`)
+		case c == '\f':
+			quoted.WriteString(`\f`)
+		case c == '\r':
+			quoted.WriteString(`\r`)
+		case c < 0x20 || c == 0x7f || (c >= 0x80 && quoteAll):
+			fmt.Fprintf(&quoted, `\%03o`, c)
+		default:
+			quoted.WriteByte(c)
+		}
+	}
+	quoted.WriteByte('"')
+	return quoted.String()
+}
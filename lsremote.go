@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/tamimehsan/gogit/repo"
+)
+
+func runLsRemote(args []string) {
+	lsRemoteCmd := flag.NewFlagSet("ls-remote", flag.ExitOnError)
+	userName := lsRemoteCmd.String("u", "", "The username for the remote repository")
+	symref := lsRemoteCmd.Bool("symref", false, "Show the targets of symbolic refs")
+	exitCode := lsRemoteCmd.Bool("exit-code", false, "Exit with status 2 when no refs match")
+	opts := repo.ListRemoteOptions{}
+	lsRemoteCmd.BoolVar(&opts.Heads, "heads", false, "Limit to branches")
+	lsRemoteCmd.BoolVar(&opts.Heads, "h", false, "Shorthand for --heads")
+	lsRemoteCmd.BoolVar(&opts.Tags, "tags", false, "Limit to tags")
+	lsRemoteCmd.BoolVar(&opts.Tags, "t", false, "Shorthand for --tags")
+	args = parseArgs(lsRemoteCmd, args)
+
+	opts.Remote = remoteArg("", args)
+	if len(args) > 0 {
+		opts.Patterns = args[1:]
+	}
+	opts.UserName = *userName
+
+	adv, err := openRepo().ListRemote(opts)
+	check(err)
+	for _, ref := range adv.Refs {
+		if target, found := adv.Symrefs[ref.Name]; found && *symref {
+			fmt.Printf("ref: %s\t%s\n", target, ref.Name)
+		}
+		fmt.Printf("%s\t%s\n", ref.Hash, ref.Name)
+		if peeled, found := adv.Peeled[ref.Name]; found {
+			fmt.Printf("%s\t%s^{}\n", peeled, ref.Name)
+		}
+	}
+	if *exitCode && len(adv.Refs) == 0 {
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/tamimehsan/gogit/repo"
+)
+
+func runLsTree(args []string) {
+	lsTreeCmd := flag.NewFlagSet("ls-tree", flag.ExitOnError)
+	opts := repo.ListTreeOptions{}
+	lsTreeCmd.BoolVar(&opts.Recursive, "r", false, "Recurse into subtrees")
+	lsTreeCmd.BoolVar(&opts.ShowTrees, "t", false, "Show the trees recursed into")
+	nameOnly := lsTreeCmd.Bool("name-only", false, "Show only the paths")
+	lsTreeCmd.BoolVar(nameOnly, "name-status", false, "Show only the paths")
+	nulTerminated := lsTreeCmd.Bool("z", false, "Terminate entries with NUL and do not quote paths")
+	fullName := lsTreeCmd.Bool("full-name", false, "Show paths from the root of the tree")
+	fullTree := lsTreeCmd.Bool("full-tree", false, "List the whole tree wherever the command runs")
+	args = parseArgs(lsTreeCmd, args)
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		usageError("usage: gogit ls-tree [-r] [-t] [--name-only] [-z] <tree-ish> [<path>...]")
+	}
+
+	r := openRepo()
+	tree, err := r.ResolveRevision(args[0] + "^{tree}")
+	if err != nil {
+		check(fmt.Errorf("not a tree object: %s", args[0]))
+	}
+	// like git, paths are relative to the current directory, which limits
+	// the listing when none are given
+	prefix := "."
+	if !r.IsBare() && !*fullTree {
+		prefix, err = r.Rel(".")
+		check(err)
+	}
+	for _, path := range args[1:] {
+		if path == "--" {
+			continue
+		}
+		rel := path
+		if prefix != "." {
+			rel, err = r.Rel(path)
+			check(err)
+			if strings.HasSuffix(path, "/") && rel != "." {
+				rel += "/"
+			}
+		}
+		opts.Paths = append(opts.Paths, rel)
+	}
+	if len(opts.Paths) == 0 && prefix != "." {
+		opts.Paths = append(opts.Paths, prefix+"/")
+	}
+	if *fullName || *fullTree {
+		prefix = "."
+	}
+	files, err := r.ListTree(tree, opts)
+	check(err)
+
+	out := newPathWriter(r, prefix, *nulTerminated)
+	defer out.Flush()
+	for _, file := range files {
+		if *nameOnly {
+			out.WritePath("", file.Path)
+			continue
+		}
+		out.WritePath(fmt.Sprintf("%06o %s %s\t", file.Mode, file.Type(), file.Hash), file.Path)
+	}
+}
@@ -1,67 +1,220 @@
-package main
-
-import (
-	"encoding/hex"
-	"flag"
-	"fmt"
-	"os"
-)
-
-func main() {
-
-	initCmd := flag.NewFlagSet("init", flag.ExitOnError)
-	hashObjectCmd := flag.NewFlagSet("hash-object", flag.ExitOnError)
-	addCmd := flag.NewFlagSet("add", flag.ExitOnError)
-	catFilesCmd := flag.NewFlagSet("cat-file", flag.ExitOnError)
-	pushCmd := flag.NewFlagSet("push", flag.ExitOnError)
-
-	password := pushCmd.String("p", "", "The password for the remote repository")
-	userName := pushCmd.String("u", "", "The username for the remote repository")
-	remote := pushCmd.String("r", "", "The remote repository")
-
-	// statusCmd := flag.NewFlagSet("status", flag.ExitOnError)
-
-	commitCmd := flag.NewFlagSet("commit", flag.ExitOnError)
-
-	// diffCmd := flag.NewFlagSet("diff", flag.ExitOnError)
-
-	objectType := hashObjectCmd.String("t", "blob", "The type of the object")
-
-	switch os.Args[1] {
-	case "init":
-		initCmd.Parse(os.Args[2:])
-		directory := initCmd.Arg(0)
-		gitInit(directory)
-	case "hash-object":
-		hashObjectCmd.Parse(os.Args[2:])
-		file := hashObjectCmd.Arg(0)
-		gitHashObject(file, *objectType)
-	case "add":
-		addCmd.Parse(os.Args[2:])
-		files := addCmd.Args()
-		gitAdd(files)
-	case "ls-files":
-		indexes := readIndex()
-		for _, entry := range indexes {
-			fmt.Println(entry.path, " ", entry.size, " ", hex.EncodeToString(entry.sha1))
-		}
-	case "cat-file":
-		catFilesCmd.Parse(os.Args[2:])
-		file := catFilesCmd.Arg(0)
-		gitCatFile(file)
-	case "status":
-		gitStatus()
-	case "tree":
-		createTree()
-	case "commit":
-		commitCmd.Parse(os.Args[2:])
-		msg := commitCmd.Arg(0)
-		createCommit(msg)
-	case "push":
-		pushCmd.Parse(os.Args[2:])
-		gitPush(*remote, *userName, *password)
-	case "version":
-		fmt.Println("gogit version 0.0.1")
-	}
-
-}
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/tamimehsan/gogit/repo"
+)
+
+func main() {
+
+	initCmd := flag.NewFlagSet("init", flag.ExitOnError)
+	hashObjectCmd := flag.NewFlagSet("hash-object", flag.ExitOnError)
+
+	objectType := hashObjectCmd.String("t", "blob", "The type of the object")
+
+	args, err := parseGlobalOptions(os.Args[1:])
+	check(err)
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "usage: gogit [-C <path>] [--git-dir=<path>] [--work-tree=<path>] <command> [<args>]")
+		os.Exit(1)
+	}
+	os.Args = append([]string{os.Args[0]}, args...)
+
+	switch os.Args[1] {
+	case "init":
+		opts := repo.InitOptions{}
+		initCmd.BoolVar(&opts.Bare, "bare", false, "Create a bare repository")
+		initCmd.StringVar(&opts.InitialBranch, "b", "", "The name of the initial branch")
+		initCmd.StringVar(&opts.InitialBranch, "initial-branch", "", "The name of the initial branch")
+		args := parseArgs(initCmd, os.Args[2:])
+		if len(args) > 1 {
+			usageError("usage: gogit init [--bare] [-b <branch-name>] [<directory>]")
+		}
+		directory := ""
+		if len(args) == 1 {
+			directory = args[0]
+		}
+		r, err := repo.InitWithOptions(directory, opts)
+		check(err)
+		absDirectory, err := filepath.Abs(r.GitDir())
+		check(err)
+		fmt.Println("Initialized empty Git repository in", path.Clean(absDirectory))
+	case "hash-object":
+		hashObjectCmd.Parse(os.Args[2:])
+		file := hashObjectCmd.Arg(0)
+		data, err := readInput(file)
+		check(err)
+		fmt.Println(repo.HashObject(bytes.NewReader(data), *objectType, len(data)))
+	case "add":
+		runAdd(os.Args[2:])
+	case "ls-files":
+		runLsFiles(os.Args[2:])
+	case "ls-tree":
+		runLsTree(os.Args[2:])
+	case "cat-file":
+		runCatFile(os.Args[2:])
+	case "status":
+		status, err := openRepo().Status()
+		check(err)
+		printStatus(status)
+	case "tree":
+		_, err := openRepo().CreateTree()
+		check(err)
+	case "commit":
+		runCommit(os.Args[2:])
+	case "restore":
+		runRestore(os.Args[2:])
+	case "reset":
+		runReset(os.Args[2:])
+	case "rev-parse":
+		runRevParse(os.Args[2:])
+	case "tag":
+		runTag(os.Args[2:])
+	case "reflog":
+		runReflog(os.Args[2:])
+	case "update-ref":
+		runUpdateRef(os.Args[2:])
+	case "symbolic-ref":
+		runSymbolicRef(os.Args[2:])
+	case "pack-refs":
+		packRefsCmd := flag.NewFlagSet("pack-refs", flag.ExitOnError)
+		opts := repo.PackRefsOptions{}
+		packRefsCmd.BoolVar(&opts.All, "all", false, "Pack every ref, not only tags and refs already packed")
+		packRefsCmd.BoolVar(&opts.NoPrune, "no-prune", false, "Keep the loose files of packed refs")
+		packRefsCmd.Parse(os.Args[2:])
+		check(openRepo().PackRefs(opts))
+	case "push":
+		runPush(os.Args[2:])
+	case "fetch":
+		runFetch(os.Args[2:])
+	case "clone":
+		runClone(os.Args[2:])
+	case "upload-pack", "receive-pack":
+		runService(os.Args[1], os.Args[2:])
+	case "credential":
+		runCredential(os.Args[2:])
+	case "credential-store":
+		runCredentialStore(os.Args[2:])
+	case "credential-cache":
+		runCredentialCache(os.Args[2:])
+	case "credential-cache--daemon":
+		runCredentialCacheDaemon(os.Args[2:])
+	case "serve":
+		runServe(os.Args[2:])
+	case "ls-remote":
+		runLsRemote(os.Args[2:])
+	case "remote":
+		runRemote(os.Args[2:])
+	case "config":
+		runConfig(os.Args[2:])
+	case "version":
+		fmt.Println("gogit version 0.0.1")
+	default:
+		fmt.Fprintf(os.Stderr, "gogit: '%s' is not a gogit command\n", os.Args[1])
+		os.Exit(1)
+	}
+
+}
+
+// parseGlobalOptions applies the options given before the command name and
+// returns the remaining arguments. -C changes directory, while --git-dir and
+// --work-tree are passed on through GIT_DIR and GIT_WORK_TREE as git does.
+func parseGlobalOptions(args []string) ([]string, error) {
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		option, value, hasValue := strings.Cut(args[0], "=")
+		if !hasValue && (option == "-C" || option == "--git-dir" || option == "--work-tree") {
+			if len(args) < 2 {
+				return nil, fmt.Errorf("no directory given for %s", option)
+			}
+			value = args[1]
+			args = args[1:]
+		}
+		args = args[1:]
+
+		switch option {
+		case "-C":
+			if err := os.Chdir(value); err != nil {
+				return nil, fmt.Errorf("cannot change to '%s': %w", value, err)
+			}
+		case "--git-dir":
+			abs, err := filepath.Abs(value)
+			if err != nil {
+				return nil, err
+			}
+			os.Setenv("GIT_DIR", abs)
+		case "--work-tree":
+			abs, err := filepath.Abs(value)
+			if err != nil {
+				return nil, err
+			}
+			os.Setenv("GIT_WORK_TREE", abs)
+		default:
+			return nil, fmt.Errorf("unknown option: %s", option)
+		}
+	}
+	return args, nil
+}
+
+// openRepo discovers the repository containing the current directory or exits.
+func openRepo() *repo.Repository {
+	r, err := repo.Discover(".")
+	check(err)
+	return r
+}
+
+// check exits with a fatal message if err is not nil.
+func check(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fatal:", err)
+		os.Exit(1)
+	}
+}
+
+// parseArgs parses flags wherever they appear among the arguments, the way
+// git does, and returns the positional arguments. Everything after "--" is
+// positional; the "--" itself is kept so commands can tell paths apart.
+func parseArgs(flags *flag.FlagSet, args []string) []string {
+	positional := []string{}
+	for {
+		flags.Parse(args)
+		rest := flags.Args()
+		consumed := len(args) - len(rest)
+		if consumed > 0 && args[consumed-1] == "--" {
+			return append(append(positional, "--"), rest...)
+		}
+		if len(rest) == 0 {
+			return positional
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// readInput reads filename, or stdin if filename is empty or "-".
+func readInput(filename string) ([]byte, error) {
+	if filename == "" || filename == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(filename)
+}
+
+// stringsFlag is a flag that can be given several times, collecting every
+// value in order.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ", ")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
@@ -1,103 +1,123 @@
-package main
-
-import (
-	"bytes"
-	"compress/zlib"
-	"crypto/sha1"
-	"fmt"
-	"strings"
-)
-
-func gitPush(remote, userName, password string) {
-
-	if userName == "" {
-		userName = getUserName()
-	}
-	if password == "" {
-		password = getPassword()
-	}
-
-	if remote == "" {
-		panic("Remote repository not specified")
-	}
-
-	remoteHash := getRemoteMasterCommit(remote, userName, password)
-	localHash := getLocalMasterCommit()
-
-	localObjects := getObjects(localHash, remoteHash)
-	localObjects = uniqueObjects(localObjects)
-
-	remoteObjects := getObjects(remoteHash, "")
-	remoteObjects = uniqueObjects(remoteObjects)
-
-	missingObjects := getMissingObjects(localObjects, remoteObjects)
-	// fmt.Println("missing objects: ", missingObjects)
-	// os.Exit(0)
-	line := fmt.Sprintf("%s %s refs/heads/master\x00 report-status", remoteHash, localHash)
-	line = fmt.Sprintf("%04x%s\n0000", len(line)+5, line)
-
-	pack := createPack(missingObjects)
-
-	data := append([]byte(line), pack...)
-	// send a post request to the remote repository
-	url := remote + "/git-receive-pack"
-
-	gitPushPack(url, userName, password, data)
-
-}
-
-func createPack(objects []string) []byte {
-
-	header := []byte("PACK")
-	header = append(header, paddInteger(2, 4)...)
-	header = append(header, paddInteger(len(objects), 4)...)
-
-	body := []byte{}
-	for _, object := range objects {
-		body = append(body, encodePack(object)...)
-	}
-
-	contents := append(header, []byte(body)...)
-
-	hasher := sha1.New()
-	hasher.Write(contents)
-	contents = append(contents, hasher.Sum(nil)...)
-
-	return contents
-}
-
-func encodePack(object string) []byte {
-	objectType, contents := readObject(object)
-	data := []byte(strings.Join(contents, "\n"))
-	header := []byte{}
-
-	enum := 0
-	if objectType == "commit" {
-		enum = 1
-	} else if objectType == "tree" {
-		enum = 2
-	} else if objectType == "blob" {
-		enum = 3
-	}
-	size := len(data)
-	byt := byte(enum<<4 | size&0x0f)
-	size >>= 4
-	for size > 0 {
-		header = append(header, byt|0x80)
-		byt = byte(size & 0x7f)
-		size >>= 7
-	}
-	header = append(header, byt)
-
-	var buf bytes.Buffer
-	writer, _ := zlib.NewWriterLevel(&buf, zlib.BestSpeed)
-	_, err := writer.Write(data)
-	if err != nil {
-		return nil
-	}
-	writer.Close()
-
-	compressedData := buf.Bytes()
-	header = append(header, compressedData...)
-	return header
-}
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tamimehsan/gogit/repo"
+)
+
+// leaseFlag collects --force-with-lease[=<ref>[:<expect>]] options.
+type leaseFlag struct {
+	leases *[]repo.Lease
+}
+
+func (f leaseFlag) String() string { return "" }
+
+func (f leaseFlag) IsBoolFlag() bool { return true }
+
+func (f leaseFlag) Set(value string) error {
+	if value == "true" {
+		*f.leases = append(*f.leases, repo.Lease{})
+		return nil
+	}
+	ref, expect, hasExpect := strings.Cut(value, ":")
+	if hasExpect && expect == "" {
+		expect = repo.ZeroHash
+	}
+	*f.leases = append(*f.leases, repo.Lease{Ref: ref, Expect: expect})
+	return nil
+}
+
+func runPush(args []string) {
+	pushCmd := flag.NewFlagSet("push", flag.ExitOnError)
+	userName := pushCmd.String("username", "", "The username for the remote repository")
+	remote := pushCmd.String("r", "", "The remote repository, deprecated in favour of the first argument")
+	opts := repo.PushOptions{}
+	pushCmd.BoolVar(&opts.All, "all", false, "Push all branches")
+	pushCmd.BoolVar(&opts.Tags, "tags", false, "Push all tags")
+	pushCmd.BoolVar(&opts.FollowTags, "follow-tags", false, "Also push annotated tags pointing into the pushed history")
+	pushCmd.BoolVar(&opts.Force, "force", false, "Allow non-fast-forward updates")
+	pushCmd.BoolVar(&opts.Force, "f", false, "Shorthand for --force")
+	pushCmd.BoolVar(&opts.SetUpstream, "set-upstream", false, "Set the upstream of pushed branches")
+	pushCmd.BoolVar(&opts.SetUpstream, "u", false, "Shorthand for --set-upstream")
+	pushCmd.Var(leaseFlag{&opts.Leases}, "force-with-lease", "Force only if the remote ref has the expected value")
+	args = parseArgs(pushCmd, args)
+
+	opts.Remote = remoteArg(*remote, args)
+	if *remote == "" && len(args) > 0 {
+		args = args[1:]
+	}
+	opts.Refspecs = args
+	opts.UserName = *userName
+	opts.Progress = os.Stderr
+
+	result, err := openRepo().Push(opts)
+	if result != nil {
+		printPushResult(result)
+	}
+	check(err)
+}
+
+func printPushResult(result *repo.PushResult) {
+	upToDate := true
+	for _, update := range result.Updates {
+		if update.Status != repo.RefUpdateUpToDate {
+			upToDate = false
+		}
+	}
+	if upToDate {
+		fmt.Fprintln(os.Stderr, "Everything up-to-date")
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, "To", result.URL)
+	for _, update := range result.Updates {
+		if update.Status == repo.RefUpdateUpToDate {
+			continue
+		}
+		src := update.Src
+		if src == "" && !update.IsDelete() {
+			src = update.New[:7]
+		}
+		fmt.Fprintln(os.Stderr, formatRefUpdate(update, src, update.Dst))
+	}
+}
+
+// formatRefUpdate describes an update the way push and fetch report it,
+// e.g. "   1234567..89abcde  main -> main".
+func formatRefUpdate(update repo.RefUpdate, src, dst string) string {
+	flag, summary, reason := ' ', "", ""
+	switch {
+	case update.Status == repo.RefUpdateRejected:
+		flag, summary, reason = '!', "[rejected]", update.Reason
+	case update.Status == repo.RefUpdateRemoteRejected:
+		flag, summary, reason = '!', "[remote rejected]", update.Reason
+	case update.IsDelete():
+		flag, summary = '-', "[deleted]"
+	case update.Old == repo.ZeroHash:
+		flag, summary = '*', "[new reference]"
+		if strings.HasPrefix(dst, "refs/tags/") {
+			summary = "[new tag]"
+		} else if strings.HasPrefix(dst, "refs/heads/") || strings.HasPrefix(src, "refs/heads/") {
+			summary = "[new branch]"
+		}
+	case update.Forced:
+		flag, summary, reason = '+', update.Old[:7]+"..."+update.New[:7], "forced update"
+	default:
+		summary = update.Old[:7] + ".." + update.New[:7]
+	}
+
+	line := fmt.Sprintf(" %c %-17s ", flag, summary)
+	if update.IsDelete() {
+		line += repo.ShortRefName(dst)
+	} else {
+		line += repo.ShortRefName(src) + " -> " + repo.ShortRefName(dst)
+	}
+	if reason != "" {
+		line += " (" + reason + ")"
+	}
+	return line
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tamimehsan/gogit/repo"
+)
+
+func runReflog(args []string) {
+	action := "show"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		switch args[0] {
+		case "show", "expire", "delete", "exists":
+			action, args = args[0], args[1:]
+		}
+	}
+	switch action {
+	case "show":
+		runReflogShow(args)
+	case "expire":
+		runReflogExpire(args)
+	case "delete":
+		runReflogDelete(args)
+	case "exists":
+		if len(args) != 1 {
+			usageError("usage: gogit reflog exists <ref>")
+		}
+		if !openRepo().HasReflog(args[0]) {
+			os.Exit(1)
+		}
+	}
+}
+
+func runReflogShow(args []string) {
+	showCmd := flag.NewFlagSet("reflog show", flag.ExitOnError)
+	limit := showCmd.Int("n", -1, "Show at most that many entries")
+	args = parseArgs(showCmd, args)
+	if len(args) > 1 {
+		usageError("usage: gogit reflog [show] [-n <number>] [<ref>]")
+	}
+	name := "HEAD"
+	if len(args) == 1 {
+		name = args[0]
+	}
+
+	r := openRepo()
+	entries, err := r.ReadReflog(reflogRef(r, name))
+	check(err)
+	for i := 0; i < len(entries) && i != *limit; i++ {
+		entry := entries[len(entries)-1-i]
+		hash, err := r.AbbreviateHash(entry.New, 7)
+		check(err)
+		fmt.Printf("%s %s@{%d}: %s\n", hash, name, i, entry.Message)
+	}
+}
+
+func runReflogExpire(args []string) {
+	expireCmd := flag.NewFlagSet("reflog expire", flag.ExitOnError)
+	expire := expireCmd.String("expire", "", "Drop entries older than this, default gc.reflogExpire or 90 days")
+	expireUnreachable := expireCmd.String("expire-unreachable", "", "Drop entries older than this that are not reachable from the ref, default gc.reflogExpireUnreachable or 30 days")
+	all := expireCmd.Bool("all", false, "Expire the reflogs of every ref")
+	opts := repo.ReflogPruneOptions{}
+	expireCmd.BoolVar(&opts.Rewrite, "rewrite", false, "Chain the old value of each kept entry to the entry before it")
+	expireCmd.BoolVar(&opts.UpdateRef, "updateref", false, "Point the ref at the last kept entry")
+	expireCmd.BoolVar(&opts.DryRun, "n", false, "Show what would be dropped")
+	expireCmd.BoolVar(&opts.DryRun, "dry-run", false, "Show what would be dropped")
+	verbose := expireCmd.Bool("verbose", false, "Show the dropped entries")
+	args = parseArgs(expireCmd, args)
+
+	r := openRepo()
+	config, err := r.Config()
+	check(err)
+	now := time.Now()
+	expiry := func(value, key, fallback string) time.Time {
+		if value == "" {
+			value = fallback
+			if configured, ok := config.Get(key); ok {
+				value = configured
+			}
+		}
+		when, err := parseExpiry(value, now)
+		check(err)
+		return when
+	}
+	opts.Expire = expiry(*expire, "gc.reflogexpire", "90.days.ago")
+	opts.ExpireUnreachable = expiry(*expireUnreachable, "gc.reflogexpireunreachable", "30.days.ago")
+
+	refs := []string{}
+	if *all {
+		refs, err = r.ReflogRefs()
+		check(err)
+	}
+	for _, name := range args {
+		if name != "--" {
+			refs = append(refs, reflogRef(r, name))
+		}
+	}
+	if len(refs) == 0 {
+		usageError("usage: gogit reflog expire [--expire=<time>] [--expire-unreachable=<time>] [--rewrite] [--updateref] [-n] [--verbose] [--all] <refs>...")
+	}
+	for _, ref := range refs {
+		dropped, err := r.ExpireReflog(ref, opts)
+		check(err)
+		printPruned(dropped, *verbose, opts.DryRun)
+	}
+}
+
+func runReflogDelete(args []string) {
+	deleteCmd := flag.NewFlagSet("reflog delete", flag.ExitOnError)
+	opts := repo.ReflogPruneOptions{}
+	deleteCmd.BoolVar(&opts.Rewrite, "rewrite", false, "Chain the old value of each kept entry to the entry before it")
+	deleteCmd.BoolVar(&opts.UpdateRef, "updateref", false, "Point the ref at the last kept entry")
+	deleteCmd.BoolVar(&opts.DryRun, "n", false, "Show what would be dropped")
+	deleteCmd.BoolVar(&opts.DryRun, "dry-run", false, "Show what would be dropped")
+	verbose := deleteCmd.Bool("verbose", false, "Show the dropped entries")
+	args = parseArgs(deleteCmd, args)
+	if len(args) == 0 {
+		usageError("usage: gogit reflog delete [--rewrite] [--updateref] [-n] [--verbose] <ref>@{<n>}...")
+	}
+
+	r := openRepo()
+	// entries of one ref are deleted together, as positions shift
+	positions := map[string][]int{}
+	refs := []string{}
+	for _, arg := range args {
+		match := reflogEntryPattern.FindStringSubmatch(arg)
+		if match == nil {
+			check(fmt.Errorf("not a reflog: %s", arg))
+		}
+		name := match[1]
+		if name == "" {
+			name = "HEAD"
+		}
+		ref := reflogRef(r, name)
+		if !r.HasReflog(ref) {
+			check(fmt.Errorf("%s: no reflog for '%s'", arg, name))
+		}
+		n, _ := strconv.Atoi(match[2])
+		if _, found := positions[ref]; !found {
+			refs = append(refs, ref)
+		}
+		positions[ref] = append(positions[ref], n)
+	}
+	for _, ref := range refs {
+		dropped, err := r.DeleteReflogEntries(ref, positions[ref], opts)
+		check(err)
+		printPruned(dropped, *verbose, opts.DryRun)
+	}
+}
+
+// printPruned lists the dropped reflog entries like git does.
+func printPruned(dropped []repo.ReflogEntry, verbose, dryRun bool) {
+	if !verbose && !dryRun {
+		return
+	}
+	for _, entry := range dropped {
+		if dryRun {
+			fmt.Printf("would prune %s\n", entry.Message)
+		} else {
+			fmt.Printf("prune %s\n", entry.Message)
+		}
+	}
+}
+
+var reflogEntryPattern = regexp.MustCompile(`^(.*)@\{(\d+)\}$`)
+
+// reflogRef returns the full name of the ref whose reflog name refers to.
+func reflogRef(r *repo.Repository, name string) string {
+	if name == "HEAD" || name == "@" || r.HasReflog(name) {
+		if name == "@" {
+			return "HEAD"
+		}
+		return name
+	}
+	ref, err := r.ExpandRef(name)
+	if errors.Is(err, repo.ErrRefNotFound) {
+		check(fmt.Errorf("ambiguous argument '%s': unknown revision or path not in the working tree.", name))
+	}
+	check(err)
+	return ref
+}
+
+var relativeExpiry = regexp.MustCompile(`^(\d+)[. ]*(second|minute|hour|day|week|month|year)s?([. ]*ago)?$`)
+
+// parseExpiry reads the times reflog expire takes: "now" or "all",
+// "never" or "false", "<n>.<unit>.ago", a date or a unix timestamp as
+// "@<seconds>". Never is the zero time.
+func parseExpiry(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	switch strings.ToLower(value) {
+	case "now", "all":
+		return now, nil
+	case "never", "false":
+		return time.Time{}, nil
+	}
+	if match := relativeExpiry.FindStringSubmatch(strings.ToLower(value)); match != nil {
+		n, err := strconv.Atoi(match[1])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid expiry '%s'", value)
+		}
+		switch match[2] {
+		case "second":
+			return now.Add(-time.Duration(n) * time.Second), nil
+		case "minute":
+			return now.Add(-time.Duration(n) * time.Minute), nil
+		case "hour":
+			return now.Add(-time.Duration(n) * time.Hour), nil
+		case "day":
+			return now.AddDate(0, 0, -n), nil
+		case "week":
+			return now.AddDate(0, 0, -7*n), nil
+		case "month":
+			return now.AddDate(0, -n, 0), nil
+		default:
+			return now.AddDate(-n, 0, 0), nil
+		}
+	}
+	if seconds, found := strings.CutPrefix(value, "@"); found {
+		if n, err := strconv.ParseInt(seconds, 10, 64); err == nil {
+			return time.Unix(n, 0), nil
+		}
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+		if when, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return when, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid expiry '%s'", value)
+}
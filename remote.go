@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/tamimehsan/gogit/repo"
+)
+
+func runRemote(args []string) {
+	remoteCmd := flag.NewFlagSet("remote", flag.ExitOnError)
+	verbose := remoteCmd.Bool("v", false, "Show the URL of each remote")
+	remoteCmd.BoolVar(verbose, "verbose", false, "Show the URL of each remote")
+	remoteCmd.Parse(args)
+	args = remoteCmd.Args()
+
+	r := openRepo()
+	if len(args) == 0 {
+		remotes, err := r.Remotes()
+		check(err)
+		for _, remote := range remotes {
+			if *verbose {
+				fmt.Printf("%s\t%s (fetch)\n", remote.Name, remote.URL)
+				fmt.Printf("%s\t%s (push)\n", remote.Name, remote.PushTarget())
+			} else {
+				fmt.Println(remote.Name)
+			}
+		}
+		return
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) != 3 {
+			usageError("usage: gogit remote add <name> <url>")
+		}
+		check(r.AddRemote(args[1], args[2]))
+	case "remove", "rm":
+		if len(args) != 2 {
+			usageError("usage: gogit remote remove <name>")
+		}
+		check(r.RemoveRemote(args[1]))
+	case "rename":
+		if len(args) != 3 {
+			usageError("usage: gogit remote rename <old> <new>")
+		}
+		check(r.RenameRemote(args[1], args[2]))
+	case "set-url":
+		setURLCmd := flag.NewFlagSet("set-url", flag.ExitOnError)
+		push := setURLCmd.Bool("push", false, "Set the push URL instead of the fetch URL")
+		setURLCmd.Parse(args[1:])
+		if setURLCmd.NArg() != 2 {
+			usageError("usage: gogit remote set-url [--push] <name> <url>")
+		}
+		check(r.SetRemoteURL(setURLCmd.Arg(0), setURLCmd.Arg(1), *push))
+	case "get-url":
+		if len(args) != 2 {
+			usageError("usage: gogit remote get-url <name>")
+		}
+		remote, err := r.Remote(args[1])
+		check(err)
+		fmt.Println(remote.URL)
+	default:
+		usageError("usage: gogit remote [-v] [add|remove|rename|set-url|get-url] ...")
+	}
+}
+
+// remoteArg returns the remote named on the command line, preferring the
+// legacy -r flag, or the default remote.
+func remoteArg(flagValue string, args []string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if len(args) > 0 {
+		return args[0]
+	}
+	return repo.DefaultRemote
+}
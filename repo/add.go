@@ -0,0 +1,128 @@
+package repo
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+)
+
+// Add stages the current contents of files in the index.
+func (r *Repository) Add(files []string) error {
+	if r.IsBare() {
+		return ErrBareRepository
+	}
+	lock, err := r.lockIndex()
+	if err != nil {
+		return err
+	}
+	defer lock.Rollback()
+	indexEntries := make([]IndexEntry, 0)
+	filemap := make(map[string]bool)
+	for _, filename := range files {
+		filemap[path.Clean(filename)] = true
+	}
+	indexes, err := r.ReadIndex()
+	if err != nil {
+		return err
+	}
+	for _, index := range indexes {
+		if _, ok := filemap[index.Path]; !ok {
+			indexEntries = append(indexEntries, index)
+		}
+	}
+	for filename := range filemap {
+		indexEntry, err := r.addFile(filename)
+		if err != nil {
+			return err
+		}
+		indexEntries = append(indexEntries, indexEntry)
+	}
+	sort.Slice(indexEntries, func(i, j int) bool { return indexEntries[i].Path < indexEntries[j].Path })
+	return r.writeToIndexFile(lock, indexEntries)
+}
+
+// addFile writes filename to the object store and returns its index entry.
+func (r *Repository) addFile(filename string) (IndexEntry, error) {
+	fullPath := path.Join(r.workTree, filename)
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return IndexEntry{}, fmt.Errorf("failed to open file %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	sz, err := getFileSize(file)
+	if err != nil {
+		return IndexEntry{}, err
+	}
+	fileHash := HashObject(file, "blob", sz)
+	file.Seek(0, 0)
+	if err := r.writeToObjectFile(file, fileHash, "blob", sz); err != nil {
+		return IndexEntry{}, err
+	}
+
+	indexEntry, err := createIndexEntry(fullPath, fileHash)
+	if err != nil {
+		return IndexEntry{}, err
+	}
+	indexEntry.Path = filename
+	indexEntry.Flags = len(filename)
+	return indexEntry, nil
+}
+
+// AddTracked stages the current contents of every tracked file and removes
+// the deleted ones from the index, as git add -u does.
+func (r *Repository) AddTracked() error {
+	if r.IsBare() {
+		return ErrBareRepository
+	}
+	lock, err := r.lockIndex()
+	if err != nil {
+		return err
+	}
+	defer lock.Rollback()
+	indexes, err := r.ReadIndex()
+	if err != nil {
+		return err
+	}
+	indexEntries := make([]IndexEntry, 0, len(indexes))
+	for _, index := range indexes {
+		if index.Stage() != 0 {
+			indexEntries = append(indexEntries, index)
+			continue
+		}
+		changed, err := r.trackedFileChanged(index)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if changed {
+			if index, err = r.addFile(index.Path); err != nil {
+				return err
+			}
+		}
+		indexEntries = append(indexEntries, index)
+	}
+	return r.writeToIndexFile(lock, indexEntries)
+}
+
+// trackedFileChanged reports whether the working tree file of entry has
+// other contents or another mode than the index records.
+func (r *Repository) trackedFileChanged(entry IndexEntry) (bool, error) {
+	fullPath := path.Join(r.workTree, entry.Path)
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		return false, err
+	}
+	if setCorrectMode(int(info.Mode().Perm())|0o100000) != entry.Mode {
+		return true, nil
+	}
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	return HashObject(file, "blob", int(info.Size())) != entry.Hash(), nil
+}
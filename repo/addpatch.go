@@ -0,0 +1,124 @@
+package repo
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+)
+
+// ErrIndexChanged is returned when staging hunks of a file whose index
+// entry changed since its patch was computed.
+var ErrIndexChanged = errors.New("index entry changed")
+
+// FilePatch is the difference between the staged and the working tree
+// contents of a file, as hunks that can be staged one by one.
+type FilePatch struct {
+	Path string
+	Mode int
+	// Staged and Worktree are the blob hashes of both versions.
+	Staged   string
+	Worktree string
+	Hunks    []Hunk
+
+	staged []byte
+}
+
+// UnstagedPatches returns the patches of the tracked files paths select,
+// all if none, whose working tree contents differ from the index. Deleted
+// and binary files are left out, as they cannot be staged by hunk.
+func (r *Repository) UnstagedPatches(paths []string) ([]FilePatch, error) {
+	if r.IsBare() {
+		return nil, ErrBareRepository
+	}
+	entries, err := r.ReadIndex()
+	if err != nil {
+		return nil, err
+	}
+	patches := []FilePatch{}
+	for _, entry := range entries {
+		if entry.Stage() != 0 || entry.Mode == 0o160000 || entry.Mode == 0o120000 || !MatchPathspec(paths, entry.Path) {
+			continue
+		}
+		worktree, err := os.ReadFile(path.Join(r.workTree, entry.Path))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		hash := HashObject(bytes.NewReader(worktree), "blob", len(worktree))
+		if hash == entry.Hash() {
+			continue
+		}
+		_, staged, err := r.ReadObject(entry.Hash())
+		if err != nil {
+			return nil, err
+		}
+		if isBinary(staged) || isBinary(worktree) {
+			continue
+		}
+		patches = append(patches, FilePatch{
+			Path:     entry.Path,
+			Mode:     entry.Mode,
+			Staged:   entry.Hash(),
+			Worktree: hash,
+			Hunks:    DiffHunks(staged, worktree),
+			staged:   staged,
+		})
+	}
+	return patches, nil
+}
+
+// isBinary reports whether data looks binary the way git guesses it: a
+// NUL byte among the first 8000 bytes.
+func isBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0
+}
+
+// StageHunks applies the hunks of patch whose positions selected lists to
+// the staged contents of its file, writes the result as a new blob and
+// points the index entry at it. The working tree is not touched.
+func (r *Repository) StageHunks(patch FilePatch, selected []int) error {
+	if len(selected) == 0 {
+		return nil
+	}
+	hunks := make([]Hunk, 0, len(selected))
+	for i, position := range selected {
+		if position < 0 || position >= len(patch.Hunks) || (i > 0 && position <= selected[i-1]) {
+			return fmt.Errorf("invalid hunk selection for %s", patch.Path)
+		}
+		hunks = append(hunks, patch.Hunks[position])
+	}
+	data := applyHunks(patch.staged, hunks)
+	hash := HashObject(bytes.NewReader(data), "blob", len(data))
+	if err := r.writeToObjectFile(bytes.NewReader(data), hash, "blob", len(data)); err != nil {
+		return err
+	}
+
+	lock, err := r.lockIndex()
+	if err != nil {
+		return err
+	}
+	defer lock.Rollback()
+	entries, err := r.ReadIndex()
+	if err != nil {
+		return err
+	}
+	for i, entry := range entries {
+		if entry.Path != patch.Path || entry.Stage() != 0 {
+			continue
+		}
+		if entry.Hash() != patch.Staged {
+			return fmt.Errorf("%w: %s", ErrIndexChanged, patch.Path)
+		}
+		// no stat information, as the working tree file does not match the
+		// new blob and must not look like it does
+		sha1, _ := hex.DecodeString(hash)
+		entries[i] = IndexEntry{Mode: entry.Mode, Sha1: sha1, Path: entry.Path, Flags: entry.Flags}
+		return r.writeToIndexFile(lock, entries)
+	}
+	return fmt.Errorf("%w: %s", ErrIndexChanged, patch.Path)
+}
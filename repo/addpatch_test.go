@@ -0,0 +1,60 @@
+package repo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStageHunks(t *testing.T) {
+	r := initTestRepo(t)
+	old := strings.Repeat("line\n", 10)
+	commitFile(t, r, "a", "first\n"+old+"last\n")
+	commitFile(t, r, "b", "unchanged\n")
+	worktree := "FIRST\n" + old + "LAST\n"
+	if err := os.WriteFile(filepath.Join(r.WorkTree(), "a"), []byte(worktree), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	patches, err := r.UnstagedPatches(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patches) != 1 || patches[0].Path != "a" || len(patches[0].Hunks) != 2 {
+		t.Fatalf("patches are %+v", patches)
+	}
+	for _, selected := range [][]int{{2}, {1, 0}, {0, 0}} {
+		if err := r.StageHunks(patches[0], selected); err == nil {
+			t.Errorf("hunks %v were staged", selected)
+		}
+	}
+	if err := r.StageHunks(patches[0], []int{1}); err != nil {
+		t.Fatal(err)
+	}
+	if staged := stagedContent(t, r, "a"); staged != "first\n"+old+"LAST\n" {
+		t.Errorf("staged content is %q", staged)
+	}
+	// the patch was computed against the index before the last hunk
+	if err := r.StageHunks(patches[0], []int{0}); !errors.Is(err, ErrIndexChanged) {
+		t.Errorf("staging a stale patch returned %v", err)
+	}
+
+	patches, err = r.UnstagedPatches([]string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patches) != 1 || len(patches[0].Hunks) != 1 {
+		t.Fatalf("patches are %+v", patches)
+	}
+	if err := r.StageHunks(patches[0], []int{0}); err != nil {
+		t.Fatal(err)
+	}
+	if staged := stagedContent(t, r, "a"); staged != worktree {
+		t.Errorf("staged content is %q", staged)
+	}
+	if patches, err := r.UnstagedPatches(nil); err != nil || len(patches) != 0 {
+		t.Errorf("patches left are %+v, %v", patches, err)
+	}
+}
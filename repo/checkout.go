@@ -0,0 +1,143 @@
+package repo
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidPath is returned for tree entries that could write outside the
+// working tree or into the repository, like .. or .git.
+var ErrInvalidPath = errors.New("invalid path")
+
+// verifyEntryName checks the name of a tree entry before it is created in
+// the working tree. Names come from other repositories on clone and fetch,
+// so they must not be able to climb out of their directory or touch .git.
+func verifyEntryName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.EqualFold(name, ".git") || strings.ContainsAny(name, "/\x00") {
+		return fmt.Errorf("%w '%s'", ErrInvalidPath, name)
+	}
+	return nil
+}
+
+// verifyPath checks every component of a slash separated path like
+// verifyEntryName.
+func verifyPath(name string) error {
+	for _, component := range strings.Split(name, "/") {
+		if err := verifyEntryName(component); err != nil {
+			return fmt.Errorf("%w '%s'", ErrInvalidPath, name)
+		}
+	}
+	return nil
+}
+
+// checkoutTree writes the files of tree into the working tree and makes
+// the index match it. Files that are not in tree are left alone.
+func (r *Repository) checkoutTree(tree string) error {
+	if r.IsBare() {
+		return ErrBareRepository
+	}
+	lock, err := r.lockIndex()
+	if err != nil {
+		return err
+	}
+	defer lock.Rollback()
+	entries := []IndexEntry{}
+	if err := r.checkoutTreeAt(tree, "", &entries); err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return r.writeToIndexFile(lock, entries)
+}
+
+func (r *Repository) checkoutTreeAt(tree, prefix string, entries *[]IndexEntry) error {
+	treeEntries, err := r.ReadTree(tree)
+	if err != nil {
+		return err
+	}
+	for _, entry := range treeEntries {
+		if err := verifyEntryName(entry.Name); err != nil {
+			return err
+		}
+		name := path.Join(prefix, entry.Name)
+		fullPath := path.Join(r.workTree, name)
+		switch {
+		case entry.IsTree():
+			if err := os.MkdirAll(fullPath, 0755); err != nil {
+				return err
+			}
+			if err := r.checkoutTreeAt(entry.Hash, name, entries); err != nil {
+				return err
+			}
+			continue
+		case entry.IsSubmodule():
+			// submodules are not cloned, only their directory is created
+			if err := os.MkdirAll(fullPath, 0755); err != nil {
+				return err
+			}
+			hash, _ := hex.DecodeString(entry.Hash)
+			*entries = append(*entries, IndexEntry{Mode: entry.Mode, Sha1: hash, Path: name, Flags: len(name)})
+			continue
+		}
+
+		indexEntry, err := r.checkoutFile(name, entry.Hash, entry.Mode)
+		if err != nil {
+			return err
+		}
+		*entries = append(*entries, indexEntry)
+	}
+	return nil
+}
+
+// checkoutFile writes blob with mode to name in the working tree and
+// returns the index entry that records it.
+func (r *Repository) checkoutFile(name, blob string, mode int) (IndexEntry, error) {
+	if err := verifyPath(name); err != nil {
+		return IndexEntry{}, err
+	}
+	fullPath := path.Join(r.workTree, name)
+	if err := r.checkoutBlob(blob, mode, fullPath); err != nil {
+		return IndexEntry{}, err
+	}
+	indexEntry, err := createIndexEntry(fullPath, blob)
+	if err != nil {
+		return IndexEntry{}, err
+	}
+	indexEntry.Mode = mode
+	indexEntry.Path = name
+	indexEntry.Flags = len(name)
+	return indexEntry, nil
+}
+
+// checkoutBlob writes the contents of blob to file, as an executable file
+// or a symbolic link when mode says so.
+func (r *Repository) checkoutBlob(blob string, mode int, file string) error {
+	objectType, data, err := r.ReadObject(blob)
+	if err != nil {
+		return err
+	}
+	if objectType != "blob" {
+		return fmt.Errorf("%s is a %s, not a blob", blob, objectType)
+	}
+	if err := os.MkdirAll(path.Dir(file), 0755); err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if mode == 0o120000 {
+		return os.Symlink(string(data), file)
+	}
+	perm := os.FileMode(0644)
+	if mode&0o111 != 0 {
+		perm = 0755
+	}
+	if err := os.WriteFile(file, data, perm); err != nil {
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package repo
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CloneOptions selects what Clone copies and where.
+type CloneOptions struct {
+	URL string
+	// Directory is where the clone is created. Empty derives it from URL.
+	Directory string
+	// Bare clones without a working tree, mirroring the remote branches
+	// into refs/heads.
+	Bare bool
+	// Branch is checked out instead of the branch the remote HEAD names.
+	Branch string
+	// Origin names the remote. Empty means DefaultRemote.
+	Origin string
+
+	UserName string
+	Password string
+	// Progress receives the "remote: " messages of the server, if set.
+	Progress io.Writer
+}
+
+// CloneDirectory returns the directory a clone of url is created in when
+// none is given: the last path component without ".git", with ".git"
+// added back for bare clones.
+func CloneDirectory(url string, bare bool) string {
+	name := strings.TrimSuffix(strings.TrimRight(url, "/"), "/.git")
+	if i := strings.LastIndexAny(name, "/:"); i != -1 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSuffix(name, ".git")
+	if bare && name != "" {
+		name += ".git"
+	}
+	return name
+}
+
+// Clone creates a repository, fetches every branch and tag of the remote
+// at opts.URL into it and checks out the remote HEAD or opts.Branch. The
+// new directory is removed again if the clone fails.
+func Clone(opts CloneOptions) (*Repository, error) {
+	directory := opts.Directory
+	if directory == "" {
+		if directory = CloneDirectory(opts.URL, opts.Bare); directory == "" {
+			return nil, fmt.Errorf("cannot guess the directory name for '%s', please give one", opts.URL)
+		}
+	}
+	entries, err := os.ReadDir(directory)
+	if err == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("destination path '%s' already exists and is not an empty directory", directory)
+	}
+	created := os.IsNotExist(err)
+
+	r, err := clone(directory, opts)
+	if err != nil && created {
+		os.RemoveAll(directory)
+	}
+	return r, err
+}
+
+func clone(directory string, opts CloneOptions) (*Repository, error) {
+	origin := opts.Origin
+	if origin == "" {
+		origin = DefaultRemote
+	}
+	url := opts.URL
+	if isLocalURL(url) {
+		// relative paths would break as soon as we leave this directory
+		abs, err := filepath.Abs(url)
+		if err != nil {
+			return nil, err
+		}
+		url = abs
+	}
+	remote, userName, password := splitCredentials(url)
+	if opts.UserName != "" {
+		userName = opts.UserName
+	}
+	if opts.Password != "" {
+		password = opts.Password
+	}
+
+	var r *Repository
+	var err error
+	fetchSpec := defaultFetchRefspec(origin)
+	if opts.Bare {
+		if r, err = InitBare(directory); err != nil {
+			return nil, err
+		}
+		fetchSpec = "+refs/heads/*:refs/heads/*"
+		configFile, err := r.ConfigFile(ScopeLocal)
+		if err != nil {
+			return nil, err
+		}
+		if err := configFile.Set("remote."+origin+".url", url); err != nil {
+			return nil, err
+		}
+		if err := configFile.Save(); err != nil {
+			return nil, err
+		}
+	} else {
+		if r, err = Init(directory); err != nil {
+			return nil, err
+		}
+		if err := r.AddRemote(origin, url); err != nil {
+			return nil, err
+		}
+	}
+
+	refspecs, err := parseFetchRefspecs([]string{fetchSpec, "+refs/tags/*:refs/tags/*"})
+	if err != nil {
+		return nil, err
+	}
+	session, err := r.connectUploadPack(remote, userName, password)
+	if err != nil {
+		return nil, err
+	}
+	adv, err := session.listRefs(append(refPrefixes(refspecs), "HEAD"))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.fetchRefs(session, adv, refspecs, false, true, "clone", opts.Progress); err != nil {
+		return nil, err
+	}
+
+	branch := cloneBranch(adv)
+	if opts.Branch != "" {
+		branch = "refs/heads/" + opts.Branch
+		if _, found := adv.Lookup(branch); !found {
+			return nil, fmt.Errorf("remote branch %s not found in upstream %s", opts.Branch, origin)
+		}
+	}
+	if branch == "" {
+		// an empty repository, HEAD stays unborn
+		return r, nil
+	}
+	if err := r.WriteSymbolicRef("HEAD", branch, ""); err != nil {
+		return nil, err
+	}
+	hash, found := adv.Lookup(branch)
+	if opts.Bare || !found {
+		return r, nil
+	}
+
+	if err := r.WriteRef(branch, hash, "clone: from "+remote); err != nil {
+		return nil, err
+	}
+	name := strings.TrimPrefix(branch, "refs/heads/")
+	if head := cloneBranch(adv); head != "" {
+		tracking := "refs/remotes/" + origin + "/" + strings.TrimPrefix(head, "refs/heads/")
+		if err := r.WriteSymbolicRef("refs/remotes/"+origin+"/HEAD", tracking, ""); err != nil {
+			return nil, err
+		}
+	}
+	configFile, err := r.ConfigFile(ScopeLocal)
+	if err != nil {
+		return nil, err
+	}
+	if err := configFile.Set("branch."+name+".remote", origin); err != nil {
+		return nil, err
+	}
+	if err := configFile.Set("branch."+name+".merge", branch); err != nil {
+		return nil, err
+	}
+	if err := configFile.Save(); err != nil {
+		return nil, err
+	}
+
+	commit, err := r.ReadCommit(hash)
+	if err != nil {
+		return nil, err
+	}
+	return r, r.checkoutTree(commit.Tree)
+}
+
+// cloneBranch returns the branch the remote HEAD points to. Servers that do
+// not advertise symbolic refs get the first branch at the same commit.
+func cloneBranch(adv *Advertisement) string {
+	if target, found := adv.Symrefs["HEAD"]; found {
+		return target
+	}
+	head, found := adv.Lookup("HEAD")
+	if !found {
+		return ""
+	}
+	for _, ref := range adv.Refs {
+		if strings.HasPrefix(ref.Name, "refs/heads/") && ref.Hash == head {
+			return ref.Name
+		}
+	}
+	return ""
+}
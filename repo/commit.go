@@ -0,0 +1,204 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrNothingToCommit is returned when a commit would record the same tree
+// as its parent.
+var ErrNothingToCommit = errors.New("nothing to commit")
+
+// ErrEmptyMessage is returned for a commit without a message.
+var ErrEmptyMessage = errors.New("empty commit message")
+
+// CommitOptions configures Commit.
+type CommitOptions struct {
+	Message string
+	// All first stages the changes of every tracked file, deletions
+	// included, as AddTracked does.
+	All bool
+	// Amend replaces the commit HEAD points to instead of adding one on
+	// top of it. The new commit gets its parents and, unless Author or
+	// Date say otherwise, its author.
+	Amend bool
+	// Author is the author as "Name <email>" instead of the user.
+	Author string
+	// Date is the author date instead of now.
+	Date time.Time
+	// AllowEmpty records the commit even if it has the tree of its parent.
+	AllowEmpty bool
+}
+
+// Commit records the index as a new commit on the current branch, or on
+// HEAD itself when it is detached, and returns its hash.
+func (r *Repository) Commit(opts CommitOptions) (string, error) {
+	if strings.TrimSpace(opts.Message) == "" {
+		return "", ErrEmptyMessage
+	}
+	if opts.Author != "" && !authorPattern.MatchString(opts.Author) {
+		return "", fmt.Errorf("author '%s' is not 'Name <email>'", opts.Author)
+	}
+	currentCommit, err := r.headCommit()
+	if err != nil {
+		return "", err
+	}
+	parents := []string{}
+	now := time.Now()
+	committer, err := r.signature(now)
+	if err != nil {
+		return "", err
+	}
+	author := committer
+	if opts.Amend {
+		if currentCommit == "" {
+			return "", errors.New("there is no commit to amend")
+		}
+		amended, err := r.ReadCommit(currentCommit)
+		if err != nil {
+			return "", err
+		}
+		parents = amended.Parents
+		author = amended.Author
+	} else if currentCommit != "" {
+		parents = append(parents, currentCommit)
+	}
+	if opts.Author != "" || !opts.Date.IsZero() {
+		identity, date := splitSignature(author)
+		if opts.Author != "" {
+			identity = strings.TrimSpace(opts.Author)
+		}
+		if !opts.Date.IsZero() {
+			date = signatureDate(opts.Date)
+		}
+		author = identity + " " + date
+	}
+
+	if opts.All {
+		if err := r.AddTracked(); err != nil {
+			return "", err
+		}
+	}
+	treeHash, err := r.CreateTree()
+	if err != nil {
+		return "", err
+	}
+	if !opts.AllowEmpty && len(parents) < 2 {
+		parentTree := emptyTree
+		if len(parents) == 1 {
+			parent, err := r.ReadCommit(parents[0])
+			if err != nil {
+				return "", err
+			}
+			parentTree = parent.Tree
+		}
+		if treeHash == parentTree {
+			return "", ErrNothingToCommit
+		}
+	}
+
+	commitContent := ""
+	commitContent += fmt.Sprintf("tree %s\n", treeHash)
+	for _, parent := range parents {
+		commitContent += fmt.Sprintf("parent %s\n", parent)
+	}
+	commitContent += fmt.Sprintf("author %s\n", author)
+	commitContent += fmt.Sprintf("committer %s\n", committer)
+	commitContent += "\n"
+	commitContent += strings.TrimRight(opts.Message, "\n")
+	commitContent += "\n"
+
+	hash, err := r.WriteObject("commit", []byte(commitContent))
+	if err != nil {
+		return "", err
+	}
+	subject, _, _ := strings.Cut(strings.TrimSpace(opts.Message), "\n")
+	reflogMessage := "commit: " + subject
+	switch {
+	case opts.Amend:
+		reflogMessage = "commit (amend): " + subject
+	case currentCommit == "":
+		reflogMessage = "commit (initial): " + subject
+	}
+	// fail rather than lose a commit made meanwhile
+	expected := currentCommit
+	if expected == "" {
+		expected = zeroHash
+	}
+	if err := r.UpdateRef("HEAD", hash, expected, reflogMessage); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// emptyTree is the hash of the tree without entries.
+const emptyTree = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+// authorPattern matches identities given as "Name <email>".
+var authorPattern = regexp.MustCompile(`^\s*[^<>]*<[^<>]*>\s*$`)
+
+// splitSignature splits a signature line into the "Name <email>" identity
+// and the date after it.
+func splitSignature(signature string) (string, string) {
+	end := strings.LastIndexByte(signature, '>')
+	if end < 0 {
+		return signature, ""
+	}
+	return signature[:end+1], strings.TrimSpace(signature[end+1:])
+}
+
+// headCommit returns the commit HEAD points to, "" if its branch is
+// unborn.
+func (r *Repository) headCommit() (string, error) {
+	hash, err := r.ReadRef("HEAD")
+	if errors.Is(err, ErrRefNotFound) {
+		return "", nil
+	}
+	return hash, err
+}
+
+// CommitObject is a parsed commit object.
+type CommitObject struct {
+	Tree      string
+	Parents   []string
+	Author    string
+	Committer string
+	Message   string
+}
+
+// ReadCommit reads and parses a commit object.
+func (r *Repository) ReadCommit(commit string) (*CommitObject, error) {
+	objectType, contents, err := r.ReadObject(commit)
+	if err != nil {
+		return nil, err
+	}
+	if objectType != "commit" {
+		return nil, fmt.Errorf("invalid commit object type %v: %v", commit, objectType)
+	}
+	return parseCommit(contents)
+}
+
+func parseCommit(contents []byte) (*CommitObject, error) {
+	header, message, _ := strings.Cut(string(contents), "\n\n")
+	commit := &CommitObject{Message: message}
+	for _, line := range strings.Split(header, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "tree":
+			commit.Tree = value
+		case "parent":
+			commit.Parents = append(commit.Parents, value)
+		case "author":
+			commit.Author = value
+		case "committer":
+			commit.Committer = value
+		}
+	}
+	if commit.Tree == "" {
+		return nil, fmt.Errorf("invalid commit object: missing tree")
+	}
+	return commit, nil
+}
@@ -0,0 +1,131 @@
+package repo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommitAll(t *testing.T) {
+	r := initTestRepo(t)
+	commitFile(t, r, "a", "1\n")
+	commitFile(t, r, "b", "1\n")
+	if err := os.WriteFile(filepath.Join(r.WorkTree(), "a"), []byte("2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(r.WorkTree(), "b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(r.WorkTree(), "new"), []byte("new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Commit(CommitOptions{Message: "all", All: true}); err != nil {
+		t.Fatal(err)
+	}
+	tree, err := r.ResolveRevision("HEAD^{tree}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := r.ListTree(tree, ListTreeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Path != "a" {
+		t.Errorf("the commit has %+v, want only the modified a", files)
+	}
+	if blob, _ := r.ResolveRevision("HEAD:a"); blob != HashObject(strings.NewReader("2\n"), "blob", 2) {
+		t.Errorf("a was committed as %s", blob)
+	}
+	if got := indexPaths(t, r); got != "a" {
+		t.Errorf("the index has %s, the untracked file must stay out", got)
+	}
+}
+
+func TestCommitAmend(t *testing.T) {
+	r := initTestRepo(t)
+	if _, err := r.Commit(CommitOptions{Message: "nothing", Amend: true, AllowEmpty: true}); err == nil {
+		t.Error("amending on an unborn branch succeeded")
+	}
+	first := commitFile(t, r, "a", "1\n")
+	t.Setenv("GOGIT_USERNAME", "Original Author")
+	second := commitFile(t, r, "a", "2\n")
+	original, err := r.ReadCommit(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GOGIT_USERNAME", "Test User")
+
+	amended, err := r.Commit(CommitOptions{Message: "reworded\n", Amend: true, AllowEmpty: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := r.ReadCommit(amended)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commit.Parents) != 1 || commit.Parents[0] != first || commit.Tree != original.Tree {
+		t.Errorf("the amended commit has parents %v and tree %s", commit.Parents, commit.Tree)
+	}
+	if commit.Author != original.Author || !strings.HasPrefix(commit.Committer, "Test User ") || commit.Message != "reworded\n" {
+		t.Errorf("the amended commit is %+v", commit)
+	}
+	entries, err := r.ReadReflog("refs/heads/master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := entries[len(entries)-1]; last.Old != second || last.New != amended || last.Message != "commit (amend): reworded" {
+		t.Errorf("the amend is logged as %+v", last)
+	}
+}
+
+func TestCommitAuthorAndDate(t *testing.T) {
+	r := initTestRepo(t)
+	commitFile(t, r, "a", "1\n")
+	date := time.Date(2005, 4, 7, 22, 13, 13, 0, time.FixedZone("", 2*60*60))
+
+	for _, test := range []struct {
+		opts   CommitOptions
+		author string
+	}{
+		{CommitOptions{Author: "Other <other@example.com>"}, "Other <other@example.com> "},
+		{CommitOptions{Date: date}, "Test User <test@example.com> 1112904793 +0200"},
+		{CommitOptions{Author: " Both <both@example.com> ", Date: date}, "Both <both@example.com> 1112904793 +0200"},
+	} {
+		test.opts.Message = "empty"
+		test.opts.AllowEmpty = true
+		hash, err := r.Commit(test.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		commit, err := r.ReadCommit(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(commit.Author, test.author) {
+			t.Errorf("with %+v the author is %s", test.opts, commit.Author)
+		}
+		if !strings.HasPrefix(commit.Committer, "Test User <test@example.com> ") || strings.HasSuffix(commit.Committer, "1112904793 +0200") {
+			t.Errorf("with %+v the committer is %s", test.opts, commit.Committer)
+		}
+	}
+
+	head, _ := r.ReadRef("HEAD")
+	for _, author := range []string{"nobody", "a <b> <c>", "<>x"} {
+		if _, err := r.Commit(CommitOptions{Message: "bad", Author: author, AllowEmpty: true}); err == nil {
+			t.Errorf("the author %q was accepted", author)
+		}
+	}
+	if _, err := r.Commit(CommitOptions{Message: "empty"}); !errors.Is(err, ErrNothingToCommit) {
+		t.Errorf("an empty commit without AllowEmpty returned %v", err)
+	}
+	if _, err := r.Commit(CommitOptions{Message: " \n", AllowEmpty: true}); !errors.Is(err, ErrEmptyMessage) {
+		t.Errorf("a commit with an empty message returned %v", err)
+	}
+	if hash, _ := r.ReadRef("HEAD"); hash != head {
+		t.Error("a refused commit moved HEAD")
+	}
+}
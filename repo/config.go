@@ -0,0 +1,715 @@
+package repo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ConfigScope selects one of the config files git reads.
+type ConfigScope int
+
+const (
+	ScopeSystem ConfigScope = iota
+	ScopeGlobal
+	ScopeLocal
+)
+
+// maxIncludeDepth bounds include.path recursion, as git does.
+const maxIncludeDepth = 10
+
+// ErrMultipleValues is returned when a single value is expected for a key
+// that has several.
+var ErrMultipleValues = errors.New("cannot overwrite multiple values with a single value")
+
+// ConfigEntry is one key = value line of a config file.
+type ConfigEntry struct {
+	// Section and Key are lower case, Subsection keeps its case.
+	Section    string
+	Subsection string
+	Key        string
+	Value      string
+	// Origin is the file the entry was read from.
+	Origin string
+	Scope  ConfigScope
+}
+
+// Name returns the dotted name of the entry, e.g. remote.origin.url.
+func (entry ConfigEntry) Name() string {
+	if entry.Subsection != "" {
+		return entry.Section + "." + entry.Subsection + "." + entry.Key
+	}
+	return entry.Section + "." + entry.Key
+}
+
+func (entry ConfigEntry) matches(section, subsection, key string) bool {
+	return entry.Section == section && entry.Subsection == subsection && (key == "" || entry.Key == key)
+}
+
+// Config is the merged view of the system, global and local config files,
+// with later entries taking precedence over earlier ones.
+type Config struct {
+	entries []ConfigEntry
+}
+
+// Config reads the configuration of the repository.
+func (r *Repository) Config() (*Config, error) {
+	return ReadConfig(r.gitDir)
+}
+
+// ReadConfig reads the system, global and local configuration. gitDir may be
+// empty to read the configuration outside of a repository.
+func ReadConfig(gitDir string) (*Config, error) {
+	config := &Config{}
+	for _, scope := range []ConfigScope{ScopeSystem, ScopeGlobal, ScopeLocal} {
+		if scope == ScopeLocal && gitDir == "" {
+			continue
+		}
+		for _, file := range configPaths(scope, gitDir) {
+			if err := config.include(file, scope, gitDir, 0); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return config, nil
+}
+
+// include appends the entries of file, expanding include and includeIf.
+func (c *Config) include(file string, scope ConfigScope, gitDir string, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("exceeded maximum include depth (%d) while including %s", maxIncludeDepth, file)
+	}
+	configFile, err := ReadConfigFile(file)
+	if err != nil {
+		return err
+	}
+	for _, entry := range configFile.Entries() {
+		entry.Scope = scope
+		c.entries = append(c.entries, entry)
+		if entry.Key != "path" || !includeApplies(entry, gitDir) {
+			continue
+		}
+		target := expandHome(entry.Value)
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(file), target)
+		}
+		if err := c.include(target, scope, gitDir, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// includeApplies reports whether entry is an include.path, or an
+// includeIf.<condition>.path whose condition holds for gitDir.
+func includeApplies(entry ConfigEntry, gitDir string) bool {
+	if entry.Section == "include" && entry.Subsection == "" {
+		return true
+	}
+	if entry.Section != "includeif" {
+		return false
+	}
+	condition := entry.Subsection
+	foldCase := false
+	pattern, found := strings.CutPrefix(condition, "gitdir:")
+	if !found {
+		if pattern, found = strings.CutPrefix(condition, "gitdir/i:"); !found {
+			return false
+		}
+		foldCase = true
+	}
+	if gitDir == "" {
+		return false
+	}
+	pattern = expandHome(pattern)
+	if !filepath.IsAbs(pattern) && !strings.HasPrefix(pattern, "**/") {
+		pattern = "**/" + pattern
+	}
+	gitDir = filepath.ToSlash(gitDir)
+	if foldCase {
+		pattern, gitDir = strings.ToLower(pattern), strings.ToLower(gitDir)
+	}
+	return matchGitDir(pattern, gitDir)
+}
+
+// matchGitDir matches a gitdir: pattern, where "**/" matches any number of
+// leading directories and a trailing "/" matches everything below it.
+func matchGitDir(pattern, gitDir string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			expr.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			expr.WriteString("[^/]*")
+		case pattern[i] == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	expr.WriteString("$")
+	matched, _ := regexp.MatchString(expr.String(), strings.TrimSuffix(gitDir, "/"))
+	return matched
+}
+
+// configPaths returns the files read for scope, lowest precedence first.
+func configPaths(scope ConfigScope, gitDir string) []string {
+	switch scope {
+	case ScopeSystem:
+		if os.Getenv("GIT_CONFIG_NOSYSTEM") != "" {
+			return nil
+		}
+		if file := os.Getenv("GIT_CONFIG_SYSTEM"); file != "" {
+			return []string{file}
+		}
+		return []string{"/etc/gitconfig"}
+	case ScopeGlobal:
+		if file := os.Getenv("GIT_CONFIG_GLOBAL"); file != "" {
+			return []string{file}
+		}
+		files := []string{}
+		xdg := os.Getenv("XDG_CONFIG_HOME")
+		if xdg == "" {
+			if home, err := os.UserHomeDir(); err == nil {
+				xdg = filepath.Join(home, ".config")
+			}
+		}
+		if xdg != "" {
+			files = append(files, filepath.Join(xdg, "git", "config"))
+		}
+		if home, err := os.UserHomeDir(); err == nil {
+			files = append(files, filepath.Join(home, ".gitconfig"))
+		}
+		return files
+	default:
+		return []string{filepath.Join(commonGitDir(gitDir), "config")}
+	}
+}
+
+// ConfigFile opens the config file written to for scope.
+func (r *Repository) ConfigFile(scope ConfigScope) (*ConfigFile, error) {
+	return OpenConfigFile(scope, r.gitDir)
+}
+
+// OpenConfigFile opens the config file written to for scope. gitDir is only
+// needed for the local scope.
+func OpenConfigFile(scope ConfigScope, gitDir string) (*ConfigFile, error) {
+	if scope == ScopeLocal && gitDir == "" {
+		return nil, errors.New("--local can only be used inside a git repository")
+	}
+	files := configPaths(scope, gitDir)
+	if len(files) == 0 {
+		return nil, errors.New("no config file for this scope")
+	}
+	return ReadConfigFile(files[len(files)-1])
+}
+
+// Get returns the value of key with the highest precedence.
+func (c *Config) Get(key string) (string, bool) {
+	values := c.GetAll(key)
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[len(values)-1], true
+}
+
+// GetAll returns every value of key in order of precedence, lowest first.
+func (c *Config) GetAll(key string) []string {
+	section, subsection, name, err := parseConfigKey(key)
+	if err != nil {
+		return nil
+	}
+	values := []string{}
+	for _, entry := range c.entries {
+		if entry.matches(section, subsection, name) {
+			values = append(values, entry.Value)
+		}
+	}
+	return values
+}
+
+// GetBool returns the value of key interpreted as a boolean, or fallback if
+// it is not set or not a boolean. A key without "=" is true, an empty value
+// false.
+func (c *Config) GetBool(key string, fallback bool) bool {
+	value, ok := c.Get(key)
+	if !ok {
+		return fallback
+	}
+	if b, ok := parseConfigBool(value); ok {
+		return b
+	}
+	return fallback
+}
+
+// parseConfigBool interprets a boolean config value and reports whether it
+// is one. Keys without "=" are read as "true".
+func parseConfigBool(value string) (bool, bool) {
+	switch strings.ToLower(value) {
+	case "true", "yes", "on", "1":
+		return true, true
+	case "false", "no", "off", "0", "":
+		return false, true
+	}
+	return false, false
+}
+
+// Subsections returns the distinct subsections of section in file order.
+func (c *Config) Subsections(section string) []string {
+	section = strings.ToLower(section)
+	seen := map[string]bool{}
+	subsections := []string{}
+	for _, entry := range c.entries {
+		if entry.Section == section && entry.Subsection != "" && !seen[entry.Subsection] {
+			seen[entry.Subsection] = true
+			subsections = append(subsections, entry.Subsection)
+		}
+	}
+	return subsections
+}
+
+// Entries returns every entry in order of precedence, lowest first.
+func (c *Config) Entries() []ConfigEntry {
+	return c.entries
+}
+
+// ConfigFile is a single config file that can be edited and saved. It keeps
+// the lines it read, so comments and formatting survive edits, and only the
+// lines of changed entries are written anew.
+type ConfigFile struct {
+	Path  string
+	lines []configLine
+}
+
+// configLine is one line of a config file, or several joined by trailing
+// backslashes.
+type configLine struct {
+	text string
+	// section and subsection are those the line belongs to, so comments go
+	// with their section.
+	section    string
+	subsection string
+	// header is the [section] part of text if the line starts a section.
+	header string
+	// entry is the key = value of the line, if any.
+	entry *ConfigEntry
+}
+
+// ReadConfigFile parses the config file at path. A missing file is empty.
+func ReadConfigFile(path string) (*ConfigFile, error) {
+	configFile := &ConfigFile{Path: path}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return configFile, nil
+		}
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := configFile.parse(data); err != nil {
+		return nil, err
+	}
+	return configFile, nil
+}
+
+func (f *ConfigFile) parse(data []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	section, subsection := "", ""
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		text := scanner.Text()
+		line := strings.TrimSpace(text)
+		// join continuation lines
+		for strings.HasSuffix(line, "\\") && !strings.HasSuffix(line, "\\\\") && scanner.Scan() {
+			lineNumber++
+			text += "\n" + scanner.Text()
+			line = strings.TrimSuffix(line, "\\") + scanner.Text()
+		}
+		parsed := configLine{text: text}
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			parsed.section, parsed.subsection = section, subsection
+			f.lines = append(f.lines, parsed)
+			continue
+		}
+
+		if line[0] == '[' {
+			end := strings.LastIndex(line, "]")
+			if end == -1 {
+				return fmt.Errorf("bad config line %d in file %s", lineNumber, f.Path)
+			}
+			var err error
+			section, subsection, err = parseSectionHeader(line[1:end])
+			if err != nil {
+				return fmt.Errorf("bad config line %d in file %s", lineNumber, f.Path)
+			}
+			parsed.header = text[:strings.Index(text, "]")+1]
+			line = strings.TrimSpace(line[end+1:])
+		}
+		parsed.section, parsed.subsection = section, subsection
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			f.lines = append(f.lines, parsed)
+			continue
+		}
+
+		if section == "" {
+			return fmt.Errorf("bad config line %d in file %s", lineNumber, f.Path)
+		}
+		key, rawValue, hasValue := strings.Cut(line, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !validConfigName(key) {
+			return fmt.Errorf("bad config line %d in file %s", lineNumber, f.Path)
+		}
+		value := "true"
+		if hasValue {
+			var err error
+			if value, err = parseConfigValue(rawValue); err != nil {
+				return fmt.Errorf("bad config line %d in file %s", lineNumber, f.Path)
+			}
+		}
+		parsed.entry = &ConfigEntry{
+			Section:    section,
+			Subsection: subsection,
+			Key:        key,
+			Value:      value,
+			Origin:     f.Path,
+		}
+		f.lines = append(f.lines, parsed)
+	}
+	return scanner.Err()
+}
+
+// parseSectionHeader parses the inside of [section "subsection"] or the
+// legacy [section.subsection] form.
+func parseSectionHeader(header string) (string, string, error) {
+	name, rest, hasSubsection := strings.Cut(strings.TrimSpace(header), " ")
+	if !hasSubsection {
+		section, subsection, _ := strings.Cut(name, ".")
+		if !validConfigName(section) {
+			return "", "", errors.New("invalid section name")
+		}
+		return strings.ToLower(section), strings.ToLower(subsection), nil
+	}
+	if !validConfigName(name) {
+		return "", "", errors.New("invalid section name")
+	}
+	rest = strings.TrimSpace(rest)
+	if len(rest) < 2 || rest[0] != '"' || rest[len(rest)-1] != '"' {
+		return "", "", errors.New("invalid subsection")
+	}
+	var subsection strings.Builder
+	for i := 1; i < len(rest)-1; i++ {
+		if rest[i] == '\\' && i+1 < len(rest)-1 {
+			i++
+		}
+		subsection.WriteByte(rest[i])
+	}
+	return strings.ToLower(name), subsection.String(), nil
+}
+
+// parseConfigValue unquotes a value, handling escapes and inline comments.
+func parseConfigValue(raw string) (string, error) {
+	var value strings.Builder
+	inQuotes := false
+	pendingSpace := ""
+	raw = strings.TrimSpace(raw)
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case c == '"':
+			inQuotes = !inQuotes
+		case c == '\\':
+			if i+1 >= len(raw) {
+				return "", errors.New("trailing backslash")
+			}
+			i++
+			value.WriteString(pendingSpace)
+			pendingSpace = ""
+			switch raw[i] {
+			case 'n':
+				value.WriteByte('\n')
+			case 't':
+				value.WriteByte('\t')
+			case 'b':
+				value.WriteByte('\b')
+			case '\\', '"':
+				value.WriteByte(raw[i])
+			default:
+				return "", errors.New("invalid escape")
+			}
+		case (c == '#' || c == ';') && !inQuotes:
+			return value.String(), nil
+		case (c == ' ' || c == '\t') && !inQuotes:
+			// collapse unquoted whitespace, dropping it at the end
+			pendingSpace += string(c)
+		default:
+			value.WriteString(pendingSpace)
+			pendingSpace = ""
+			value.WriteByte(c)
+		}
+	}
+	if inQuotes {
+		return "", errors.New("unterminated quote")
+	}
+	return value.String(), nil
+}
+
+func validConfigName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if !(c == '-' || c == '.' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseConfigKey splits section.subsection.key into its parts.
+func parseConfigKey(key string) (string, string, string, error) {
+	first := strings.Index(key, ".")
+	last := strings.LastIndex(key, ".")
+	if first <= 0 || last == len(key)-1 {
+		return "", "", "", fmt.Errorf("key does not contain a section: %s", key)
+	}
+	section := strings.ToLower(key[:first])
+	name := strings.ToLower(key[last+1:])
+	subsection := ""
+	if first != last {
+		subsection = key[first+1 : last]
+	}
+	if !validConfigName(section) || !validConfigName(name) || strings.Contains(name, ".") {
+		return "", "", "", fmt.Errorf("invalid key: %s", key)
+	}
+	return section, subsection, name, nil
+}
+
+// Get returns the last value of key in the file.
+func (f *ConfigFile) Get(key string) (string, bool) {
+	config := Config{entries: f.Entries()}
+	return config.Get(key)
+}
+
+// GetAll returns every value of key in the file.
+func (f *ConfigFile) GetAll(key string) []string {
+	config := Config{entries: f.Entries()}
+	return config.GetAll(key)
+}
+
+// Entries returns the entries of the file in order.
+func (f *ConfigFile) Entries() []ConfigEntry {
+	entries := []ConfigEntry{}
+	for _, line := range f.lines {
+		if line.entry != nil {
+			entries = append(entries, *line.entry)
+		}
+	}
+	return entries
+}
+
+// Set replaces the value of key, adding it if it is not set yet. It fails
+// if key has more than one value.
+func (f *ConfigFile) Set(key, value string) error {
+	section, subsection, name, err := parseConfigKey(key)
+	if err != nil {
+		return err
+	}
+	index := -1
+	for i, line := range f.lines {
+		if line.entry != nil && line.entry.matches(section, subsection, name) {
+			if index != -1 {
+				return fmt.Errorf("%w: %s", ErrMultipleValues, key)
+			}
+			index = i
+		}
+	}
+	if index == -1 {
+		return f.Add(key, value)
+	}
+	f.lines[index].entry.Value = value
+	f.lines[index].render()
+	return nil
+}
+
+// Add appends a value for key, keeping any values it already has.
+func (f *ConfigFile) Add(key, value string) error {
+	section, subsection, name, err := parseConfigKey(key)
+	if err != nil {
+		return err
+	}
+	entry := configLine{
+		section:    section,
+		subsection: subsection,
+		entry:      &ConfigEntry{Section: section, Subsection: subsection, Key: name, Value: value, Origin: f.Path},
+	}
+	entry.render()
+
+	// insert after the last entry of the section, or its header if it has
+	// none, and start the section at the end if there is no header for it
+	index := -1
+	for i, line := range f.lines {
+		if (line.header != "" || line.entry != nil) && line.section == section && line.subsection == subsection {
+			index = i
+		}
+	}
+	if index == -1 {
+		header := configLine{section: section, subsection: subsection, header: sectionHeader(section, subsection)}
+		header.text = header.header
+		f.lines = append(f.lines, header, entry)
+		return nil
+	}
+	f.lines = append(f.lines[:index+1], append([]configLine{entry}, f.lines[index+1:]...)...)
+	return nil
+}
+
+// Unset removes key. It fails if key has more than one value.
+func (f *ConfigFile) Unset(key string) error {
+	section, subsection, name, err := parseConfigKey(key)
+	if err != nil {
+		return err
+	}
+	count := 0
+	for _, entry := range f.Entries() {
+		if entry.matches(section, subsection, name) {
+			count++
+		}
+	}
+	if count > 1 {
+		return fmt.Errorf("%w: %s", ErrMultipleValues, key)
+	}
+	_, err = f.UnsetAll(key)
+	return err
+}
+
+// UnsetAll removes every value of key and returns how many were removed.
+func (f *ConfigFile) UnsetAll(key string) (int, error) {
+	section, subsection, name, err := parseConfigKey(key)
+	if err != nil {
+		return 0, err
+	}
+	lines := f.lines[:0]
+	removed := 0
+	for _, line := range f.lines {
+		if line.entry != nil && line.entry.matches(section, subsection, name) {
+			removed++
+			if line.header == "" {
+				continue
+			}
+			// keep the section an inline entry started
+			line.entry = nil
+			line.render()
+		}
+		lines = append(lines, line)
+	}
+	f.lines = lines
+	return removed, nil
+}
+
+// RemoveSection removes section.subsection with every key and comment in
+// it, and reports whether it existed.
+func (f *ConfigFile) RemoveSection(section, subsection string) bool {
+	section = strings.ToLower(section)
+	lines := f.lines[:0]
+	removed := false
+	for _, line := range f.lines {
+		if line.section == section && line.subsection == subsection {
+			removed = true
+			continue
+		}
+		lines = append(lines, line)
+	}
+	f.lines = lines
+	return removed
+}
+
+// RenameSection moves the keys of section.oldName to section.newName and
+// reports whether there were any.
+func (f *ConfigFile) RenameSection(section, oldName, newName string) bool {
+	section = strings.ToLower(section)
+	renamed := false
+	for i := range f.lines {
+		line := &f.lines[i]
+		if line.section != section || line.subsection != oldName {
+			continue
+		}
+		line.subsection = newName
+		if line.entry != nil {
+			line.entry.Subsection = newName
+		}
+		if line.header != "" {
+			header := sectionHeader(section, newName)
+			line.text = header + strings.TrimPrefix(line.text, line.header)
+			line.header = header
+			renamed = true
+		}
+	}
+	return renamed
+}
+
+// render writes the text of an edited line anew.
+func (line *configLine) render() {
+	text := line.header
+	if line.entry != nil {
+		if text != "" {
+			text += "\n"
+		}
+		text += fmt.Sprintf("\t%s = %s", line.entry.Key, quoteConfigValue(line.entry.Value))
+	}
+	line.text = text
+}
+
+// sectionHeader returns the [section "subsection"] line starting a section.
+func sectionHeader(section, subsection string) string {
+	if subsection == "" {
+		return "[" + section + "]"
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(subsection)
+	return fmt.Sprintf("[%s \"%s\"]", section, escaped)
+}
+
+// Bytes serializes the file in git config format.
+func (f *ConfigFile) Bytes() []byte {
+	var buf bytes.Buffer
+	for _, line := range f.lines {
+		buf.WriteString(line.text)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// Save writes the file back to disk through its lock file, so concurrent
+// writers fail instead of overwriting each other.
+func (f *ConfigFile) Save() error {
+	return writeFileLocked(f.Path, f.Bytes())
+}
+
+func quoteConfigValue(value string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\b", `\b`).Replace(value)
+	if value != strings.TrimSpace(value) || strings.ContainsAny(value, "#;") {
+		return `"` + escaped + `"`
+	}
+	return escaped
+}
+
+func expandHome(path string) string {
+	if rest, found := strings.CutPrefix(path, "~/"); found {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
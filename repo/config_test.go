@@ -0,0 +1,205 @@
+package repo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func readConfig(t *testing.T, file string) *ConfigFile {
+	t.Helper()
+	config, err := ReadConfigFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func TestConfigFileKeepsComments(t *testing.T) {
+	file := writeConfig(t, "# top\n[core] ; header\n\tbare = false # inline\n\t# about b\n\n[b]\n\tk = 1\n[a \"x\"]\n\tj = 2\n")
+	config := readConfig(t, file)
+	if err := config.Set("b.k", "5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Add("b.new", "two words "); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Set("c.z", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Save(); err != nil {
+		t.Fatal(err)
+	}
+	want := "# top\n[core] ; header\n\tbare = false # inline\n\t# about b\n\n[b]\n\tk = 5\n\tnew = \"two words \"\n[a \"x\"]\n\tj = 2\n[c]\n\tz = 1\n"
+	if data, _ := os.ReadFile(file); string(data) != want {
+		t.Errorf("config is\n%s\nwant\n%s", data, want)
+	}
+
+	config = readConfig(t, file)
+	if value, _ := config.Get("b.new"); value != "two words " {
+		t.Errorf("b.new is %q", value)
+	}
+	if _, err := config.UnsetAll("core.bare"); err != nil {
+		t.Fatal(err)
+	}
+	if !config.RenameSection("a", "x", "y") {
+		t.Error("a.x was not renamed")
+	}
+	if !config.RemoveSection("b", "") {
+		t.Error("b was not removed")
+	}
+	if config.RemoveSection("b", "") {
+		t.Error("b was removed twice")
+	}
+	want = "# top\n[core] ; header\n\t# about b\n\n[a \"y\"]\n\tj = 2\n[c]\n\tz = 1\n"
+	if data := config.Bytes(); string(data) != want {
+		t.Errorf("config is\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestConfigFileInlineEntry(t *testing.T) {
+	config := readConfig(t, writeConfig(t, "[core] bare = true\n[remote \"o\"] url = x\n"))
+	if err := config.Set("core.bare", "false"); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Unset("remote.o.url"); err != nil {
+		t.Fatal(err)
+	}
+	want := "[core]\n\tbare = false\n[remote \"o\"]\n"
+	if data := config.Bytes(); string(data) != want {
+		t.Errorf("config is\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestConfigFileMultipleValues(t *testing.T) {
+	config := readConfig(t, writeConfig(t, "[remote \"o\"]\n\tfetch = a\n\tfetch = b\n"))
+	for name, err := range map[string]error{
+		"Set":   config.Set("remote.o.fetch", "c"),
+		"Unset": config.Unset("remote.o.fetch"),
+	} {
+		if err == nil {
+			t.Errorf("%s of a multi-valued key succeeded", name)
+		}
+	}
+	if err := config.Add("remote.o.fetch", "c"); err != nil {
+		t.Fatal(err)
+	}
+	if values := config.GetAll("remote.o.fetch"); len(values) != 3 || values[2] != "c" {
+		t.Errorf("values are %v", values)
+	}
+	if removed, err := config.UnsetAll("remote.o.fetch"); err != nil || removed != 3 {
+		t.Errorf("UnsetAll removed %d, %v", removed, err)
+	}
+}
+
+func TestConfigFileParse(t *testing.T) {
+	config := readConfig(t, writeConfig(t, "[Section \"Sub \\\"q\\\"\"]\n\tKey = a\\\n  b ; comment\n\tquoted = \" x#y \"\n\tescaped = a\\tb\n[old.Sub]\n\tk = v\n"))
+	for key, want := range map[string]string{
+		"section.Sub \"q\".key":     "a  b",
+		"section.Sub \"q\".quoted":  " x#y ",
+		"section.Sub \"q\".escaped": "a\tb",
+		"old.sub.k":                 "v",
+	} {
+		if value, _ := config.Get(key); value != want {
+			t.Errorf("%s is %q, want %q", key, value, want)
+		}
+	}
+
+	for _, bad := range []string{"key = value\n", "[section\n", "[s]\n\tbad key = 1\n", "[s]\n\tk = \"open\n", "[s]\n\tk = \\q\n"} {
+		if _, err := ReadConfigFile(writeConfig(t, bad)); err == nil {
+			t.Errorf("%q was parsed", bad)
+		}
+	}
+}
+
+func TestConfigGetBool(t *testing.T) {
+	file := writeConfig(t, "[b]\n\tbare\n\tempty =\n\tyes = yes\n\toff = off\n\tone = 1\n\tother = maybe\n")
+	config := &Config{entries: readConfig(t, file).Entries()}
+	for key, want := range map[string]bool{
+		"b.bare":    true,
+		"b.empty":   false,
+		"b.yes":     true,
+		"b.off":     false,
+		"b.one":     true,
+		"b.other":   true,
+		"b.missing": true,
+	} {
+		if got := config.GetBool(key, true); got != want {
+			t.Errorf("%s is %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestReadConfigIncludes(t *testing.T) {
+	setupTestEnv(t)
+	dir := t.TempDir()
+	gitDir := filepath.Join(dir, "work", ".git")
+	files := map[string]string{
+		"included":  "[user]\n\tname = Included\n",
+		"matched":   "[user]\n\temail = matched@example.com\n",
+		"unmatched": "[user]\n\temail = unmatched@example.com\n",
+		"loop":      "[include]\n\tpath = loop\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	global := filepath.Join(dir, "global")
+	data := "[user]\n\tname = Global\n[include]\n\tpath = included\n" +
+		"[includeIf \"gitdir:work/\"]\n\tpath = matched\n" +
+		"[includeIf \"gitdir:other/\"]\n\tpath = unmatched\n"
+	if err := os.WriteFile(global, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", global)
+
+	config, err := ReadConfig(gitDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{"user.name": "Included", "user.email": "matched@example.com"} {
+		if value, _ := config.Get(key); value != want {
+			t.Errorf("%s is %q, want %q", key, value, want)
+		}
+	}
+
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(dir, "loop"))
+	if _, err := ReadConfig(gitDir); err == nil {
+		t.Error("an include loop was read")
+	}
+}
+
+func TestConfigFileSaveLocked(t *testing.T) {
+	file := writeConfig(t, "[core]\n\tbare = false\n")
+	config := readConfig(t, file)
+	if err := config.Set("core.bare", "true"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file+".lock", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Save(); !errors.Is(err, ErrLocked) {
+		t.Errorf("saving a locked config returned %v", err)
+	}
+	os.Remove(file + ".lock")
+	if err := config.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := readConfig(t, file).Get("core.bare"); value != "true" {
+		t.Errorf("core.bare is %s", value)
+	}
+	if _, err := os.Stat(file + ".lock"); err == nil {
+		t.Error("the lock file was left behind")
+	}
+}
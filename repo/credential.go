@@ -0,0 +1,380 @@
+package repo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Credential is what the git credential protocol exchanges with helpers:
+// the server it is for and the user name and password to use there.
+type Credential struct {
+	Protocol string
+	Host     string
+	// Path is only kept when credential.useHttpPath is set.
+	Path     string
+	UserName string
+	Password string
+	// quit is set by a helper telling us to stop looking.
+	quit bool
+}
+
+// CredentialForURL returns the credential for remote with the user name
+// and password it contains, if any.
+func CredentialForURL(remote string) (*Credential, error) {
+	parsed, err := url.Parse(remote)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL '%s': %w", remote, err)
+	}
+	c := &Credential{Protocol: parsed.Scheme, Host: parsed.Host, Path: strings.Trim(parsed.Path, "/")}
+	if parsed.User != nil {
+		c.UserName = parsed.User.Username()
+		c.Password, _ = parsed.User.Password()
+	}
+	return c, nil
+}
+
+// ParseCredential reads key=value lines up to an empty line or the end of
+// reader. A url key sets every part of the credential it contains.
+func ParseCredential(reader io.Reader) (*Credential, error) {
+	c := &Credential{}
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid credential line: %s", line)
+		}
+		switch key {
+		case "protocol":
+			c.Protocol = value
+		case "host":
+			c.Host = value
+		case "path":
+			c.Path = value
+		case "username":
+			c.UserName = value
+		case "password":
+			c.Password = value
+		case "quit":
+			c.quit, _ = parseConfigBool(value)
+		case "url":
+			parsed, err := CredentialForURL(value)
+			if err != nil {
+				return nil, err
+			}
+			*c = *parsed
+		}
+	}
+	return c, scanner.Err()
+}
+
+// Encode writes the credential as key=value lines, leaving out empty
+// fields.
+func (c *Credential) Encode(w io.Writer) error {
+	var buffer bytes.Buffer
+	for _, field := range [][2]string{
+		{"protocol", c.Protocol}, {"host", c.Host}, {"path", c.Path},
+		{"username", c.UserName}, {"password", c.Password},
+	} {
+		if field[1] == "" {
+			continue
+		}
+		if strings.ContainsAny(field[1], "\n\x00") {
+			return fmt.Errorf("credential %s contains a newline or NUL", field[0])
+		}
+		buffer.WriteString(field[0] + "=" + field[1] + "\n")
+	}
+	_, err := w.Write(buffer.Bytes())
+	return err
+}
+
+// URL returns protocol://host/path, without the user name.
+func (c *Credential) URL() string {
+	u := url.URL{Scheme: c.Protocol, Host: c.Host, Path: c.Path}
+	if c.Path != "" {
+		u.Path = "/" + c.Path
+	}
+	return u.String()
+}
+
+// matches reports whether other fits every field set in c but the
+// password, which is how helpers look credentials up.
+func (c *Credential) matches(other *Credential) bool {
+	return (c.Protocol == "" || c.Protocol == other.Protocol) &&
+		(c.Host == "" || c.Host == other.Host) &&
+		(c.Path == "" || c.Path == other.Path) &&
+		(c.UserName == "" || c.UserName == other.UserName)
+}
+
+// credentialConfig is what the configuration says about one credential.
+type credentialConfig struct {
+	helpers     []string
+	userName    string
+	useHTTPPath bool
+}
+
+// readCredentialConfig applies credential.* and credential.<url>.* to c.
+// URL specific entries apply when their protocol and host match c and
+// their path is a prefix of its path. An empty helper clears the helpers
+// listed before it.
+func readCredentialConfig(config *Config, c *Credential) credentialConfig {
+	result := credentialConfig{}
+	for _, entry := range config.Entries() {
+		if entry.Section != "credential" {
+			continue
+		}
+		if entry.Subsection != "" {
+			pattern, err := CredentialForURL(entry.Subsection)
+			if err != nil || pattern.Protocol != c.Protocol || !strings.EqualFold(pattern.Host, c.Host) {
+				continue
+			}
+			if pattern.Path != "" && c.Path != pattern.Path && !strings.HasPrefix(c.Path, pattern.Path+"/") {
+				continue
+			}
+		}
+		switch entry.Key {
+		case "helper":
+			if entry.Value == "" {
+				result.helpers = nil
+			} else {
+				result.helpers = append(result.helpers, entry.Value)
+			}
+		case "username":
+			result.userName = entry.Value
+		case "usehttppath":
+			result.useHTTPPath, _ = parseConfigBool(entry.Value)
+		}
+	}
+	return result
+}
+
+// FillCredential completes c with a user name and password. They are asked
+// from the credential helpers in turn and, if none has both, from the user
+// through GIT_ASKPASS, core.askPass, SSH_ASKPASS or the terminal.
+func FillCredential(config *Config, c *Credential) error {
+	if c.UserName != "" && c.Password != "" {
+		return nil
+	}
+	settings := readCredentialConfig(config, c)
+	if !settings.useHTTPPath && (c.Protocol == "http" || c.Protocol == "https") {
+		c.Path = ""
+	}
+	if c.UserName == "" {
+		c.UserName = settings.userName
+	}
+
+	for _, helper := range settings.helpers {
+		answer, quit := runCredentialHelper(helper, "get", c)
+		if answer != nil {
+			if answer.UserName != "" {
+				c.UserName = answer.UserName
+			}
+			if answer.Password != "" {
+				c.Password = answer.Password
+			}
+		}
+		if quit {
+			return errors.New("credential helper told us to quit")
+		}
+		if c.UserName != "" && c.Password != "" {
+			return nil
+		}
+	}
+
+	var err error
+	if c.UserName == "" {
+		if c.UserName, err = credentialPrompt(config, "Username for '"+c.URL()+"': ", true); err != nil {
+			return err
+		}
+	}
+	if c.Password == "" {
+		u := url.URL{Scheme: c.Protocol, Host: c.Host, User: url.User(c.UserName)}
+		if c.Password, err = credentialPrompt(config, "Password for '"+u.String()+"': ", false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApproveCredential tells the credential helpers that c worked, so they
+// can store it.
+func ApproveCredential(config *Config, c *Credential) {
+	if c.UserName == "" || c.Password == "" {
+		return
+	}
+	for _, helper := range readCredentialConfig(config, c).helpers {
+		runCredentialHelper(helper, "store", c)
+	}
+}
+
+// RejectCredential tells the credential helpers that c was refused, so
+// they can forget it.
+func RejectCredential(config *Config, c *Credential) {
+	for _, helper := range readCredentialConfig(config, c).helpers {
+		runCredentialHelper(helper, "erase", c)
+	}
+	c.Password = ""
+}
+
+// CredentialHelper is a credential helper built into gogit.
+type CredentialHelper interface {
+	Get(c *Credential) (*Credential, error)
+	Store(c *Credential) error
+	Erase(c *Credential) error
+}
+
+// builtinCredentialHelper returns the built-in helper a credential.helper
+// value such as "store --file=<path>" or "cache --timeout=300" names.
+func builtinCredentialHelper(helper string) (CredentialHelper, bool) {
+	fields := strings.Fields(helper)
+	if len(fields) == 0 {
+		return nil, false
+	}
+	switch fields[0] {
+	case "store":
+		store := &CredentialStore{}
+		for i := 1; i < len(fields); i++ {
+			if value, found := strings.CutPrefix(fields[i], "--file="); found {
+				store.File = expandHome(value)
+			} else if fields[i] == "--file" && i+1 < len(fields) {
+				i++
+				store.File = expandHome(fields[i])
+			}
+		}
+		return store, true
+	case "cache":
+		cache := &CredentialCache{}
+		for i := 1; i < len(fields); i++ {
+			option, value, found := strings.Cut(fields[i], "=")
+			if !found && i+1 < len(fields) {
+				i++
+				value = fields[i]
+			}
+			switch option {
+			case "--timeout":
+				cache.Timeout, _ = strconv.Atoi(value)
+			case "--socket":
+				cache.Socket = expandHome(value)
+			}
+		}
+		return cache, true
+	}
+	return nil, false
+}
+
+// runCredentialHelper runs action, get, store or erase, with helper and
+// returns its answer and whether it asked to stop looking. Failing helpers
+// are skipped like git does: credentials are only a convenience.
+func runCredentialHelper(helper, action string, c *Credential) (*Credential, bool) {
+	if builtin, found := builtinCredentialHelper(helper); found {
+		switch action {
+		case "get":
+			answer, _ := builtin.Get(c)
+			return answer, false
+		case "store":
+			builtin.Store(c)
+		case "erase":
+			builtin.Erase(c)
+		}
+		return nil, false
+	}
+
+	command := helper
+	switch {
+	case strings.HasPrefix(helper, "!"):
+		command = helper[1:]
+	case filepath.IsAbs(helper):
+	default:
+		command = "git-credential-" + helper
+	}
+	cmd := exec.Command("sh", "-c", command+" "+action)
+	var input, output bytes.Buffer
+	if err := c.Encode(&input); err != nil {
+		return nil, false
+	}
+	cmd.Stdin = &input
+	cmd.Stdout = &output
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil || action != "get" {
+		return nil, false
+	}
+	answer, err := ParseCredential(&output)
+	if err != nil {
+		return nil, false
+	}
+	return answer, answer.quit
+}
+
+// credentialPrompt asks the user for a value, through an askpass program
+// if one is configured, or else on the terminal. Passwords are read
+// without echo.
+func credentialPrompt(config *Config, prompt string, echo bool) (string, error) {
+	askPass := os.Getenv("GIT_ASKPASS")
+	if askPass == "" {
+		askPass, _ = config.Get("core.askPass")
+	}
+	if askPass == "" {
+		askPass = os.Getenv("SSH_ASKPASS")
+	}
+	what := "Password"
+	if echo {
+		what = "Username"
+	}
+	if askPass != "" {
+		output, err := exec.Command(askPass, prompt).Output()
+		if err != nil {
+			return "", fmt.Errorf("could not read %s: %s failed: %w", what, askPass, err)
+		}
+		return strings.TrimRight(string(output), "\r\n"), nil
+	}
+
+	if value, set := os.LookupEnv("GIT_TERMINAL_PROMPT"); set {
+		if prompt, _ := parseConfigBool(value); !prompt {
+			return "", fmt.Errorf("could not read %s: terminal prompts disabled", what)
+		}
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("could not read %s: no terminal to prompt on", what)
+	}
+	defer tty.Close()
+	if _, err := tty.WriteString(prompt); err != nil {
+		return "", err
+	}
+	if !echo {
+		if err := setTerminalEcho(tty, false); err == nil {
+			defer func() {
+				setTerminalEcho(tty, true)
+				tty.WriteString("\n")
+			}()
+		}
+	}
+	line, err := bufio.NewReader(tty).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("could not read %s: %w", what, err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// setTerminalEcho turns echoing of typed characters on tty on or off.
+func setTerminalEcho(tty *os.File, on bool) error {
+	mode := "-echo"
+	if on {
+		mode = "echo"
+	}
+	cmd := exec.Command("stty", mode)
+	cmd.Stdin = tty
+	return cmd.Run()
+}
@@ -0,0 +1,268 @@
+package repo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultCacheTimeout is how many seconds the cache helper keeps a
+	// credential by default.
+	defaultCacheTimeout = 900
+	// cacheDaemonGrace is how long a daemon nobody talks to stays up.
+	cacheDaemonGrace = 30 * time.Second
+)
+
+// CredentialCache is the "cache" credential helper. It keeps credentials in
+// the memory of a daemon, started on the first store, which forgets them
+// after a timeout and exits once it holds none. The daemon is the gogit
+// binary running credential-cache--daemon.
+type CredentialCache struct {
+	// Socket is the unix socket of the daemon. Empty means
+	// $XDG_CACHE_HOME/gogit/credential/socket.
+	Socket string
+	// Timeout is how many seconds stored credentials are kept, 0 means
+	// 900.
+	Timeout int
+}
+
+// socket returns the path of the socket of the daemon.
+func (c *CredentialCache) socket() string {
+	if c.Socket != "" {
+		return c.Socket
+	}
+	cache := os.Getenv("XDG_CACHE_HOME")
+	if cache == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		cache = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(cache, "gogit", "credential", "socket")
+}
+
+// Get asks the daemon for a credential matching cred. It returns nil when
+// the daemon is not running.
+func (c *CredentialCache) Get(cred *Credential) (*Credential, error) {
+	response, err := c.request("get", cred)
+	if err != nil {
+		return nil, nil
+	}
+	answer, err := ParseCredential(bytes.NewReader(response))
+	if err != nil || answer.Password == "" {
+		return nil, err
+	}
+	return answer, nil
+}
+
+// Store hands cred to the daemon, starting it if needed.
+func (c *CredentialCache) Store(cred *Credential) error {
+	if _, err := c.request("store", cred); err == nil {
+		return nil
+	}
+	if err := startCredentialCacheDaemon(c.socket()); err != nil {
+		return err
+	}
+	_, err := c.request("store", cred)
+	return err
+}
+
+// Erase makes the daemon forget the credentials matching cred.
+func (c *CredentialCache) Erase(cred *Credential) error {
+	_, err := c.request("erase", cred)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Exit stops the daemon, forgetting every credential.
+func (c *CredentialCache) Exit() error {
+	_, err := c.request("exit", &Credential{})
+	return err
+}
+
+// request sends action and cred to the daemon and returns its answer.
+func (c *CredentialCache) request(action string, cred *Credential) ([]byte, error) {
+	conn, err := net.Dial("unix", c.socket())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = defaultCacheTimeout
+	}
+	var request bytes.Buffer
+	request.WriteString("action=" + action + "\n")
+	request.WriteString("timeout=" + strconv.Itoa(timeout) + "\n")
+	if err := cred.Encode(&request); err != nil {
+		return nil, err
+	}
+	request.WriteString("\n")
+	if _, err := conn.Write(request.Bytes()); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(conn)
+}
+
+// startCredentialCacheDaemon starts the daemon in the background and waits
+// for it to listen on socket.
+func startCredentialCacheDaemon(socket string) error {
+	if socket == "" {
+		return errors.New("no socket for the credential cache")
+	}
+	if err := os.MkdirAll(filepath.Dir(socket), 0700); err != nil {
+		return err
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("cannot start the credential cache daemon: %w", err)
+	}
+	cmd := exec.Command(executable, "credential-cache--daemon", socket)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("cannot start the credential cache daemon: %w", err)
+	}
+	cmd.Process.Release()
+
+	for i := 0; i < 50; i++ {
+		if conn, err := net.Dial("unix", socket); err == nil {
+			conn.Close()
+			return nil
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return errors.New("the credential cache daemon did not start")
+}
+
+// cachedCredential is a credential held by the daemon.
+type cachedCredential struct {
+	credential *Credential
+	expiry     time.Time
+}
+
+// ServeCredentialCache runs the daemon of the cache credential helper on
+// socket. It returns once every credential it was given has expired or
+// been erased, or when asked to exit.
+func ServeCredentialCache(socket string) error {
+	// a socket left by a daemon that died would make Listen fail
+	if conn, err := net.Dial("unix", socket); err == nil {
+		conn.Close()
+		return fmt.Errorf("a credential cache daemon is already listening on %s", socket)
+	}
+	os.Remove(socket)
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: socket, Net: "unix"})
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	cached := []cachedCredential{}
+	deadline := time.Now().Add(cacheDaemonGrace)
+	for {
+		listener.SetDeadline(deadline)
+		conn, err := listener.Accept()
+		var timeout net.Error
+		if errors.As(err, &timeout) && timeout.Timeout() {
+			// fall through to expire credentials
+		} else if err != nil {
+			return err
+		} else {
+			var exit bool
+			cached, exit = serveCredentialCacheRequest(conn, cached)
+			conn.Close()
+			if exit {
+				return nil
+			}
+		}
+
+		now := time.Now()
+		kept := cached[:0]
+		for _, entry := range cached {
+			if entry.expiry.After(now) {
+				kept = append(kept, entry)
+			}
+		}
+		cached = kept
+		if len(cached) == 0 {
+			if err != nil {
+				// a whole grace period went by with nothing to keep
+				return nil
+			}
+			deadline = now.Add(cacheDaemonGrace)
+			continue
+		}
+		deadline = cached[0].expiry
+		for _, entry := range cached {
+			if entry.expiry.Before(deadline) {
+				deadline = entry.expiry
+			}
+		}
+	}
+}
+
+// serveCredentialCacheRequest answers one request of a cache client and
+// returns the updated credentials and whether the daemon should exit.
+func serveCredentialCacheRequest(conn net.Conn, cached []cachedCredential) ([]cachedCredential, bool) {
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	var request bytes.Buffer
+	action, timeout := "", defaultCacheTimeout
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil || line == "\n" {
+			break
+		}
+		if value, found := strings.CutPrefix(line, "action="); found {
+			action = strings.TrimSuffix(value, "\n")
+		} else if value, found := strings.CutPrefix(line, "timeout="); found {
+			timeout, _ = strconv.Atoi(strings.TrimSuffix(value, "\n"))
+		} else {
+			request.WriteString(line)
+		}
+	}
+	credential, err := ParseCredential(&request)
+	if err != nil {
+		return cached, false
+	}
+
+	key := *credential
+	key.Password = ""
+	switch action {
+	case "get":
+		for _, entry := range cached {
+			if credential.matches(entry.credential) {
+				entry.credential.Encode(conn)
+				break
+			}
+		}
+	case "store", "erase":
+		kept := []cachedCredential{}
+		for _, entry := range cached {
+			if !key.matches(entry.credential) {
+				kept = append(kept, entry)
+			}
+		}
+		cached = kept
+		if action == "store" && credential.Password != "" {
+			expiry := time.Now().Add(time.Duration(timeout) * time.Second)
+			cached = append([]cachedCredential{{credential: credential, expiry: expiry}}, cached...)
+		}
+	case "exit":
+		return cached, true
+	}
+	return cached, false
+}
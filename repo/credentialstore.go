@@ -0,0 +1,160 @@
+package repo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CredentialStore is the "store" credential helper. It keeps credentials
+// unencrypted in a file, one URL with user name and password per line, in
+// the same format as git credential-store.
+type CredentialStore struct {
+	// File is the store to use. Empty means ~/.git-credentials, also
+	// looking up $XDG_CONFIG_HOME/git/credentials.
+	File string
+}
+
+// files returns the files searched, the one written to first.
+func (s *CredentialStore) files() []string {
+	if s.File != "" {
+		return []string{s.File}
+	}
+	files := []string{}
+	if home, err := os.UserHomeDir(); err == nil {
+		files = append(files, filepath.Join(home, ".git-credentials"))
+	}
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			xdg = filepath.Join(home, ".config")
+		}
+	}
+	if xdg != "" {
+		files = append(files, filepath.Join(xdg, "git", "credentials"))
+	}
+	return files
+}
+
+// Get returns the first stored credential matching c, or nil.
+func (s *CredentialStore) Get(c *Credential) (*Credential, error) {
+	for _, file := range s.files() {
+		stored, err := readCredentialStore(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, candidate := range stored {
+			if c.matches(candidate) {
+				return candidate, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// Store saves c at the top of the store, replacing entries for the same
+// user on the same server.
+func (s *CredentialStore) Store(c *Credential) error {
+	if c.Protocol == "" || c.Host == "" || c.UserName == "" || c.Password == "" {
+		return errors.New("credential-store needs a protocol, host, username and password")
+	}
+	key := *c
+	key.Password = ""
+	files := s.files()
+	if len(files) == 0 {
+		return errors.New("no credential store file")
+	}
+	return rewriteCredentialStore(files[0], func(stored []*Credential) []*Credential {
+		kept := []*Credential{c}
+		for _, candidate := range stored {
+			if !key.matches(candidate) {
+				kept = append(kept, candidate)
+			}
+		}
+		return kept
+	})
+}
+
+// Erase removes every stored credential matching c.
+func (s *CredentialStore) Erase(c *Credential) error {
+	key := *c
+	key.Password = ""
+	for _, file := range s.files() {
+		err := rewriteCredentialStore(file, func(stored []*Credential) []*Credential {
+			kept := []*Credential{}
+			for _, candidate := range stored {
+				if !key.matches(candidate) {
+					kept = append(kept, candidate)
+				}
+			}
+			return kept
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readCredentialStore reads the credentials of a store file, skipping lines
+// that are not complete URLs. A missing file is an empty store.
+func readCredentialStore(file string) ([]*Credential, error) {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	stored := []*Credential{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		c, err := CredentialForURL(strings.TrimSpace(scanner.Text()))
+		if err != nil || c.Protocol == "" || c.Host == "" || c.UserName == "" || c.Password == "" {
+			continue
+		}
+		stored = append(stored, c)
+	}
+	return stored, scanner.Err()
+}
+
+// rewriteCredentialStore replaces the credentials of a store file with what
+// update returns, under the lock of the file so concurrent rewrites cannot
+// lose each other's credentials. Files that do not change are not touched.
+func rewriteCredentialStore(file string, update func([]*Credential) []*Credential) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	lock, err := lock(file)
+	if err != nil {
+		return err
+	}
+	defer lock.Rollback()
+	// passwords are in the clear, only the owner may read them whatever
+	// mode the file had
+	if err := lock.file.Chmod(0600); err != nil {
+		return err
+	}
+	stored, err := readCredentialStore(file)
+	if err != nil {
+		return err
+	}
+	updated := update(stored)
+	if len(updated) == len(stored) && (len(stored) == 0 || updated[0] == stored[0]) {
+		return nil
+	}
+	for _, c := range updated {
+		u := url.URL{Scheme: c.Protocol, Host: c.Host, User: url.UserPassword(c.UserName, c.Password)}
+		if c.Path != "" {
+			u.Path = "/" + c.Path
+		}
+		if _, err := lock.Write([]byte(u.String() + "\n")); err != nil {
+			return err
+		}
+	}
+	return lock.Commit()
+}
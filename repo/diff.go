@@ -0,0 +1,265 @@
+package repo
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// diffContext is how many unchanged lines surround the changes of a hunk.
+const diffContext = 3
+
+// diffOp is what an edit does to a line.
+type diffOp int
+
+const (
+	diffEqual diffOp = iota
+	diffDelete
+	diffInsert
+)
+
+// diffEdit is one line of an edit script turning one text into another.
+type diffEdit struct {
+	op   diffOp
+	line string
+}
+
+// Hunk is a group of nearby changes of a unified diff.
+type Hunk struct {
+	// OldStart and NewStart are the first lines of the hunk on each side,
+	// counted from 1, or the line before the hunk when it has no line on
+	// that side, as in the @@ header.
+	OldStart, OldLines int
+	NewStart, NewLines int
+	// Lines are the lines of the hunk, with their end of line, prefixed
+	// with ' ' for context, '-' for removed and '+' for added lines.
+	Lines []string
+
+	// oldOffset is the number of old lines before the hunk.
+	oldOffset int
+}
+
+// Header returns the @@ line of the hunk.
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+}
+
+func hunkRange(start, lines int) string {
+	if lines == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+// String returns the hunk as it appears in a unified diff.
+func (h Hunk) String() string {
+	var text strings.Builder
+	text.WriteString(h.Header() + "\n")
+	for _, line := range h.Lines {
+		text.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			text.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+	return text.String()
+}
+
+// splitLines splits data into lines that keep their end of line. The last
+// line has none if data does not end with a newline.
+func splitLines(data []byte) []string {
+	lines := []string{}
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, '\n') + 1
+		if end == 0 {
+			end = len(data)
+		}
+		lines = append(lines, string(data[:end]))
+		data = data[end:]
+	}
+	return lines
+}
+
+// diffLines returns a shortest edit script from a to b, using the linear
+// space variant of the algorithm of Myers' "An O(ND) Difference Algorithm
+// and Its Variations", so large files need no more memory than their lines.
+func diffLines(a, b []string) []diffEdit {
+	return appendDiff(make([]diffEdit, 0, max(len(a), len(b))), a, b)
+}
+
+// appendDiff appends the edits from a to b to edits. Each call splits the
+// texts at the middle snake of a shortest path and diffs both halves.
+func appendDiff(edits []diffEdit, a, b []string) []diffEdit {
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		edits = append(edits, diffEdit{diffEqual, a[0]})
+		a, b = a[1:], b[1:]
+	}
+	common := 0
+	for common < len(a) && common < len(b) && a[len(a)-1-common] == b[len(b)-1-common] {
+		common++
+	}
+	suffix := a[len(a)-common:]
+	a, b = a[:len(a)-common], b[:len(b)-common]
+
+	switch {
+	case len(a) == 0:
+		for _, line := range b {
+			edits = append(edits, diffEdit{diffInsert, line})
+		}
+	case len(b) == 0:
+		for _, line := range a {
+			edits = append(edits, diffEdit{diffDelete, line})
+		}
+	default:
+		// without a common prefix or suffix at least two edits are left,
+		// so both halves are smaller than a and b
+		x, y, u, v := middleSnake(a, b)
+		edits = appendDiff(edits, a[:x], b[:y])
+		for _, line := range a[x:u] {
+			edits = append(edits, diffEdit{diffEqual, line})
+		}
+		edits = appendDiff(edits, a[u:], b[v:])
+	}
+	for _, line := range suffix {
+		edits = append(edits, diffEdit{diffEqual, line})
+	}
+	return edits
+}
+
+// middleSnake returns the snake from (x, y) to (u, v) in the middle of a
+// shortest edit path from a to b, found by searching for the furthest
+// reaching paths from both ends until they overlap.
+func middleSnake(a, b []string) (x, y, u, v int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	odd := delta%2 != 0
+	maxD := (n + m + 1) / 2
+	offset := maxD + 1
+	// forward[offset+k] is the furthest x on diagonal k = x - y from the
+	// start, backward[offset+c] the furthest from the end on diagonal c
+	// of the reversed texts, which is diagonal delta - c here.
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+	// the paths overlap at d = maxD at the latest
+	for d := 0; ; d++ {
+		for k := -d; k <= d; k += 2 {
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y = x - k
+			u, v = x, y
+			for u < n && v < m && a[u] == b[v] {
+				u++
+				v++
+			}
+			forward[offset+k] = u
+			if c := delta - k; odd && c >= -(d-1) && c <= d-1 && u+backward[offset+c] >= n {
+				return x, y, u, v
+			}
+		}
+		for c := -d; c <= d; c += 2 {
+			var end int
+			if c == -d || (c != d && backward[offset+c-1] < backward[offset+c+1]) {
+				end = backward[offset+c+1]
+			} else {
+				end = backward[offset+c-1] + 1
+			}
+			start := end
+			for start < n && start-c < m && a[n-1-start] == b[m-1-start+c] {
+				start++
+			}
+			backward[offset+c] = start
+			if k := delta - c; !odd && k >= -d && k <= d && forward[offset+k]+start >= n {
+				return n - start, m - start + c, n - end, m - end + c
+			}
+		}
+	}
+}
+
+// DiffHunks returns the hunks of the unified diff from old to new, with
+// diffContext lines of context. Changes closer than twice that share a
+// hunk.
+func DiffHunks(old, new []byte) []Hunk {
+	edits := diffLines(splitLines(old), splitLines(new))
+	hunks := []Hunk{}
+	// positions of every edit on both sides
+	oldLine, newLine := make([]int, len(edits)+1), make([]int, len(edits)+1)
+	for i, edit := range edits {
+		oldLine[i+1], newLine[i+1] = oldLine[i], newLine[i]
+		if edit.op != diffInsert {
+			oldLine[i+1]++
+		}
+		if edit.op != diffDelete {
+			newLine[i+1]++
+		}
+	}
+	for i := 0; i < len(edits); {
+		if edits[i].op == diffEqual {
+			i++
+			continue
+		}
+		start := max(i-diffContext, 0)
+		// extend over changes until a run of unchanged lines is too long to
+		// stay in one hunk
+		end, equal := i, 0
+		for j := i; j < len(edits) && equal <= 2*diffContext; j++ {
+			if edits[j].op == diffEqual {
+				equal++
+				continue
+			}
+			equal = 0
+			end = j + 1
+		}
+		end = min(end+diffContext, len(edits))
+
+		hunk := Hunk{oldOffset: oldLine[start]}
+		for _, edit := range edits[start:end] {
+			switch edit.op {
+			case diffEqual:
+				hunk.Lines = append(hunk.Lines, " "+edit.line)
+				hunk.OldLines++
+				hunk.NewLines++
+			case diffDelete:
+				hunk.Lines = append(hunk.Lines, "-"+edit.line)
+				hunk.OldLines++
+			case diffInsert:
+				hunk.Lines = append(hunk.Lines, "+"+edit.line)
+				hunk.NewLines++
+			}
+		}
+		hunk.OldStart, hunk.NewStart = oldLine[start], newLine[start]
+		if hunk.OldLines > 0 {
+			hunk.OldStart++
+		}
+		if hunk.NewLines > 0 {
+			hunk.NewStart++
+		}
+		hunks = append(hunks, hunk)
+		i = end
+	}
+	return hunks
+}
+
+// applyHunks returns old with the changes of hunks, which must come from
+// DiffHunks of old and be in order.
+func applyHunks(old []byte, hunks []Hunk) []byte {
+	lines := splitLines(old)
+	var result bytes.Buffer
+	position := 0
+	for _, hunk := range hunks {
+		for ; position < hunk.oldOffset; position++ {
+			result.WriteString(lines[position])
+		}
+		for _, line := range hunk.Lines {
+			if line[0] != '-' {
+				result.WriteString(line[1:])
+			}
+		}
+		position += hunk.OldLines
+	}
+	for ; position < len(lines); position++ {
+		result.WriteString(lines[position])
+	}
+	return result.Bytes()
+}
@@ -0,0 +1,123 @@
+package repo
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// lcsLength returns the length of a longest common subsequence of a and b.
+func lcsLength(a, b []string) int {
+	row := make([]int, len(b)+1)
+	for i := range a {
+		previous := 0
+		for j := range b {
+			current := row[j+1]
+			if a[i] == b[j] {
+				row[j+1] = previous + 1
+			} else {
+				row[j+1] = max(row[j+1], row[j])
+			}
+			previous = current
+		}
+	}
+	return row[len(b)]
+}
+
+func TestDiffLines(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	text := func() []string {
+		lines := make([]string, random.Intn(30))
+		for i := range lines {
+			lines[i] = string(rune('a' + random.Intn(4)))
+		}
+		return lines
+	}
+	for i := 0; i < 500; i++ {
+		a, b := text(), text()
+		edits := diffLines(a, b)
+		old, new, changes := []string{}, []string{}, 0
+		for _, edit := range edits {
+			if edit.op != diffInsert {
+				old = append(old, edit.line)
+			}
+			if edit.op != diffDelete {
+				new = append(new, edit.line)
+			}
+			if edit.op != diffEqual {
+				changes++
+			}
+		}
+		if strings.Join(old, "") != strings.Join(a, "") || strings.Join(new, "") != strings.Join(b, "") {
+			t.Fatalf("the edits of %q to %q do not turn one into the other", a, b)
+		}
+		if want := len(a) + len(b) - 2*lcsLength(a, b); changes != want {
+			t.Fatalf("the edits of %q to %q have %d changes, want %d", a, b, changes, want)
+		}
+	}
+}
+
+func TestDiffLinesMemory(t *testing.T) {
+	// every line differs, the worst case for the edit distance
+	a, b := make([]string, 3000), make([]string, 3000)
+	for i := range a {
+		a[i], b[i] = fmt.Sprintf("a%d\n", i), fmt.Sprintf("b%d\n", i)
+	}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	edits := diffLines(a, b)
+	runtime.ReadMemStats(&after)
+	if len(edits) != 6000 {
+		t.Errorf("diff has %d edits, want 6000", len(edits))
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 16<<20 {
+		t.Errorf("diffing 3000 lines allocated %d bytes", allocated)
+	}
+}
+
+func TestDiffHunks(t *testing.T) {
+	old := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16\n17\n18\n19\n20\n"
+	new := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16\n17\n18\n20\n21"
+	// as git diff -U3 prints them
+	want := []string{
+		"@@ -1,6 +1,6 @@\n 1\n 2\n-3\n+three\n 4\n 5\n 6\n",
+		"@@ -16,5 +16,5 @@\n 16\n 17\n 18\n-19\n 20\n+21\n\\ No newline at end of file\n",
+	}
+	hunks := DiffHunks([]byte(old), []byte(new))
+	if len(hunks) != len(want) {
+		t.Fatalf("diff has %d hunks, want %d", len(hunks), len(want))
+	}
+	for i, hunk := range hunks {
+		if hunk.String() != want[i] {
+			t.Errorf("hunk %d is\n%s\nwant\n%s", i, hunk, want[i])
+		}
+	}
+
+	for _, test := range []struct {
+		selected []int
+		want     string
+	}{
+		{[]int{0}, strings.Replace(old, "3\n", "three\n", 1)},
+		{[]int{1}, strings.Replace(old, "19\n20\n", "20\n21", 1)},
+		{[]int{0, 1}, new},
+	} {
+		selected := []Hunk{}
+		for _, i := range test.selected {
+			selected = append(selected, hunks[i])
+		}
+		if got := string(applyHunks([]byte(old), selected)); got != test.want {
+			t.Errorf("applying hunks %v gives %q, want %q", test.selected, got, test.want)
+		}
+	}
+
+	// changes closer than twice the context share a hunk
+	joined := DiffHunks([]byte("a\nb\nc\nd\ne\nf\ng\nh\n"), []byte("A\nb\nc\nd\ne\nf\ng\nH\n"))
+	if len(joined) != 1 || joined[0].Header() != "@@ -1,8 +1,8 @@" {
+		t.Errorf("hunks are %v", joined)
+	}
+	if hunks := DiffHunks(nil, []byte("a\n")); len(hunks) != 1 || hunks[0].Header() != "@@ -0,0 +1 @@" {
+		t.Errorf("hunks of a new file are %v", hunks)
+	}
+}
@@ -0,0 +1,350 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrFetchRejected is returned when some local refs could not be updated.
+var ErrFetchRejected = errors.New("some local refs could not be updated")
+
+// FetchOptions selects what Fetch downloads and from where.
+type FetchOptions struct {
+	// Remote is a remote name or URL. Empty means origin.
+	Remote string
+	// Refspecs are [+]<src>[:<dst>] specs. With none, the fetch refspecs
+	// of the remote are used, or HEAD for a URL.
+	Refspecs []string
+	// Tags also fetches every tag into refs/tags.
+	Tags bool
+	// Force allows non-fast-forward updates of every local ref.
+	Force bool
+	// UpdateHeadOK allows updating the branch HEAD points to in a
+	// repository with a working tree, which leaves the index and the
+	// working tree behind, like --update-head-ok.
+	UpdateHeadOK bool
+
+	UserName string
+	Password string
+	// Progress receives the "remote: " messages of the server, if set.
+	Progress io.Writer
+}
+
+// FetchResult is what Fetch did. In each update, Src is the remote ref and
+// Dst the local ref, or "" if the ref was only written to FETCH_HEAD.
+type FetchResult struct {
+	URL     string
+	Updates []RefUpdate
+}
+
+// Fetch downloads the refs selected by the refspecs of opts and the
+// objects they need, and updates the matching local refs.
+func (r *Repository) Fetch(opts FetchOptions) (*FetchResult, error) {
+	remoteConfig, err := r.ResolveRemote(opts.Remote)
+	if err != nil {
+		return nil, err
+	}
+	remote, userName, password := splitCredentials(remoteConfig.URL)
+	if opts.UserName != "" {
+		userName = opts.UserName
+	}
+	if opts.Password != "" {
+		password = opts.Password
+	}
+	result := &FetchResult{URL: remote}
+
+	specs := opts.Refspecs
+	if len(specs) == 0 {
+		specs = remoteConfig.Fetch
+		if len(specs) == 0 {
+			specs = []string{"HEAD"}
+		}
+	}
+	if opts.Tags {
+		specs = append(specs, "+refs/tags/*:refs/tags/*")
+	}
+	refspecs, err := parseFetchRefspecs(specs)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := r.connectUploadPack(remote, userName, password)
+	if err != nil {
+		return nil, err
+	}
+	adv, err := session.listRefs(refPrefixes(refspecs))
+	if err != nil {
+		return nil, err
+	}
+	action := "fetch " + remoteConfig.Name
+	if remoteConfig.Name == "" {
+		action = "fetch " + remote
+	}
+	result.Updates, err = r.fetchRefs(session, adv, refspecs, opts.Force, opts.UpdateHeadOK, action, opts.Progress)
+	if err != nil {
+		return result, err
+	}
+	if err := r.writeFetchHead(result.Updates, remote); err != nil {
+		return result, err
+	}
+
+	for _, update := range result.Updates {
+		if update.Status == RefUpdateRejected {
+			return result, ErrFetchRejected
+		}
+	}
+	return result, nil
+}
+
+// parseFetchRefspecs parses fetch refspecs, which cannot delete refs.
+func parseFetchRefspecs(specs []string) ([]Refspec, error) {
+	refspecs := []Refspec{}
+	for _, spec := range specs {
+		refspec, err := ParseRefspec(spec)
+		if err != nil {
+			return nil, err
+		}
+		if refspec.IsDelete() {
+			return nil, fmt.Errorf("invalid refspec '%s'", spec)
+		}
+		refspecs = append(refspecs, refspec)
+	}
+	return refspecs, nil
+}
+
+// fetchRefs downloads the refs of adv that refspecs select, with the
+// objects they need, and updates the matching local refs. The branch HEAD
+// points to is only updated in bare repositories or with updateHead.
+// Reflog messages start with action.
+func (r *Repository) fetchRefs(session *uploadPackSession, adv *Advertisement, refspecs []Refspec, force, updateHead bool, action string, progress io.Writer) ([]RefUpdate, error) {
+	updates, err := planFetch(refspecs, adv, force)
+	if err != nil {
+		return nil, err
+	}
+	if !updateHead && !r.IsBare() {
+		head, err := r.HeadBranch()
+		if err != nil {
+			return nil, err
+		}
+		for _, update := range updates {
+			if head != "" && update.Dst == head {
+				return nil, fmt.Errorf("refusing to fetch into branch '%s' checked out at '%s'", head, r.workTree)
+			}
+		}
+	}
+
+	wants := []string{}
+	wanted := map[string]bool{}
+	for _, update := range updates {
+		if !wanted[update.New] && !r.HasObject(update.New) {
+			wanted[update.New] = true
+			wants = append(wants, update.New)
+		}
+	}
+	if len(wants) > 0 {
+		haves, err := r.localTips()
+		if err != nil {
+			return nil, err
+		}
+		pack, err := session.fetchPack(wants, haves, progress)
+		if err != nil {
+			return updates, err
+		}
+		if err := r.storePack(pack, "fetch.unpackLimit"); err != nil {
+			return updates, err
+		}
+	}
+
+	for i := range updates {
+		if err := r.applyFetchUpdate(&updates[i], action); err != nil {
+			return updates, err
+		}
+	}
+	return updates, nil
+}
+
+// refPrefixes returns the ls-refs prefixes that cover the sources of
+// refspecs. Exact names are expanded with the ExpandRef lookup order.
+func refPrefixes(refspecs []Refspec) []string {
+	prefixes := []string{}
+	for _, refspec := range refspecs {
+		if refspec.IsPattern() {
+			prefix, _, _ := strings.Cut(refspec.Src, "*")
+			prefixes = append(prefixes, prefix)
+			continue
+		}
+		if isHash(refspec.Src) {
+			continue
+		}
+		for _, format := range refLookupOrder {
+			prefixes = append(prefixes, fmt.Sprintf(format, refspec.Src))
+		}
+	}
+	return prefixes
+}
+
+// planFetch matches the refs of the remote against refspecs.
+func planFetch(refspecs []Refspec, adv *Advertisement, force bool) ([]RefUpdate, error) {
+	refs := adv.refMap()
+	updates := []RefUpdate{}
+	seen := map[string]bool{}
+	add := func(update RefUpdate) {
+		key := update.Dst
+		if key == "" {
+			key = "FETCH_HEAD " + update.Src
+		}
+		if !seen[key] {
+			seen[key] = true
+			updates = append(updates, update)
+		}
+	}
+
+	for _, refspec := range refspecs {
+		forced := refspec.Force || force
+		if refspec.IsPattern() {
+			for _, ref := range adv.Refs {
+				if dst, ok := refspec.Match(ref.Name); ok {
+					add(RefUpdate{Src: ref.Name, Dst: dst, New: ref.Hash, Forced: forced})
+				}
+			}
+			continue
+		}
+
+		if isHash(refspec.Src) {
+			add(RefUpdate{Dst: refspec.Dst, New: refspec.Src, Forced: forced})
+			continue
+		}
+		matched := false
+		for _, format := range refLookupOrder {
+			name := fmt.Sprintf(format, refspec.Src)
+			if hash, found := refs[name]; found {
+				dst := refspec.Dst
+				if dst != "" && !strings.HasPrefix(dst, "refs/") && dst != "HEAD" {
+					dst = "refs/heads/" + dst
+					if strings.HasPrefix(name, "refs/tags/") {
+						dst = "refs/tags/" + refspec.Dst
+					}
+				}
+				add(RefUpdate{Src: name, Dst: dst, New: hash, Forced: forced})
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("couldn't find remote ref %s", refspec.Src)
+		}
+	}
+	return updates, nil
+}
+
+// localTips returns the hashes of the local refs, which the remote uses to
+// leave out objects we already have.
+func (r *Repository) localTips() ([]string, error) {
+	refs, err := r.ListRefs("")
+	if err != nil {
+		return nil, err
+	}
+	tips := []string{}
+	seen := map[string]bool{}
+	for _, ref := range refs {
+		if !seen[ref.Hash] && r.HasObject(ref.Hash) {
+			seen[ref.Hash] = true
+			tips = append(tips, ref.Hash)
+		}
+	}
+	return tips, nil
+}
+
+// applyFetchUpdate moves a local ref to the fetched value unless that would
+// lose history or clobber a tag without force.
+func (r *Repository) applyFetchUpdate(update *RefUpdate, action string) error {
+	update.Old = zeroHash
+	if update.Dst == "" {
+		update.Status = RefUpdateOK
+		return nil
+	}
+	hash, err := r.ReadRef(update.Dst)
+	if err != nil && !errors.Is(err, ErrRefNotFound) {
+		return err
+	}
+	if err == nil {
+		update.Old = hash
+	}
+
+	if update.Old == update.New {
+		update.Status = RefUpdateUpToDate
+		return nil
+	}
+	if update.Old != zeroHash {
+		switch {
+		case strings.HasPrefix(update.Dst, "refs/tags/"):
+			if !update.Forced {
+				update.Status, update.Reason = RefUpdateRejected, "would clobber existing tag"
+				return nil
+			}
+		case r.fastForward(update.Old, update.New):
+			update.Forced = false
+		case !update.Forced:
+			update.Status, update.Reason = RefUpdateRejected, "non-fast-forward"
+			return nil
+		}
+	} else {
+		update.Forced = false
+	}
+
+	message := action + ": fast-forward"
+	switch {
+	case update.Old == zeroHash && strings.HasPrefix(update.Dst, "refs/tags/"):
+		message = action + ": storing tag"
+	case update.Old == zeroHash:
+		message = action + ": storing head"
+	case update.Forced:
+		message = action + ": forced-update"
+	}
+	if err := r.WriteRef(update.Dst, update.New, message); err != nil {
+		return err
+	}
+	update.Status = RefUpdateOK
+	return nil
+}
+
+// writeFetchHead records the fetched refs in FETCH_HEAD. Refs stored in a
+// local ref are marked not-for-merge, except the upstream of the current
+// branch.
+func (r *Repository) writeFetchHead(updates []RefUpdate, remote string) error {
+	merge := ""
+	if head, err := r.HeadBranch(); err == nil && head != "" {
+		merge = r.configValue("branch." + strings.TrimPrefix(head, "refs/heads/") + ".merge")
+	}
+
+	var lines strings.Builder
+	for _, update := range updates {
+		if update.Src == "" || update.Status == RefUpdateRejected {
+			continue
+		}
+		marker := "not-for-merge"
+		if update.Dst == "" || update.Src == merge {
+			marker = ""
+		}
+		description := update.Src
+		switch {
+		case strings.HasPrefix(update.Src, "refs/heads/"):
+			description = "branch '" + ShortRefName(update.Src) + "'"
+		case strings.HasPrefix(update.Src, "refs/tags/"):
+			description = "tag '" + ShortRefName(update.Src) + "'"
+		case update.Src == "HEAD":
+			description = ""
+		}
+		if description != "" {
+			description += " of "
+		}
+		fmt.Fprintf(&lines, "%s\t%s\t%s%s\n", update.New, marker, description, remote)
+	}
+	if err := os.WriteFile(r.path("FETCH_HEAD"), []byte(lines.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write FETCH_HEAD: %w", err)
+	}
+	return nil
+}
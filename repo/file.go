@@ -0,0 +1,79 @@
+package repo
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// CatFile writes the contents of object to w as cat-file -p shows them:
+// trees are listed one entry per line as "mode type hash\tname", other
+// objects are written as they are.
+func (r *Repository) CatFile(object string, w io.Writer) error {
+	objectType, data, err := r.ReadObject(object)
+	if err != nil {
+		return err
+	}
+	if objectType != "tree" {
+		_, err = w.Write(data)
+		return err
+	}
+	entries, err := parseTree(data)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if _, err := fmt.Fprintf(w, "%06o %s %s\t%s\n", entry.Mode, entry.Type(), entry.Hash, entry.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func createDir(path string) error {
+	// check if the directory exists
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		return nil
+	}
+	if err := os.Mkdir(path, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", path, err)
+	}
+	return nil
+}
+
+func getFileSize(file *os.File) (int, error) {
+	fileStat, err := file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get file stat: %w", err)
+	}
+	return int(fileStat.Size()), nil
+}
+
+// writeFileAtomic creates path with perm from what write writes. The data
+// goes to a temporary file next to path, which is synced and renamed into
+// place, so readers never see a partial file, not even after a crash.
+func writeFileAtomic(path string, perm os.FileMode, write func(io.Writer) error) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "tmp_"+filepath.Base(path)+"_")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	if err := write(file); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := file.Chmod(perm); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
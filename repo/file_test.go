@@ -0,0 +1,50 @@
+package repo
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestCatFile(t *testing.T) {
+	r := initTestRepo(t)
+	head := commitFile(t, r, "d/b", "2\n")
+	blob, err := r.ResolveRevision("HEAD:d/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	subtree, err := r.ResolveRevision("HEAD:d")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := r.CatFile(blob, &out); err != nil || out.String() != "2\n" {
+		t.Errorf("the blob is %q, %v", out.String(), err)
+	}
+	tree, _ := r.ResolveRevision("HEAD^{tree}")
+	out.Reset()
+	if err := r.CatFile(tree, &out); err != nil || out.String() != fmt.Sprintf("040000 tree %s\td\n", subtree) {
+		t.Errorf("the tree is %q, %v", out.String(), err)
+	}
+	out.Reset()
+	if err := r.CatFile(subtree, &out); err != nil || out.String() != fmt.Sprintf("100644 blob %s\tb\n", blob) {
+		t.Errorf("the subtree is %q, %v", out.String(), err)
+	}
+	_, data, err := r.ReadObject(head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := r.CatFile(head, &out); err != nil || !bytes.Equal(out.Bytes(), data) {
+		t.Errorf("the commit is %q, %v", out.String(), err)
+	}
+
+	for object, want := range map[string]string{head: "commit", tree: "tree", blob: "blob"} {
+		objectType, size, err := r.ReadObjectInfo(object)
+		_, data, _ := r.ReadObject(object)
+		if err != nil || objectType != want || size != len(data) {
+			t.Errorf("%s is %s of size %d, %v, want %s of size %d", object, objectType, size, err, want, len(data))
+		}
+	}
+}
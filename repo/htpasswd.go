@@ -0,0 +1,120 @@
+package repo
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ReadPasswordFile reads an htpasswd file of "name:hash" lines and returns
+// the password hashes by user name. The hashes htpasswd -m and -s write,
+// $apr1$ MD5 and {SHA}, are supported. Plain text passwords are not, so
+// they never sit on disk unhashed.
+func ReadPasswordFile(file string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	users := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, hash, found := strings.Cut(line, ":")
+		if !found || name == "" {
+			return nil, fmt.Errorf("%s:%d: expected <name>:<password hash>", file, number)
+		}
+		if !strings.HasPrefix(hash, "$apr1$") && !strings.HasPrefix(hash, "{SHA}") {
+			return nil, fmt.Errorf("%s:%d: unsupported password hash for %s, use htpasswd -m or -s", file, number, name)
+		}
+		users[name] = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// checkPassword reports whether password matches hash, as ReadPasswordFile
+// returns it.
+func checkPassword(hash, password string) bool {
+	var computed string
+	switch {
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(password))
+		computed = "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+	case strings.HasPrefix(hash, "$apr1$"):
+		salt, _, _ := strings.Cut(strings.TrimPrefix(hash, "$apr1$"), "$")
+		computed = apr1(password, salt)
+	default:
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(hash)) == 1
+}
+
+// apr1 hashes password with salt the way Apache does for "$apr1$" hashes,
+// which is the MD5 based crypt of FreeBSD with another magic string.
+func apr1(password, salt string) string {
+	const magic = "$apr1$"
+	salt = salt[:min(len(salt), 8)]
+
+	alternate := md5.Sum([]byte(password + salt + password))
+	digest := md5.New()
+	digest.Write([]byte(password + magic + salt))
+	for i := len(password); i > 0; i -= 16 {
+		digest.Write(alternate[:min(i, 16)])
+	}
+	for i := len(password); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			digest.Write([]byte{0})
+		} else {
+			digest.Write([]byte{password[0]})
+		}
+	}
+	final := digest.Sum(nil)
+
+	// rounds meant to slow down guessing
+	for i := 0; i < 1000; i++ {
+		round := md5.New()
+		if i&1 != 0 {
+			round.Write([]byte(password))
+		} else {
+			round.Write(final)
+		}
+		if i%3 != 0 {
+			round.Write([]byte(salt))
+		}
+		if i%7 != 0 {
+			round.Write([]byte(password))
+		}
+		if i&1 != 0 {
+			round.Write(final)
+		} else {
+			round.Write([]byte(password))
+		}
+		final = round.Sum(nil)
+	}
+
+	const alphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	var encoded strings.Builder
+	encode := func(value uint, n int) {
+		for ; n > 0; n-- {
+			encoded.WriteByte(alphabet[value&0x3f])
+			value >>= 6
+		}
+	}
+	for _, group := range [][3]int{{0, 6, 12}, {1, 7, 13}, {2, 8, 14}, {3, 9, 15}, {4, 10, 5}} {
+		encode(uint(final[group[0]])<<16|uint(final[group[1]])<<8|uint(final[group[2]]), 4)
+	}
+	encode(uint(final[11]), 2)
+	return magic + salt + "$" + encoded.String()
+}
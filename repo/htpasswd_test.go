@@ -0,0 +1,68 @@
+package repo
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckPassword(t *testing.T) {
+	// hashes from openssl passwd -apr1 and htpasswd -s
+	for _, test := range []struct {
+		hash, password string
+		ok             bool
+	}{
+		{"$apr1$abcdefgh$h9FWgUz3n9YxylKLlR5SQ/", "secret", true},
+		{"$apr1$abcdefgh$h9FWgUz3n9YxylKLlR5SQ/", "Secret", false},
+		{"$apr1$xy$43..WIhbfuznGvwoCyUek/", "", true},
+		{"{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=", "secret", true},
+		{"{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=", "secret ", false},
+		{"secret", "secret", false},
+	} {
+		if got := checkPassword(test.hash, test.password); got != test.ok {
+			t.Errorf("checkPassword(%q, %q) = %v, want %v", test.hash, test.password, got, test.ok)
+		}
+	}
+}
+
+func TestReadPasswordFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "htpasswd")
+	data := "# users\nalice:$apr1$abcdefgh$h9FWgUz3n9YxylKLlR5SQ/\n\nbob:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=\n"
+	if err := os.WriteFile(file, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	users, err := ReadPasswordFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users["bob"] != "{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=" {
+		t.Errorf("users are %v", users)
+	}
+
+	authorize := BasicAuth(users)
+	for _, test := range []struct {
+		name, password string
+		ok             bool
+	}{
+		{"alice", "secret", true},
+		{"bob", "secret", true},
+		{"alice", "wrong", false},
+		{"carol", "secret", false},
+	} {
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/repo.git/info/refs", nil)
+		req.SetBasicAuth(test.name, test.password)
+		if err := authorize(req, "repo.git", false); (err == nil) != test.ok {
+			t.Errorf("%s:%s was answered with %v", test.name, test.password, err)
+		}
+	}
+
+	for _, bad := range []string{"alice:secret\n", "alice\n", ":{SHA}x\n", "alice:$2y$05$abc\n"} {
+		if err := os.WriteFile(file, []byte(bad), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadPasswordFile(file); err == nil {
+			t.Errorf("ReadPasswordFile accepted %q", bad)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package repo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnauthorized is returned by an HTTPBackend Authorize function to ask
+// the client for credentials.
+var ErrUnauthorized = errors.New("authentication required")
+
+// HTTPBackend is an http.Handler serving the repositories below Root over
+// the smart HTTP protocol, like git http-backend. Clone and fetch are
+// always allowed unless a repository sets http.uploadpack to false. Push
+// needs http.receivepack set to true, or Authorize set and the config
+// unset: anonymous pushes are never accepted by default.
+type HTTPBackend struct {
+	// Root is the directory repositories are looked up in.
+	Root string
+	// Realm is sent to clients asked for credentials. Empty means "gogit".
+	Realm string
+	// Authorize, if set, is called before every request with the path of
+	// the repository below Root and whether the request can update it.
+	// Returning ErrUnauthorized answers 401 so the client retries with
+	// credentials, any other error answers 403.
+	Authorize func(req *http.Request, repository string, write bool) error
+}
+
+// BasicAuth returns an Authorize function for HTTPBackend that accepts
+// the basic auth credentials of users, which maps names to password hashes
+// as ReadPasswordFile returns them, for every repository.
+func BasicAuth(users map[string]string) func(*http.Request, string, bool) error {
+	return func(req *http.Request, _ string, _ bool) error {
+		userName, password, ok := req.BasicAuth()
+		if !ok {
+			return ErrUnauthorized
+		}
+		hash, found := users[userName]
+		if !found || !checkPassword(hash, password) {
+			return ErrUnauthorized
+		}
+		return nil
+	}
+}
+
+func (b *HTTPBackend) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	// cleaning a rooted path removes every ".." that could escape Root
+	urlPath := path.Clean("/" + req.URL.Path)
+	var repository, service string
+	switch {
+	case strings.HasSuffix(urlPath, "/info/refs"):
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		repository = strings.TrimSuffix(urlPath, "/info/refs")
+		service = req.URL.Query().Get("service")
+		if service == "" {
+			http.Error(w, "The dumb HTTP protocol is not supported", http.StatusForbidden)
+			return
+		}
+	case strings.HasSuffix(urlPath, "/git-upload-pack"), strings.HasSuffix(urlPath, "/git-receive-pack"):
+		if req.Method != http.MethodPost {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		slash := strings.LastIndex(urlPath, "/")
+		repository, service = urlPath[:slash], urlPath[slash+1:]
+	default:
+		http.NotFound(w, req)
+		return
+	}
+	if service != "git-upload-pack" && service != "git-receive-pack" {
+		http.Error(w, "Unknown service "+service, http.StatusForbidden)
+		return
+	}
+	repository = strings.TrimPrefix(repository, "/")
+
+	write := service == "git-receive-pack"
+	if b.Authorize != nil {
+		if err := b.Authorize(req, repository, write); err != nil {
+			if errors.Is(err, ErrUnauthorized) {
+				realm := b.Realm
+				if realm == "" {
+					realm = "gogit"
+				}
+				w.Header().Set("WWW-Authenticate", `Basic realm="`+realm+`"`)
+				http.Error(w, "Authentication required", http.StatusUnauthorized)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	directory := filepath.Join(b.Root, filepath.FromSlash(repository))
+	if _, err := os.Stat(directory); err != nil {
+		http.NotFound(w, req)
+		return
+	}
+	r, err := OpenDir(directory)
+	if err != nil {
+		http.NotFound(w, req)
+		return
+	}
+	if !b.serviceEnabled(r, service) {
+		http.Error(w, "Service not enabled: "+service, http.StatusForbidden)
+		return
+	}
+
+	version := ParseProtocolVersion(req.Header.Get("Git-Protocol"))
+	if write {
+		version = 0
+	}
+	// responses are built in memory so a failure can still be reported
+	// with a proper status code
+	var response bytes.Buffer
+	var contentType string
+	if req.Method == http.MethodPost {
+		contentType = "application/x-" + service + "-result"
+		if req.Header.Get("Content-Type") != "application/x-"+service+"-request" {
+			http.Error(w, "Unsupported Media Type", http.StatusUnsupportedMediaType)
+			return
+		}
+		body := io.Reader(req.Body)
+		switch req.Header.Get("Content-Encoding") {
+		case "gzip", "x-gzip":
+			gzipReader, err := gzip.NewReader(req.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			defer gzipReader.Close()
+			body = gzipReader
+		}
+		if write {
+			err = r.ReceivePack(body, &response)
+		} else {
+			err = r.UploadPack(body, &response, version)
+		}
+	} else {
+		contentType = "application/x-" + service + "-advertisement"
+		if version != 2 {
+			pkt := newPktWriter(&response)
+			pkt.WriteString("# service=" + service + "\n")
+			pkt.Flush()
+		}
+		err = r.AdvertiseRefs(&response, service, version)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", contentType)
+	header.Set("Expires", "Fri, 01 Jan 1980 00:00:00 GMT")
+	header.Set("Pragma", "no-cache")
+	header.Set("Cache-Control", "no-cache, max-age=0, must-revalidate")
+	w.Write(response.Bytes())
+}
+
+// serviceEnabled applies http.uploadpack and http.receivepack of r.
+func (b *HTTPBackend) serviceEnabled(r *Repository, service string) bool {
+	config, err := r.Config()
+	if err != nil {
+		return false
+	}
+	if service == "git-receive-pack" {
+		return config.GetBool("http.receivepack", b.Authorize != nil)
+	}
+	return config.GetBool("http.uploadpack", true)
+}
@@ -0,0 +1,302 @@
+package repo
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Ignore decides which untracked files are left out, from patterns in the
+// gitignore format. As in git, patterns given on the command line win over
+// those of per-directory files, which win over exclude files, and within
+// each group the last matching pattern decides.
+type Ignore struct {
+	// PerDirectory is the name of the pattern files read from each
+	// directory of the working tree, usually .gitignore. Empty reads none.
+	PerDirectory string
+
+	command     []ignorePattern
+	directories []ignorePattern
+	files       []ignorePattern
+}
+
+// ignorePattern is one compiled line of a gitignore file.
+type ignorePattern struct {
+	// base is the directory the pattern applies to, "" for the top of the
+	// working tree.
+	base    string
+	negate  bool
+	dirOnly bool
+	// basename patterns have no slash and match the last component of a
+	// path at any depth below base.
+	basename bool
+	matcher  *regexp.Regexp
+}
+
+// StandardIgnore returns the patterns git uses by default: .gitignore in
+// every directory, .git/info/exclude and core.excludesFile, which defaults
+// to $XDG_CONFIG_HOME/git/ignore.
+func (r *Repository) StandardIgnore() (*Ignore, error) {
+	ignore := &Ignore{PerDirectory: ".gitignore"}
+	excludesFile := r.configValue("core.excludesfile")
+	if excludesFile == "" {
+		xdg := os.Getenv("XDG_CONFIG_HOME")
+		if xdg == "" {
+			if home, err := os.UserHomeDir(); err == nil {
+				xdg = filepath.Join(home, ".config")
+			}
+		}
+		if xdg != "" {
+			excludesFile = filepath.Join(xdg, "git", "ignore")
+		}
+	}
+	for _, file := range []string{expandHome(excludesFile), r.path("info", "exclude")} {
+		if file == "" {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ignore.files = append(ignore.files, parseIgnorePatterns("", string(data))...)
+	}
+	return ignore, nil
+}
+
+// AddPattern adds a pattern as if given on the command line.
+func (ignore *Ignore) AddPattern(pattern string) {
+	ignore.command = append(ignore.command, parseIgnorePatterns("", pattern)...)
+}
+
+// AddFile adds the patterns of file as if given on the command line.
+func (ignore *Ignore) AddFile(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("cannot use %s as an exclude file: %w", file, err)
+	}
+	ignore.command = append(ignore.command, parseIgnorePatterns("", string(data))...)
+	return nil
+}
+
+// parseIgnorePatterns compiles the lines of a gitignore file found in base.
+func parseIgnorePatterns(base, data string) []ignorePattern {
+	patterns := []ignorePattern{}
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		// trailing spaces are dropped unless escaped
+		for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+			line = line[:len(line)-1]
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pattern := ignorePattern{base: base}
+		if strings.HasPrefix(line, "!") {
+			pattern.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			pattern.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		pattern.basename = !strings.Contains(line, "/")
+		pattern.matcher = ignoreRegexp(strings.TrimPrefix(line, "/"))
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+// ignoreRegexp compiles a gitignore glob, where * and ? stop at slashes
+// and ** matches any number of directories.
+func ignoreRegexp(pattern string) *regexp.Regexp {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if strings.HasPrefix(pattern[i:], "**") && (i == 0 || pattern[i-1] == '/') {
+				rest := pattern[i+2:]
+				switch {
+				case rest == "":
+					expr.WriteString(".*")
+					i++
+					continue
+				case strings.HasPrefix(rest, "/"):
+					expr.WriteString("(?:.*/)?")
+					i += 2
+					continue
+				}
+			}
+			expr.WriteString("[^/]*")
+		case '?':
+			expr.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end == -1 {
+				expr.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			expr.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+	matcher, err := regexp.Compile(expr.String())
+	if err != nil {
+		// a broken class matches nothing, as in git
+		return regexp.MustCompile(`[^\x00-\x{10FFFF}]`)
+	}
+	return matcher
+}
+
+// matches reports whether the pattern applies to name, a path relative to
+// the top of the working tree.
+func (pattern ignorePattern) matches(name string, isDir bool) bool {
+	if pattern.dirOnly && !isDir {
+		return false
+	}
+	if pattern.base != "" {
+		rest, found := strings.CutPrefix(name, pattern.base+"/")
+		if !found {
+			return false
+		}
+		name = rest
+	}
+	if pattern.basename {
+		name = path.Base(name)
+	}
+	return pattern.matcher.MatchString(name)
+}
+
+// ignored reports whether name is ignored. The directories above it are
+// not checked: walks do not enter ignored directories.
+func (ignore *Ignore) ignored(name string, isDir bool) bool {
+	for _, group := range [][]ignorePattern{ignore.command, ignore.directories, ignore.files} {
+		for i := len(group) - 1; i >= 0; i-- {
+			if group[i].matches(name, isDir) {
+				return !group[i].negate
+			}
+		}
+	}
+	return false
+}
+
+// loadDirectory adds the per-directory patterns of dir, relative to the top
+// of the working tree.
+func (ignore *Ignore) loadDirectory(workTree, dir string) error {
+	if ignore.PerDirectory == "" {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(workTree, filepath.FromSlash(dir), ignore.PerDirectory))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ignore.directories = append(ignore.directories, parseIgnorePatterns(dir, string(data))...)
+	return nil
+}
+
+// UntrackedFiles returns the sorted paths of the files of the working tree
+// that are not in the index and not ignored. A nil ignore ignores nothing.
+func (r *Repository) UntrackedFiles(ignore *Ignore) ([]string, error) {
+	if r.IsBare() {
+		return nil, ErrBareRepository
+	}
+	if ignore == nil {
+		ignore = &Ignore{}
+	}
+	entries, err := r.ReadIndex()
+	if err != nil {
+		return nil, err
+	}
+	tracked := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		tracked[entry.Path] = true
+	}
+
+	untracked := []string{}
+	err = filepath.WalkDir(r.workTree, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(r.workTree, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return ignore.loadDirectory(r.workTree, "")
+		}
+		if d.Name() == ".git" {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ignore.ignored(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return ignore.loadDirectory(r.workTree, rel)
+		}
+		if !tracked[rel] {
+			untracked = append(untracked, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(untracked)
+	return untracked, nil
+}
+
+// MatchPathspec reports whether name, relative to the top of the working
+// tree, is selected by specs: it is one of them, lies in a directory one of
+// them names, or matches one as a glob. No specs select everything.
+func MatchPathspec(specs []string, name string) bool {
+	if len(specs) == 0 {
+		return true
+	}
+	for _, spec := range specs {
+		spec = strings.TrimSuffix(spec, "/")
+		if spec == "" || spec == "." || name == spec || strings.HasPrefix(name, spec+"/") {
+			return true
+		}
+		if strings.ContainsAny(spec, "*?[") {
+			if matcher, err := globRegexp(spec); err == nil && matcher.MatchString(name) {
+				return true
+			}
+		}
+	}
+	return false
+}
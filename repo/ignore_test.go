@@ -0,0 +1,132 @@
+package repo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIgnorePatterns(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		name    string
+		isDir   bool
+		want    bool
+	}{
+		{"*.o", "main.o", false, true},
+		{"*.o", "lib/deep/main.o", false, true},
+		{"*.o", "main.c", false, false},
+		{"/build", "build", true, true},
+		{"/build", "src/build", true, false},
+		{"build/", "src/build", true, true},
+		{"build/", "build", false, false},
+		{"doc/*.txt", "doc/a.txt", false, true},
+		{"doc/*.txt", "doc/sub/a.txt", false, false},
+		{"**/logs", "a/b/logs", true, true},
+		{"logs/**", "logs/a/b", false, true},
+		{"a/**/b", "a/x/y/b", false, true},
+		{"a/**/b", "a/b", false, true},
+		{"file?.[ch]", "file1.c", false, true},
+		{"file?.[!ch]", "file1.c", false, false},
+		{`\#notes`, "#notes", false, true},
+		{"# comment", "# comment", false, false},
+		{"trailing   ", "trailing", false, true},
+	} {
+		ignore := &Ignore{}
+		ignore.AddPattern(test.pattern)
+		if got := ignore.ignored(test.name, test.isDir); got != test.want {
+			t.Errorf("%q ignores %s: %v, want %v", test.pattern, test.name, got, test.want)
+		}
+	}
+
+	// the last matching pattern wins
+	ignore := &Ignore{}
+	ignore.AddPattern("*.log\n!keep.log")
+	if ignore.ignored("keep.log", false) || !ignore.ignored("other.log", false) {
+		t.Error("a negated pattern did not override an earlier one")
+	}
+}
+
+func TestUntrackedFiles(t *testing.T) {
+	r := initTestRepo(t)
+	commitFile(t, r, "tracked.o", "tracked\n")
+	for name, content := range map[string]string{
+		".gitignore":     "*.o\nbuild/\n",
+		"a.o":            "",
+		"a.c":            "",
+		"build/out":      "",
+		"src/.gitignore": "!keep.o\n/local\n",
+		"src/keep.o":     "",
+		"src/b.o":        "",
+		"src/local":      "",
+		"src/x/local":    "",
+		"secret":         "",
+	} {
+		file := filepath.Join(r.WorkTree(), filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(r.GitDir(), "info", "exclude"), []byte("secret\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	untracked, err := r.UntrackedFiles(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(untracked, " "); got != ".gitignore a.c a.o build/out secret src/.gitignore src/b.o src/keep.o src/local src/x/local" {
+		t.Errorf("without patterns the untracked files are %s", got)
+	}
+
+	ignore, err := r.StandardIgnore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	untracked, err = r.UntrackedFiles(ignore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(untracked, " "); got != ".gitignore a.c src/.gitignore src/keep.o src/x/local" {
+		t.Errorf("the untracked files are %s", got)
+	}
+
+	// patterns from the command line win over .gitignore files
+	ignore, err = r.StandardIgnore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ignore.AddPattern("!a.o\n.gitignore")
+	untracked, err = r.UntrackedFiles(ignore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(untracked, " "); got != "a.c a.o src/keep.o src/x/local" {
+		t.Errorf("with command line patterns the untracked files are %s", got)
+	}
+}
+
+func TestMatchPathspec(t *testing.T) {
+	for _, test := range []struct {
+		specs []string
+		name  string
+		want  bool
+	}{
+		{nil, "a/b", true},
+		{[]string{"."}, "a/b", true},
+		{[]string{"a"}, "a/b", true},
+		{[]string{"a/"}, "a/b", true},
+		{[]string{"a"}, "ab", false},
+		{[]string{"a/b"}, "a", false},
+		{[]string{"*.go"}, "main.go", true},
+		{[]string{"c", "d/*"}, "d/e", true},
+	} {
+		if got := MatchPathspec(test.specs, test.name); got != test.want {
+			t.Errorf("MatchPathspec(%q, %s) = %v, want %v", test.specs, test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package repo
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// IndexEntry is a single file staged in the index.
+type IndexEntry struct {
+	CtimeSec  int
+	CtimeNsec int
+	MtimeSec  int
+	MtimeNsec int
+	Dev       int
+	Ino       int
+	Mode      int
+	Uid       int
+	Gid       int
+	Size      int
+	Sha1      []byte
+	Flags     int
+	Path      string
+}
+
+// Hash returns the hex encoded hash of the staged blob.
+func (entry IndexEntry) Hash() string {
+	return hex.EncodeToString(entry.Sha1)
+}
+
+// Stage returns the merge stage of the entry, 0 unless it is conflicted.
+func (entry IndexEntry) Stage() int {
+	return entry.Flags >> 12 & 3
+}
+
+func createIndexEntry(filename, fileHash string) (IndexEntry, error) {
+	indexEntry := IndexEntry{}
+	fileInfo, err := os.Lstat(filename)
+	if err != nil {
+		return indexEntry, fmt.Errorf("failed to get file info: %w", err)
+	}
+	stat := fileInfo.Sys().(*syscall.Stat_t)
+	indexEntry.CtimeSec = int(stat.Ctim.Sec)
+	indexEntry.CtimeNsec = int(stat.Ctim.Nsec)
+	indexEntry.MtimeSec = int(stat.Mtim.Sec)
+	indexEntry.MtimeNsec = int(stat.Mtim.Nsec)
+	indexEntry.Dev = int(stat.Dev)
+	indexEntry.Ino = int(stat.Ino)
+	indexEntry.Mode = int(stat.Mode)
+	indexEntry.Mode = setCorrectMode(indexEntry.Mode)
+	indexEntry.Uid = int(stat.Uid)
+	indexEntry.Gid = int(stat.Gid)
+	indexEntry.Size = int(stat.Size)
+	indexEntry.Path = filename
+	sha1, _ := hex.DecodeString(fileHash)
+	indexEntry.Sha1 = sha1
+	indexEntry.Flags = len(filename)
+	return indexEntry, nil
+}
+
+// ReadIndex returns the entries of the index, or none if there is no index.
+func (r *Repository) ReadIndex() ([]IndexEntry, error) {
+	data, err := os.ReadFile(r.path("index"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []IndexEntry{}, nil
+		}
+		return nil, fmt.Errorf("failed to open index file: %w", err)
+	}
+	if err := validateFile(data); err != nil {
+		return nil, err
+	}
+	if len(data) < 32 || string(data[:4]) != "DIRC" {
+		return nil, fmt.Errorf("invalid index file")
+	}
+
+	entryCount := binary.BigEndian.Uint32(data[8:12])
+	data = data[12 : len(data)-20]
+
+	indexes := make([]IndexEntry, 0, entryCount)
+	for i := 0; i < int(entryCount); i++ {
+		if len(data) < 62 {
+			return nil, fmt.Errorf("invalid index file: truncated entry")
+		}
+		field := func(n int) int {
+			return int(binary.BigEndian.Uint32(data[n*4:]))
+		}
+		entry := IndexEntry{
+			CtimeSec:  field(0),
+			CtimeNsec: field(1),
+			MtimeSec:  field(2),
+			MtimeNsec: field(3),
+			Dev:       field(4),
+			Ino:       field(5),
+			Mode:      field(6),
+			Uid:       field(7),
+			Gid:       field(8),
+			Size:      field(9),
+			Sha1:      append([]byte{}, data[40:60]...),
+			Flags:     int(binary.BigEndian.Uint16(data[60:62])),
+		}
+
+		end := bytes.IndexByte(data[62:], 0)
+		if end == -1 {
+			return nil, fmt.Errorf("invalid index file: unterminated path")
+		}
+		entry.Path = string(data[62 : 62+end])
+		entryLen := 62 + len(entry.Path) + 1
+		pad := (8 - (entryLen % 8)) % 8
+		if entryLen+pad > len(data) {
+			return nil, fmt.Errorf("invalid index file: truncated entry")
+		}
+		data = data[entryLen+pad:]
+
+		indexes = append(indexes, entry)
+	}
+	return indexes, nil
+
+}
+
+// lockIndex takes the lock of the index. Commands hold it from reading the
+// index until they write it back, so concurrent changes are not lost.
+func (r *Repository) lockIndex() (*lockFile, error) {
+	return lock(r.path("index"))
+}
+
+// writeToIndexFile replaces the index with indexEntries through lock, the
+// lock of the index, releasing it.
+func (r *Repository) writeToIndexFile(lock *lockFile, indexEntries []IndexEntry) error {
+	var buf bytes.Buffer
+	buf.WriteString("DIRC")
+	buf.Write(paddInteger(2, 4))
+	buf.Write(paddInteger(len(indexEntries), 4))
+	for _, entry := range indexEntries {
+
+		buf.Write(paddInteger(entry.CtimeSec, 4))
+		buf.Write(paddInteger(entry.CtimeNsec, 4))
+		buf.Write(paddInteger(entry.MtimeSec, 4))
+		buf.Write(paddInteger(entry.MtimeNsec, 4))
+		buf.Write(paddInteger(entry.Dev, 4))
+		buf.Write(paddInteger(entry.Ino, 4))
+		// the mode is a 4 byte integer
+		// where the last 9 bit can be only of two type 111101101 or 110100100
+
+		buf.Write(paddInteger(entry.Mode, 4))
+		buf.Write(paddInteger(entry.Uid, 4))
+		buf.Write(paddInteger(entry.Gid, 4))
+		buf.Write(paddInteger(entry.Size, 4))
+		buf.Write(entry.Sha1)
+		buf.Write(paddInteger(entry.Flags, 2))
+		buf.WriteString(entry.Path)
+		buf.WriteByte(0)
+
+		pad := (8 - ((62 + len(entry.Path) + 1) % 8)) % 8
+		buf.Write(make([]byte, pad))
+
+	}
+
+	hasher := sha1.New()
+	hasher.Write(buf.Bytes())
+	buf.Write(hasher.Sum(nil))
+
+	if _, err := lock.Write(buf.Bytes()); err != nil {
+		lock.Rollback()
+		return fmt.Errorf("failed to write index file: %w", err)
+	}
+	return lock.Commit()
+}
+
+// validateFile checks the trailing sha1 checksum of an index file.
+func validateFile(data []byte) error {
+	if len(data) < 20 {
+		return fmt.Errorf("the index file is corrupted")
+	}
+	hash := sha1.Sum(data[:len(data)-20])
+	if !bytes.Equal(hash[:], data[len(data)-20:]) {
+		return fmt.Errorf("the index file is corrupted")
+	}
+	return nil
+}
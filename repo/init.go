@@ -0,0 +1,116 @@
+package repo
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// DefaultBranch is the initial branch of new repositories when neither the
+// caller nor init.defaultBranch chooses one.
+const DefaultBranch = "master"
+
+// InitOptions configures InitWithOptions.
+type InitOptions struct {
+	// Bare creates a repository without a working tree.
+	Bare bool
+	// InitialBranch is the unborn branch HEAD points to. Empty means
+	// init.defaultBranch, or DefaultBranch if that is not set.
+	InitialBranch string
+}
+
+// Init creates an empty repository in directory and opens it.
+func Init(directory string) (*Repository, error) {
+	return InitWithOptions(directory, InitOptions{})
+}
+
+// InitBare creates an empty bare repository, one without a working tree,
+// in directory and opens it.
+func InitBare(directory string) (*Repository, error) {
+	return InitWithOptions(directory, InitOptions{Bare: true})
+}
+
+// InitWithOptions creates an empty repository in directory as opts says
+// and opens it.
+func InitWithOptions(directory string, opts InitOptions) (*Repository, error) {
+	branch := opts.InitialBranch
+	if branch == "" {
+		config, err := ReadConfig("")
+		if err != nil {
+			return nil, err
+		}
+		branch = DefaultBranch
+		if configured, ok := config.Get("init.defaultbranch"); ok && configured != "" {
+			branch = configured
+		}
+	}
+	if !ValidRefName("refs/heads/" + branch) {
+		return nil, fmt.Errorf("invalid initial branch name: '%s'", branch)
+	}
+	bare := opts.Bare
+
+	// create a directory
+	if directory == "" || directory == "." {
+		directory = "."
+	} else if err := createDir(directory); err != nil {
+		return nil, err
+	}
+
+	directory, err := filepath.Abs(directory)
+	if err != nil {
+		return nil, err
+	}
+	r := &Repository{gitDir: path.Join(directory, ".git"), workTree: directory}
+	if bare {
+		r = &Repository{gitDir: directory}
+	}
+
+	// create a .git directory
+	if err := createDir(r.gitDir); err != nil {
+		return nil, err
+	}
+	// create subdirectories
+	for _, dir := range []string{"branches", "hooks", "info", "objects", "refs"} {
+		if err := createDir(r.path(dir)); err != nil {
+			return nil, err
+		}
+	}
+
+	// create objects subdirectories
+	for _, dir := range []string{"info", "pack"} {
+		if err := createDir(r.path("objects", dir)); err != nil {
+			return nil, err
+		}
+	}
+
+	// create refs subdirectories
+	for _, dir := range []string{"heads", "tags"} {
+		if err := createDir(r.path("refs", dir)); err != nil {
+			return nil, err
+		}
+	}
+
+	config := "[core]\n\trepositoryformatversion = 0\n\tfilemode = true\n"
+	if bare {
+		config += "\tbare = true\n"
+	} else {
+		config += "\tbare = false\n\tlogallrefupdates = true\n"
+	}
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"HEAD", "ref: refs/heads/" + branch + "\n"},
+		{"config", config},
+		{"description", "Unnamed repository; edit this file 'description' to name the repository.\n"},
+		{path.Join("info", "exclude"), ""},
+	}
+	for _, file := range files {
+		if err := os.WriteFile(r.path(file.name), []byte(file.content), 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
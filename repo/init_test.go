@@ -0,0 +1,52 @@
+package repo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInitialBranch(t *testing.T) {
+	setupTestEnv(t)
+	dir := t.TempDir()
+
+	for _, test := range []struct {
+		name string
+		opts InitOptions
+		want string
+	}{
+		{"default", InitOptions{}, "refs/heads/master"},
+		{"option", InitOptions{InitialBranch: "main"}, "refs/heads/main"},
+		{"bare", InitOptions{Bare: true, InitialBranch: "feature/x"}, "refs/heads/feature/x"},
+	} {
+		r, err := InitWithOptions(filepath.Join(dir, test.name), test.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if branch, err := r.HeadBranch(); err != nil || branch != test.want {
+			t.Errorf("%s: HEAD is on %s, %v, want %s", test.name, branch, err, test.want)
+		}
+		if r.IsBare() != test.opts.Bare {
+			t.Errorf("%s: bare is %v", test.name, r.IsBare())
+		}
+	}
+
+	if r, err := InitWithOptions(filepath.Join(dir, "invalid"), InitOptions{InitialBranch: "a..b"}); err == nil {
+		t.Errorf("an invalid initial branch made %s", r.GitDir())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "invalid")); !os.IsNotExist(err) {
+		t.Errorf("a failed init left its directory: %v", err)
+	}
+
+	global := filepath.Join(os.Getenv("HOME"), ".gitconfig")
+	if err := os.WriteFile(global, []byte("[init]\n\tdefaultBranch = trunk\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := Init(filepath.Join(dir, "configured"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if branch, err := r.HeadBranch(); err != nil || branch != "refs/heads/trunk" {
+		t.Errorf("with init.defaultBranch HEAD is on %s, %v", branch, err)
+	}
+}
@@ -0,0 +1,79 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrLocked is returned when a file is already locked by another process.
+var ErrLocked = errors.New("file is locked")
+
+// lockFile implements git's protocol for replacing a file atomically: the
+// new contents are written to "<file>.lock", created exclusively so only
+// one writer holds it, then renamed over the file. Readers see either the
+// old or the new contents, never a partial write.
+type lockFile struct {
+	path string
+	file *os.File
+}
+
+// lock takes the lock of path.
+func lock(path string) (*lockFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path+".lock", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return nil, fmt.Errorf("%w: unable to create '%s.lock': another gogit process seems to be running, remove the file if it crashed", ErrLocked, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to create '%s.lock': %w", path, err)
+	}
+	return &lockFile{path: path, file: file}, nil
+}
+
+// Write adds data to the new contents.
+func (l *lockFile) Write(data []byte) (int, error) {
+	return l.file.Write(data)
+}
+
+// Commit flushes the new contents to disk and puts them in place,
+// releasing the lock.
+func (l *lockFile) Commit() error {
+	if err := l.file.Sync(); err != nil {
+		l.Rollback()
+		return fmt.Errorf("failed to write %s: %w", l.path, err)
+	}
+	if err := l.file.Close(); err != nil {
+		os.Remove(l.file.Name())
+		return fmt.Errorf("failed to write %s: %w", l.path, err)
+	}
+	if err := os.Rename(l.file.Name(), l.path); err != nil {
+		os.Remove(l.file.Name())
+		return fmt.Errorf("failed to write %s: %w", l.path, err)
+	}
+	return nil
+}
+
+// Rollback drops the new contents and releases the lock. It may be called
+// after Commit, doing nothing then.
+func (l *lockFile) Rollback() {
+	if l.file.Close() == nil {
+		os.Remove(l.file.Name())
+	}
+}
+
+// writeFileLocked replaces path with data under its lock.
+func writeFileLocked(path string, data []byte) error {
+	lock, err := lock(path)
+	if err != nil {
+		return err
+	}
+	if _, err := lock.Write(data); err != nil {
+		lock.Rollback()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return lock.Commit()
+}
@@ -0,0 +1,80 @@
+package repo
+
+import (
+	"path"
+	"strings"
+)
+
+// ListRemoteOptions selects the refs ListRemote returns.
+type ListRemoteOptions struct {
+	// Remote is a remote name or URL. Empty means origin.
+	Remote string
+	// Heads and Tags limit the refs to branches and tags. Both may be set.
+	Heads bool
+	Tags  bool
+	// Patterns keep only refs whose name ends in a component sequence
+	// matching one of them, like "main" or "release-*".
+	Patterns []string
+
+	UserName string
+	Password string
+}
+
+// ListRemote returns the refs a remote advertises for fetching, with the
+// peeled value of annotated tags and the targets of symbolic refs.
+func (r *Repository) ListRemote(opts ListRemoteOptions) (*Advertisement, error) {
+	remoteConfig, err := r.ResolveRemote(opts.Remote)
+	if err != nil {
+		return nil, err
+	}
+	remote, userName, password := splitCredentials(remoteConfig.URL)
+	if opts.UserName != "" {
+		userName = opts.UserName
+	}
+	if opts.Password != "" {
+		password = opts.Password
+	}
+
+	prefixes := []string{}
+	if opts.Heads {
+		prefixes = append(prefixes, "refs/heads/")
+	}
+	if opts.Tags {
+		prefixes = append(prefixes, "refs/tags/")
+	}
+	session, err := r.connectUploadPack(remote, userName, password)
+	if err != nil {
+		return nil, err
+	}
+	adv, err := session.listRefs(prefixes)
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.Patterns) == 0 {
+		return adv, nil
+	}
+
+	return adv.filterFunc(func(name string) bool {
+		return matchRefPattern(opts.Patterns, name)
+	}), nil
+}
+
+// matchRefPattern reports whether the trailing components of name match
+// one of patterns, so "main" matches refs/heads/main but not
+// refs/heads/domain.
+func matchRefPattern(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		tail := name
+		for {
+			if matched, _ := path.Match(pattern, tail); matched {
+				return true
+			}
+			_, rest, found := strings.Cut(tail, "/")
+			if !found {
+				break
+			}
+			tail = rest
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package repo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchRefPattern(t *testing.T) {
+	for _, test := range []struct {
+		patterns []string
+		name     string
+		want     bool
+	}{
+		{[]string{"main"}, "refs/heads/main", true},
+		{[]string{"main"}, "refs/heads/domain", false},
+		{[]string{"heads/main"}, "refs/heads/main", true},
+		{[]string{"release-*"}, "refs/tags/release-1", true},
+		{[]string{"v1"}, "refs/tags/v1^{}", false},
+		{[]string{"other", "v1"}, "refs/tags/v1", true},
+		{nil, "refs/heads/main", false},
+	} {
+		if got := matchRefPattern(test.patterns, test.name); got != test.want {
+			t.Errorf("matchRefPattern(%q, %s) = %v", test.patterns, test.name, got)
+		}
+	}
+}
+
+func TestListRemote(t *testing.T) {
+	r := initTestRepo(t)
+	url, _ := v2Server(t, false)
+	for _, test := range []struct {
+		opts ListRemoteOptions
+		want string
+	}{
+		{ListRemoteOptions{}, "HEAD refs/heads/main refs/tags/v1"},
+		{ListRemoteOptions{Heads: true}, "refs/heads/main"},
+		{ListRemoteOptions{Tags: true}, "refs/tags/v1"},
+		{ListRemoteOptions{Patterns: []string{"main"}}, "refs/heads/main"},
+		{ListRemoteOptions{Heads: true, Patterns: []string{"v1"}}, ""},
+	} {
+		test.opts.Remote = url
+		adv, err := r.ListRemote(test.opts)
+		if err != nil {
+			t.Fatalf("ListRemote(%+v): %v", test.opts, err)
+		}
+		names := []string{}
+		for _, ref := range adv.Refs {
+			names = append(names, ref.Name)
+		}
+		if got := strings.Join(names, " "); got != test.want {
+			t.Errorf("ListRemote(%+v) lists %q, want %q", test.opts, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package repo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// errHTTPUnauthorized is returned for a 401 answer, which asks for
+// credentials.
+var errHTTPUnauthorized = errors.New("authentication required")
+
+const (
+	// agent identifies gogit to servers.
+	agent = "gogit/1.0"
+	// protocolV2 is the Git-Protocol header value that asks for protocol v2.
+	protocolV2 = "version=2"
+)
+
+// splitCredentials removes the user name and password from an http URL and
+// returns them separately.
+func splitCredentials(remote string) (string, string, string) {
+	if !isHTTPURL(remote) {
+		// ssh URLs keep their user name, it is not a credential
+		return strings.TrimSuffix(remote, "/"), "", ""
+	}
+	parsed, err := url.Parse(remote)
+	if err != nil || parsed.User == nil {
+		return strings.TrimSuffix(remote, "/"), "", ""
+	}
+	userName := parsed.User.Username()
+	password, _ := parsed.User.Password()
+	parsed.User = nil
+	return strings.TrimSuffix(parsed.String(), "/"), userName, password
+}
+
+// gitRequest sends a smart HTTP request and returns the response body.
+// Basic auth is only sent when credentials are given, and protocol, if set,
+// is passed as the Git-Protocol header.
+func gitRequest(req *http.Request, userName, password, protocol string) ([]byte, error) {
+	if userName != "" || password != "" {
+		req.SetBasicAuth(userName, password)
+	}
+	if protocol != "" {
+		req.Header.Set("Git-Protocol", protocol)
+	}
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get response: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, errHTTPUnauthorized
+	}
+	if resp.StatusCode != http.StatusOK {
+		message := strings.TrimSpace(string(body))
+		if message != "" && len(message) < 200 {
+			return nil, fmt.Errorf("unable to access %s: %s %s", req.URL.Redacted(), resp.Status, message)
+		}
+		return nil, fmt.Errorf("unable to access %s: %s", req.URL.Redacted(), resp.Status)
+	}
+	return body, nil
+}
+
+func gitGetPack(url, userName, password, protocol string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	return gitRequest(req, userName, password, protocol)
+}
+
+// gitServiceRequest posts a request to service, e.g. git-upload-pack, and
+// returns the response.
+func gitServiceRequest(remote, service, userName, password, protocol string, data []byte) ([]byte, error) {
+	req, err := http.NewRequest("POST", remote+"/"+service, bytes.NewBuffer(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-"+service+"-request")
+	req.Header.Set("Accept", "application/x-"+service+"-result")
+	return gitRequest(req, userName, password, protocol)
+}
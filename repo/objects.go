@@ -0,0 +1,282 @@
+package repo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrObjectNotFound is returned when an object is not in the object store.
+var ErrObjectNotFound = errors.New("object not found")
+
+// reachableObjects returns every object reachable from tips that is not
+// reachable from exclude. Excluded objects missing locally are ignored,
+// since the remote may have history we never fetched.
+func (r *Repository) reachableObjects(tips, exclude []string) ([]string, error) {
+	seen := map[string]bool{}
+	for _, object := range exclude {
+		if object == "" || object == zeroHash || !r.HasObject(object) {
+			continue
+		}
+		if err := r.walkObjects(object, "", seen, nil); err != nil {
+			return nil, err
+		}
+	}
+	objects := []string{}
+	for _, object := range tips {
+		if object == "" || object == zeroHash {
+			continue
+		}
+		if err := r.walkObjects(object, "", seen, &objects); err != nil {
+			return nil, err
+		}
+	}
+	return objects, nil
+}
+
+// walkObjects marks object and everything it references as seen, appending
+// newly seen objects to objects when it is not nil. objectType may be given
+// when it is already known, so blobs do not have to be read.
+func (r *Repository) walkObjects(object, objectType string, seen map[string]bool, objects *[]string) error {
+	if seen[object] {
+		return nil
+	}
+	seen[object] = true
+	if objects != nil {
+		*objects = append(*objects, object)
+	}
+	if objectType == "blob" {
+		return nil
+	}
+
+	objectType, contents, err := r.ReadObject(object)
+	if err != nil {
+		return err
+	}
+	switch objectType {
+	case "commit":
+		commit, err := parseCommit(contents)
+		if err != nil {
+			return err
+		}
+		if err := r.walkObjects(commit.Tree, "tree", seen, objects); err != nil {
+			return err
+		}
+		for _, parent := range commit.Parents {
+			if err := r.walkObjects(parent, "commit", seen, objects); err != nil {
+				return err
+			}
+		}
+	case "tree":
+		entries, err := parseTree(contents)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if entry.IsSubmodule() {
+				continue
+			}
+			if err := r.walkObjects(entry.Hash, entry.Type(), seen, objects); err != nil {
+				return err
+			}
+		}
+	case "tag":
+		target, _, err := parseTagTarget(contents)
+		if err != nil {
+			return err
+		}
+		return r.walkObjects(target, "", seen, objects)
+	}
+	return nil
+}
+
+// HasObject reports whether object is in the object store.
+func (r *Repository) HasObject(object string) bool {
+	objectPath, err := r.objectPath(object)
+	if err != nil {
+		return false
+	}
+	if _, err := os.Stat(objectPath); err == nil {
+		return true
+	}
+	_, _, found := r.findPacked(object)
+	return found
+}
+
+// isAncestor reports whether ancestor is reachable from commit.
+func (r *Repository) isAncestor(ancestor, commit string) (bool, error) {
+	seen := map[string]bool{}
+	queue := []string{commit}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == ancestor {
+			return true, nil
+		}
+		if seen[current] {
+			continue
+		}
+		seen[current] = true
+		parsed, err := r.ReadCommit(current)
+		if err != nil {
+			return false, err
+		}
+		queue = append(queue, parsed.Parents...)
+	}
+	return false, nil
+}
+
+// objectPath returns the path of the loose object file for object.
+func (r *Repository) objectPath(object string) (string, error) {
+	if len(object) != 40 {
+		return "", fmt.Errorf("invalid object name %q", object)
+	}
+	if _, err := hex.DecodeString(object); err != nil {
+		return "", fmt.Errorf("invalid object name %q", object)
+	}
+	return r.path("objects", object[:2], object[2:]), nil
+}
+
+// ReadObjectInfo returns the type and size of object without reading its
+// contents.
+func (r *Repository) ReadObjectInfo(object string) (string, int, error) {
+	objectPath, err := r.objectPath(object)
+	if err != nil {
+		return "", 0, err
+	}
+	objectFile, err := os.Open(objectPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			if pack, offset, found := r.findPacked(object); found {
+				return r.readPackedObjectInfo(pack, offset)
+			}
+			return "", 0, fmt.Errorf("%w: %s", ErrObjectNotFound, object)
+		}
+		return "", 0, fmt.Errorf("failed to open object file: %w", err)
+	}
+	defer objectFile.Close()
+	zlibReader, err := zlib.NewReader(objectFile)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read object %s: %w", object, err)
+	}
+	defer zlibReader.Close()
+
+	header, err := bufio.NewReader(zlibReader).ReadString(0)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid object format: %s", object)
+	}
+	objectType, size, found := strings.Cut(strings.TrimSuffix(header, "\x00"), " ")
+	n, err := strconv.Atoi(size)
+	if !found || err != nil {
+		return "", 0, fmt.Errorf("invalid object format: %s", object)
+	}
+	return objectType, n, nil
+}
+
+// ReadObject returns the type and contents of object.
+func (r *Repository) ReadObject(object string) (string, []byte, error) {
+	objectPath, err := r.objectPath(object)
+	if err != nil {
+		return "", nil, err
+	}
+	objectFile, err := os.Open(objectPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			if pack, offset, found := r.findPacked(object); found {
+				return r.readPackedObject(pack, offset)
+			}
+			return "", nil, fmt.Errorf("%w: %s", ErrObjectNotFound, object)
+		}
+		return "", nil, fmt.Errorf("failed to open object file: %w", err)
+	}
+	defer objectFile.Close()
+	zlibReader, err := zlib.NewReader(objectFile)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read object %s: %w", object, err)
+	}
+	defer zlibReader.Close()
+
+	raw, err := io.ReadAll(zlibReader)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read object %s: %w", object, err)
+	}
+
+	nullIndex := bytes.IndexByte(raw, 0)
+	if nullIndex == -1 {
+		return "", nil, fmt.Errorf("invalid object format: %s", object)
+	}
+	objectType, size, found := strings.Cut(string(raw[:nullIndex]), " ")
+	if !found {
+		return "", nil, fmt.Errorf("invalid object format: %s", object)
+	}
+	contents := raw[nullIndex+1:]
+	if n, err := strconv.Atoi(size); err != nil || n != len(contents) {
+		return "", nil, fmt.Errorf("invalid object size: %s", object)
+	}
+	return objectType, contents, nil
+}
+
+/**
+ * writeToObjectFile is a function that takes a reader, a file hash, an object type and a size
+ * and writes the object to the .git/objects directory
+ * The object is written in the form "<type> <size>\x00\<content>"
+ */
+
+func (r *Repository) writeToObjectFile(reader io.Reader, fileHash string, objectType string, sz int) error {
+
+	// objects never change, so one already there is left alone
+	if r.HasObject(fileHash) {
+		return nil
+	}
+	objectPath, err := r.objectPath(fileHash)
+	if err != nil {
+		return err
+	}
+	if err := createDir(r.path("objects", fileHash[:2])); err != nil {
+		return err
+	}
+	return writeFileAtomic(objectPath, 0444, func(w io.Writer) error {
+		zlibWriter, _ := zlib.NewWriterLevel(w, zlib.DefaultCompression)
+		header := fmt.Sprintf("%s %d\x00", objectType, sz)
+		if _, err := zlibWriter.Write([]byte(header)); err != nil {
+			return err
+		}
+		if _, err := io.Copy(zlibWriter, reader); err != nil {
+			return err
+		}
+		return zlibWriter.Close()
+	})
+}
+
+// WriteObject stores data as an object of the given type and returns its hash.
+func (r *Repository) WriteObject(objectType string, data []byte) (string, error) {
+	hash := HashObject(bytes.NewReader(data), objectType, len(data))
+	if err := r.writeToObjectFile(bytes.NewReader(data), hash, objectType, len(data)); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+/**
+ * HashObject is a function that takes a reader, an object type and a size and
+ * returns the sha1 hash of the object in form "<type> <size>\x00\<content>""
+ */
+func HashObject(reader io.Reader, objectType string, sz int) string {
+
+	header := fmt.Sprintf("%s %d\x00", objectType, sz)
+	hasher := sha1.New()
+	hasher.Write([]byte(header))
+
+	io.Copy(hasher, reader)
+
+	return hex.EncodeToString(hasher.Sum(nil))
+
+}
@@ -0,0 +1,100 @@
+package repo
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// failingReader returns some data and then an error, like a file that
+// cannot be read to its end.
+type failingReader struct {
+	data io.Reader
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	n, err := f.data.Read(p)
+	if err == io.EOF {
+		return n, errors.New("read failed")
+	}
+	return n, err
+}
+
+// looseObjects returns the hashes of the loose objects of r.
+func looseObjects(t *testing.T, r *Repository) []string {
+	t.Helper()
+	objects := []string{}
+	dir := filepath.Join(r.GitDir(), "objects")
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		if hash := strings.ReplaceAll(filepath.ToSlash(rel), "/", ""); len(hash) == 40 {
+			objects = append(objects, hash)
+		} else if !strings.HasPrefix(rel, "pack") && !strings.HasPrefix(rel, "info") {
+			t.Errorf("unexpected file %s in objects", rel)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return objects
+}
+
+func TestWriteObject(t *testing.T) {
+	r := initTestRepo(t)
+	hash, err := r.WriteObject("blob", []byte("hello\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != "ce013625030ba8dba906f756967f9e9ca394464a" {
+		t.Errorf("hash is %s", hash)
+	}
+
+	// rewriting an object that exists must not touch it, even if the new
+	// contents cannot be read
+	broken := &failingReader{strings.NewReader("hel")}
+	if err := r.writeToObjectFile(broken, hash, "blob", 6); err != nil {
+		t.Errorf("rewriting an existing object: %v", err)
+	}
+	objectType, data, err := r.ReadObject(hash)
+	if err != nil || objectType != "blob" || string(data) != "hello\n" {
+		t.Errorf("object is %s %q, %v", objectType, data, err)
+	}
+
+	// a failed write leaves nothing behind
+	missing := HashObject(strings.NewReader("other\n"), "blob", 6)
+	broken = &failingReader{strings.NewReader("oth")}
+	if err := r.writeToObjectFile(broken, missing, "blob", 6); err == nil {
+		t.Error("writing from a failing reader succeeded")
+	}
+	if r.HasObject(missing) {
+		t.Error("a failed write left the object behind")
+	}
+	for _, object := range looseObjects(t, r) {
+		if _, _, err := r.ReadObject(object); err != nil {
+			t.Errorf("object %s is corrupt: %v", object, err)
+		}
+	}
+}
+
+func TestAddDirectoryLeavesNoCorruptObject(t *testing.T) {
+	r := initTestRepo(t)
+	if err := os.MkdirAll(filepath.Join(r.WorkTree(), "d"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Add([]string{"d"}); err == nil {
+		t.Error("adding a directory succeeded")
+	}
+	for _, object := range looseObjects(t, r) {
+		if _, _, err := r.ReadObject(object); err != nil {
+			t.Errorf("object %s is corrupt: %v", object, err)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package repo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// packedRefsHeader starts the packed-refs files gogit writes. It promises
+// readers that entries are sorted and that every annotated tag is followed
+// by the object it fully peels to.
+const packedRefsHeader = "# pack-refs with: peeled fully-peeled sorted \n"
+
+// packedRef is an entry of the packed-refs file.
+type packedRef struct {
+	Ref
+	// peeled is the object the annotated tag Hash points to, if recorded
+	// on a "^<hash>" line.
+	peeled string
+}
+
+// readPackedRefs returns the entries of packed-refs sorted by name, none if
+// the file does not exist. The result is cached until the file changes.
+func (r *Repository) readPackedRefs() ([]packedRef, error) {
+	r.packedMutex.Lock()
+	defer r.packedMutex.Unlock()
+	info, err := os.Stat(r.path("packed-refs"))
+	if os.IsNotExist(err) {
+		r.packed, r.packedInfo = nil, nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if r.packedInfo != nil && os.SameFile(info, r.packedInfo) && info.ModTime().Equal(r.packedInfo.ModTime()) && info.Size() == r.packedInfo.Size() {
+		return r.packed, nil
+	}
+
+	data, err := os.ReadFile(r.path("packed-refs"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read packed-refs: %w", err)
+	}
+	refs := []packedRef{}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "^"):
+			if len(refs) == 0 {
+				return nil, errors.New("invalid packed-refs: peeled line before any ref")
+			}
+			refs[len(refs)-1].peeled = line[1:]
+		default:
+			hash, name, found := strings.Cut(line, " ")
+			if !found || len(hash) != 40 {
+				return nil, fmt.Errorf("invalid packed-refs line %q", line)
+			}
+			refs = append(refs, packedRef{Ref: Ref{Name: name, Hash: hash}})
+		}
+	}
+	sort.SliceStable(refs, func(i, j int) bool { return refs[i].Name < refs[j].Name })
+	r.packed, r.packedInfo = refs, info
+	return refs, nil
+}
+
+// findPackedRef returns the packed-refs entry of name.
+func (r *Repository) findPackedRef(name string) (packedRef, bool, error) {
+	refs, err := r.readPackedRefs()
+	if err != nil {
+		return packedRef{}, false, err
+	}
+	i := sort.Search(len(refs), func(i int) bool { return refs[i].Name >= name })
+	if i < len(refs) && refs[i].Name == name {
+		return refs[i], true, nil
+	}
+	return packedRef{}, false, nil
+}
+
+// writePackedRefs replaces packed-refs with refs through lock, the lock of
+// packed-refs, releasing it.
+func (r *Repository) writePackedRefs(lock *lockFile, refs []packedRef) error {
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Name < refs[j].Name })
+	var content bytes.Buffer
+	content.WriteString(packedRefsHeader)
+	for _, ref := range refs {
+		fmt.Fprintf(&content, "%s %s\n", ref.Hash, ref.Name)
+		if ref.peeled != "" {
+			fmt.Fprintf(&content, "^%s\n", ref.peeled)
+		}
+	}
+	if _, err := lock.Write(content.Bytes()); err != nil {
+		lock.Rollback()
+		return fmt.Errorf("failed to write packed-refs: %w", err)
+	}
+	return lock.Commit()
+}
+
+// removePackedRef drops name from packed-refs and reports whether it was
+// there.
+func (r *Repository) removePackedRef(name string) (bool, error) {
+	if _, found, err := r.findPackedRef(name); err != nil || !found {
+		return false, err
+	}
+	return r.rewritePackedRefs(func(ref string) (string, bool) {
+		return ref, ref != name
+	})
+}
+
+// rewritePackedRefs renames the entries of packed-refs with rename, which
+// returns the new name and false to drop the entry. It reports whether
+// anything changed.
+func (r *Repository) rewritePackedRefs(rename func(string) (string, bool)) (bool, error) {
+	if _, err := os.Stat(r.path("packed-refs")); os.IsNotExist(err) {
+		return false, nil
+	}
+	lock, err := lock(r.path("packed-refs"))
+	if err != nil {
+		return false, err
+	}
+	defer lock.Rollback()
+	// read under the lock, another process may have changed it
+	refs, err := r.readPackedRefs()
+	if err != nil {
+		return false, err
+	}
+	kept := []packedRef{}
+	changed := false
+	for _, ref := range refs {
+		name, keep := rename(ref.Name)
+		changed = changed || !keep || name != ref.Name
+		if keep {
+			ref.Name = name
+			kept = append(kept, ref)
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+	return true, r.writePackedRefs(lock, kept)
+}
+
+// PackRefsOptions selects what PackRefs packs.
+type PackRefsOptions struct {
+	// All packs every ref instead of only tags and refs already packed.
+	All bool
+	// NoPrune keeps the loose files of the packed refs.
+	NoPrune bool
+}
+
+// PackRefs moves loose refs into packed-refs, as git pack-refs does, which
+// makes repositories with many refs faster to read. Symbolic refs and refs
+// to missing objects stay loose.
+func (r *Repository) PackRefs(opts PackRefsOptions) error {
+	packedLock, err := lock(r.path("packed-refs"))
+	if err != nil {
+		return err
+	}
+	defer packedLock.Rollback()
+
+	existing, err := r.readPackedRefs()
+	if err != nil {
+		return err
+	}
+	loose, err := r.looseRefs()
+	if err != nil {
+		return err
+	}
+	refs := map[string]packedRef{}
+	for _, ref := range existing {
+		refs[ref.Name] = ref
+	}
+	packed := []Ref{}
+	for _, ref := range loose {
+		if ref.symbolic || (!opts.All && !strings.HasPrefix(ref.Name, "refs/tags/")) || !r.HasObject(ref.Hash) {
+			continue
+		}
+		entry := packedRef{Ref: ref.Ref}
+		if peeled, isTag, err := r.peel(ref.Hash); err != nil {
+			return err
+		} else if isTag {
+			entry.peeled = peeled
+		}
+		refs[ref.Name] = entry
+		packed = append(packed, ref.Ref)
+	}
+	entries := make([]packedRef, 0, len(refs))
+	for _, ref := range refs {
+		if ref.peeled == "" && strings.HasPrefix(ref.Name, "refs/tags/") {
+			// tags packed by an older writer may lack their peeled line
+			if peeled, isTag, err := r.peel(ref.Hash); err == nil && isTag {
+				ref.peeled = peeled
+			}
+		}
+		entries = append(entries, ref)
+	}
+	if err := r.writePackedRefs(packedLock, entries); err != nil {
+		return err
+	}
+	if opts.NoPrune {
+		return nil
+	}
+	for _, ref := range packed {
+		if err := r.pruneLooseRef(ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneLooseRef removes the loose file of a ref that was packed, unless it
+// moved since.
+func (r *Repository) pruneLooseRef(ref Ref) error {
+	lock, err := lock(r.path(ref.Name))
+	if errors.Is(err, ErrLocked) {
+		// someone is updating it, their value wins over the packed one
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(r.path(ref.Name))
+	if err != nil || strings.TrimSpace(string(data)) != ref.Hash {
+		lock.Rollback()
+		return nil
+	}
+	err = os.Remove(r.path(ref.Name))
+	lock.Rollback()
+	if err != nil {
+		return err
+	}
+	removeEmptyRefDirs(r.path(ref.Name), ref.Name)
+	return nil
+}
+
+// looseRef is a ref stored in its own file under refs/.
+type looseRef struct {
+	Ref
+	symbolic bool
+}
+
+// looseRefs returns the refs stored as files under refs/, with the value of
+// symbolic refs resolved. Dangling symbolic refs are left out.
+func (r *Repository) looseRefs() ([]looseRef, error) {
+	refs := []looseRef{}
+	err := filepath.Walk(r.path("refs"), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || strings.HasSuffix(path, ".lock") {
+			return nil
+		}
+		rel, err := filepath.Rel(filepath.Dir(r.path("refs")), path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read ref %s: %w", name, err)
+		}
+		symbolic := strings.HasPrefix(string(data), "ref: ")
+		hash, err := r.ReadRef(name)
+		if symbolic && errors.Is(err, ErrRefNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		refs = append(refs, looseRef{Ref: Ref{Name: name, Hash: hash}, symbolic: symbolic})
+		return nil
+	})
+	return refs, err
+}
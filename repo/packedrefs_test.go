@@ -0,0 +1,167 @@
+package repo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPackRefs(t *testing.T) {
+	r := initTestRepo(t)
+	first := commitFile(t, r, "a", "1\n")
+	second := commitFile(t, r, "a", "2\n")
+	if err := r.WriteRef("refs/heads/topic", first, "branch"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.CreateTag(TagOptions{Name: "light", Target: first}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.CreateTag(TagOptions{Name: "v1", Annotated: true, Message: "v1"}); err != nil {
+		t.Fatal(err)
+	}
+	tag, _ := r.ReadRef("refs/tags/v1")
+
+	// only tags by default
+	if err := r.PackRefs(PackRefsOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(r.GitDir(), "packed-refs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := packedRefsHeader + first + " refs/tags/light\n" + tag + " refs/tags/v1\n^" + second + "\n"
+	if string(data) != want {
+		t.Errorf("packed-refs is\n%s\nwant\n%s", data, want)
+	}
+	for _, name := range []string{"refs/tags/light", "refs/tags/v1"} {
+		if _, err := os.Stat(filepath.Join(r.GitDir(), name)); !os.IsNotExist(err) {
+			t.Errorf("the loose file of %s was kept: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(r.GitDir(), "refs", "tags")); err != nil {
+		t.Errorf("refs/tags was removed: %v", err)
+	}
+	if hash, err := r.ReadRef("refs/tags/v1"); err != nil || hash != tag {
+		t.Errorf("v1 is %s, %v", hash, err)
+	}
+	if resolved, err := r.ResolveRevision("v1^{commit}"); err != nil || resolved != second {
+		t.Errorf("v1^{commit} is %s, %v", resolved, err)
+	}
+
+	if err := r.PackRefs(PackRefsOptions{All: true, NoPrune: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(r.GitDir(), "refs", "heads", "topic")); err != nil {
+		t.Errorf("the loose topic was pruned: %v", err)
+	}
+	if err := r.PackRefs(PackRefsOptions{All: true}); err != nil {
+		t.Fatal(err)
+	}
+	refs, err := r.ListRefs("refs/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, ref := range refs {
+		names = append(names, ref.Name)
+	}
+	if got := strings.Join(names, " "); got != "refs/heads/master refs/heads/topic refs/tags/light refs/tags/v1" {
+		t.Errorf("the refs are %s", got)
+	}
+	if head, err := r.ReadRef("HEAD"); err != nil || head != second {
+		t.Errorf("HEAD is %s, %v, through a packed branch", head, err)
+	}
+
+	// loose refs win over packed ones
+	if err := r.UpdateRef("refs/heads/topic", second, first, "move"); err != nil {
+		t.Fatal(err)
+	}
+	if hash, err := r.ReadRef("refs/heads/topic"); err != nil || hash != second {
+		t.Errorf("topic is %s, %v", hash, err)
+	}
+	refs, err = r.ListRefs("refs/heads/topic")
+	if err != nil || len(refs) != 1 || refs[0].Hash != second {
+		t.Errorf("topic is listed as %+v, %v", refs, err)
+	}
+
+	// deleting removes both copies
+	if err := r.DeleteRef("refs/heads/topic"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadRef("refs/heads/topic"); !errors.Is(err, ErrRefNotFound) {
+		t.Errorf("the deleted topic reads as %v", err)
+	}
+	if err := r.DeleteRef("refs/tags/light"); err != nil {
+		t.Fatalf("deleting a packed tag: %v", err)
+	}
+	if _, err := r.ReadRef("refs/tags/light"); !errors.Is(err, ErrRefNotFound) {
+		t.Errorf("the deleted tag reads as %v", err)
+	}
+}
+
+func TestReadPackedRefs(t *testing.T) {
+	r := initTestRepo(t)
+	file := filepath.Join(r.GitDir(), "packed-refs")
+	hash := strings.Repeat("1", 40)
+	// unsorted and with an older header, as other writers may leave it
+	content := "# pack-refs with: peeled\n" + hash + " refs/tags/z\n^" + strings.Repeat("2", 40) + "\n" + hash + " refs/heads/a\n"
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	refs, err := r.readPackedRefs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 2 || refs[0].Name != "refs/heads/a" || refs[1].peeled != strings.Repeat("2", 40) {
+		t.Errorf("packed refs are %+v", refs)
+	}
+
+	for _, bad := range []string{"^" + hash + "\n", "abc refs/heads/a\n", hash + "\n"} {
+		if err := os.WriteFile(file, []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if refs, err := r.readPackedRefs(); err == nil {
+			t.Errorf("%q parsed as %+v", bad, refs)
+		}
+	}
+}
+
+func TestRemotePackedRefs(t *testing.T) {
+	r := initTestRepo(t)
+	head := commitFile(t, r, "a", "1\n")
+	if err := r.AddRemote("origin", "/srv/origin.git"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"refs/remotes/origin/master", "refs/remotes/origin/packed"} {
+		if err := r.WriteRef(name, head, "fetch"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.PackRefs(PackRefsOptions{All: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.WriteRef("refs/remotes/origin/loose", head, "fetch"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.RenameRemote("origin", "upstream"); err != nil {
+		t.Fatal(err)
+	}
+	for prefix, want := range map[string]int{"refs/remotes/origin/": 0, "refs/remotes/upstream/": 3} {
+		if refs, err := r.ListRefs(prefix); err != nil || len(refs) != want {
+			t.Errorf("%s has %+v, %v, want %d refs", prefix, refs, err, want)
+		}
+	}
+
+	if err := r.RemoveRemote("upstream"); err != nil {
+		t.Fatal(err)
+	}
+	if refs, err := r.ListRefs("refs/remotes/"); err != nil || len(refs) != 0 {
+		t.Errorf("after removing the remote the refs are %+v, %v", refs, err)
+	}
+	if hash, err := r.ReadRef("refs/heads/master"); err != nil || hash != head {
+		t.Errorf("master is %s, %v", hash, err)
+	}
+}
@@ -0,0 +1,336 @@
+package repo
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// pack object types, as stored in the entry header
+const (
+	packCommit   = 1
+	packTree     = 2
+	packBlob     = 3
+	packTag      = 4
+	packOfsDelta = 6
+	packRefDelta = 7
+)
+
+var packTypeNames = map[int]string{
+	packCommit: "commit",
+	packTree:   "tree",
+	packBlob:   "blob",
+	packTag:    "tag",
+}
+
+// packObject is an object read from a pack, after delta resolution.
+type packObject struct {
+	offset     int
+	objectType string
+	data       []byte
+	hash       string
+	// crc is the CRC-32 of the raw entry, as stored in pack indexes.
+	crc uint32
+}
+
+// pendingDelta is a delta whose base has not been resolved yet.
+type pendingDelta struct {
+	offset     int
+	baseOffset int
+	baseHash   string
+	delta      []byte
+	crc        uint32
+}
+
+// readPack parses a version 2 pack and resolves its deltas. Bases of
+// ref-deltas that are not in the pack are looked up in the repository.
+func (r *Repository) readPack(pack []byte) ([]packObject, error) {
+	if len(pack) < 32 || string(pack[:4]) != "PACK" {
+		return nil, errors.New("invalid pack: bad signature")
+	}
+	if version := binary.BigEndian.Uint32(pack[4:8]); version != 2 && version != 3 {
+		return nil, fmt.Errorf("invalid pack: unsupported version %d", version)
+	}
+	checksum := sha1.Sum(pack[:len(pack)-20])
+	if !bytes.Equal(checksum[:], pack[len(pack)-20:]) {
+		return nil, errors.New("invalid pack: checksum mismatch")
+	}
+	count := int(binary.BigEndian.Uint32(pack[8:12]))
+	body := pack[:len(pack)-20]
+
+	objects := []packObject{}
+	byOffset := map[int]int{}
+	byHash := map[string]int{}
+	deltas := []pendingDelta{}
+
+	offset := 12
+	for i := 0; i < count; i++ {
+		if offset >= len(body) {
+			return nil, errors.New("invalid pack: truncated")
+		}
+		start := offset
+		objectType, size, n := readPackEntryHeader(body[offset:])
+		if n == 0 {
+			return nil, errors.New("invalid pack: bad entry header")
+		}
+		offset += n
+
+		delta := pendingDelta{offset: start}
+		switch objectType {
+		case packOfsDelta:
+			distance, n := readOffsetDelta(body[offset:])
+			if n == 0 || distance > start {
+				return nil, errors.New("invalid pack: bad delta offset")
+			}
+			delta.baseOffset = start - distance
+			offset += n
+		case packRefDelta:
+			if offset+20 > len(body) {
+				return nil, errors.New("invalid pack: truncated")
+			}
+			delta.baseHash = fmt.Sprintf("%x", body[offset:offset+20])
+			offset += 20
+		}
+
+		data, n, err := inflate(body[offset:], size)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pack: %w", err)
+		}
+		offset += n
+		crc := crc32.ChecksumIEEE(body[start:offset])
+
+		if objectType == packOfsDelta || objectType == packRefDelta {
+			delta.delta = data
+			delta.crc = crc
+			deltas = append(deltas, delta)
+			continue
+		}
+		typeName, ok := packTypeNames[objectType]
+		if !ok {
+			return nil, fmt.Errorf("invalid pack: unknown object type %d", objectType)
+		}
+		object := packObject{offset: start, objectType: typeName, data: data, crc: crc}
+		object.hash = HashObject(bytes.NewReader(data), typeName, len(data))
+		byOffset[start] = len(objects)
+		byHash[object.hash] = len(objects)
+		objects = append(objects, object)
+	}
+
+	// resolve deltas until none are left, since a delta can be based on
+	// another delta that appears later in the pack
+	for len(deltas) > 0 {
+		remaining := deltas[:0]
+		for _, delta := range deltas {
+			var base *packObject
+			if delta.baseHash != "" {
+				if index, found := byHash[delta.baseHash]; found {
+					base = &objects[index]
+				} else if r.HasObject(delta.baseHash) {
+					objectType, data, err := r.ReadObject(delta.baseHash)
+					if err != nil {
+						return nil, err
+					}
+					base = &packObject{objectType: objectType, data: data, hash: delta.baseHash}
+				}
+			} else if index, found := byOffset[delta.baseOffset]; found {
+				base = &objects[index]
+			}
+			if base == nil {
+				remaining = append(remaining, delta)
+				continue
+			}
+
+			data, err := applyDelta(base.data, delta.delta)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pack: %w", err)
+			}
+			object := packObject{offset: delta.offset, objectType: base.objectType, data: data, crc: delta.crc}
+			object.hash = HashObject(bytes.NewReader(data), object.objectType, len(data))
+			byOffset[delta.offset] = len(objects)
+			byHash[object.hash] = len(objects)
+			objects = append(objects, object)
+		}
+		if len(remaining) == len(deltas) {
+			return nil, fmt.Errorf("invalid pack: %d deltas have missing bases", len(remaining))
+		}
+		deltas = remaining
+	}
+	return objects, nil
+}
+
+// unpackObjects stores every object of a pack as a loose object and
+// returns how many there were.
+func (r *Repository) unpackObjects(pack []byte) (int, error) {
+	objects, err := r.readPack(pack)
+	if err != nil {
+		return 0, err
+	}
+	for _, object := range objects {
+		if r.HasObject(object.hash) {
+			continue
+		}
+		if _, err := r.WriteObject(object.objectType, object.data); err != nil {
+			return 0, err
+		}
+	}
+	return len(objects), nil
+}
+
+// readPackEntryHeader decodes the type and inflated size that start a pack
+// entry and returns them with the number of bytes used.
+func readPackEntryHeader(data []byte) (int, int, int) {
+	if len(data) == 0 {
+		return 0, 0, 0
+	}
+	c := data[0]
+	objectType := int(c>>4) & 7
+	size := int(c & 0x0f)
+	shift := 4
+	n := 1
+	for c&0x80 != 0 {
+		// more than fits in an int is a broken or hostile pack
+		if n >= len(data) || shift > 56 {
+			return 0, 0, 0
+		}
+		c = data[n]
+		size |= int(c&0x7f) << shift
+		shift += 7
+		n++
+	}
+	return objectType, size, n
+}
+
+// readOffsetDelta decodes the base distance of an ofs-delta entry.
+func readOffsetDelta(data []byte) (int, int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+	c := data[0]
+	offset := int(c & 0x7f)
+	n := 1
+	for c&0x80 != 0 {
+		if n >= len(data) || offset > math.MaxInt>>8 {
+			return 0, 0
+		}
+		c = data[n]
+		offset = ((offset + 1) << 7) | int(c&0x7f)
+		n++
+	}
+	return offset, n
+}
+
+// inflate decompresses the zlib stream at the start of data, which must
+// hold size bytes, and returns it with the number of compressed bytes
+// consumed. size comes from the pack, so it is not trusted: the buffer
+// grows as data arrives and reading stops once the stream is too long.
+func inflate(data []byte, size int) ([]byte, int, error) {
+	reader := bytes.NewReader(data)
+	zlibReader, err := zlib.NewReader(reader)
+	if err != nil {
+		return nil, 0, err
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, min(size, len(data))))
+	if _, err := io.Copy(buf, io.LimitReader(zlibReader, int64(size)+1)); err != nil {
+		return nil, 0, err
+	}
+	if err := zlibReader.Close(); err != nil {
+		return nil, 0, err
+	}
+	if buf.Len() != size {
+		return nil, 0, fmt.Errorf("inflated size %d does not match %d", buf.Len(), size)
+	}
+	return buf.Bytes(), len(data) - reader.Len(), nil
+}
+
+// applyDelta rebuilds an object from its base and a git delta.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	readSize := func() (int, error) {
+		size, shift := 0, 0
+		for {
+			if len(delta) == 0 {
+				return 0, errors.New("truncated delta")
+			}
+			c := delta[0]
+			delta = delta[1:]
+			if shift > 56 {
+				return 0, errors.New("delta size too large")
+			}
+			size |= int(c&0x7f) << shift
+			shift += 7
+			if c&0x80 == 0 {
+				return size, nil
+			}
+		}
+	}
+	baseSize, err := readSize()
+	if err != nil {
+		return nil, err
+	}
+	if baseSize != len(base) {
+		return nil, errors.New("delta base size mismatch")
+	}
+	resultSize, err := readSize()
+	if err != nil {
+		return nil, err
+	}
+
+	// the sizes come from the pack, so the result grows as it is built and
+	// may never get longer than it claims
+	result := make([]byte, 0, min(resultSize, len(base)+len(delta)))
+	for len(delta) > 0 {
+		if len(result) > resultSize {
+			return nil, errors.New("delta result size mismatch")
+		}
+		op := delta[0]
+		delta = delta[1:]
+		switch {
+		case op&0x80 != 0:
+			// copy from the base
+			offset, size := 0, 0
+			for i := 0; i < 4; i++ {
+				if op&(1<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, errors.New("truncated delta")
+					}
+					offset |= int(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+			for i := 0; i < 3; i++ {
+				if op&(0x10<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, errors.New("truncated delta")
+					}
+					size |= int(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > len(base) {
+				return nil, errors.New("delta copy out of range")
+			}
+			result = append(result, base[offset:offset+size]...)
+		case op != 0:
+			// insert literal bytes
+			if int(op) > len(delta) {
+				return nil, errors.New("truncated delta")
+			}
+			result = append(result, delta[:op]...)
+			delta = delta[op:]
+		default:
+			return nil, errors.New("invalid delta opcode 0")
+		}
+	}
+	if len(result) != resultSize {
+		return nil, errors.New("delta result size mismatch")
+	}
+	return result, nil
+}
@@ -0,0 +1,90 @@
+package repo
+
+import (
+	"bytes"
+	"compress/zlib"
+	"testing"
+)
+
+func compress(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestInflate(t *testing.T) {
+	data := bytes.Repeat([]byte("gogit "), 1000)
+	stream := append(compress(t, data), "next entry"...)
+	inflated, n, err := inflate(stream, len(data))
+	if err != nil || !bytes.Equal(inflated, data) || n != len(stream)-len("next entry") {
+		t.Errorf("inflate returned %d bytes, %d consumed, %v", len(inflated), n, err)
+	}
+
+	// sizes come from the pack and may lie in both directions
+	for _, size := range []int{len(data) - 1, len(data) + 1, 1 << 50} {
+		if _, _, err := inflate(stream, size); err == nil {
+			t.Errorf("inflate accepted the size %d for %d bytes", size, len(data))
+		}
+	}
+	// a small stream that inflates to far more than it claims
+	bomb := compress(t, make([]byte, 64<<20))
+	if _, _, err := inflate(bomb, 10); err == nil {
+		t.Error("inflate accepted a stream longer than its size")
+	}
+}
+
+// deltaSize encodes a size as deltas start with it.
+func deltaSize(size int) []byte {
+	encoded := []byte{}
+	for {
+		c := byte(size & 0x7f)
+		size >>= 7
+		if size == 0 {
+			return append(encoded, c)
+		}
+		encoded = append(encoded, c|0x80)
+	}
+}
+
+func TestApplyDelta(t *testing.T) {
+	base := []byte("hello world")
+	// copy "hello ", insert "gogit"
+	ops := []byte{0x80 | 0x01 | 0x10, 0, 6, 5, 'g', 'o', 'g', 'i', 't'}
+	delta := append(append(deltaSize(len(base)), deltaSize(11)...), ops...)
+	result, err := applyDelta(base, delta)
+	if err != nil || string(result) != "hello gogit" {
+		t.Errorf("applyDelta returned %q, %v", result, err)
+	}
+
+	for name, delta := range map[string][]byte{
+		"huge result size": append(append(deltaSize(len(base)), deltaSize(1<<50)...), ops...),
+		"short result":     append(append(deltaSize(len(base)), deltaSize(12)...), ops...),
+		"long result":      append(append(deltaSize(len(base)), deltaSize(3)...), ops...),
+		"size overflow":    append(deltaSize(len(base)), bytes.Repeat([]byte{0xff}, 12)...),
+		"copy out of base": append(append(deltaSize(len(base)), deltaSize(20)...), 0x80|0x01|0x10, 5, 20),
+		"truncated insert": append(append(deltaSize(len(base)), deltaSize(5)...), 5, 'g'),
+		"wrong base size":  append(append(deltaSize(3), deltaSize(11)...), ops...),
+	} {
+		if _, err := applyDelta(base, delta); err == nil {
+			t.Errorf("%s: applyDelta succeeded", name)
+		}
+	}
+}
+
+func TestReadPackEntryHeader(t *testing.T) {
+	objectType, size, n := readPackEntryHeader([]byte{0x80 | packBlob<<4 | 0x5, 0x01})
+	if objectType != packBlob || size != 5|1<<4 || n != 2 {
+		t.Errorf("header is type %d size %d in %d bytes", objectType, size, n)
+	}
+	if _, _, n := readPackEntryHeader(bytes.Repeat([]byte{0xff}, 12)); n != 0 {
+		t.Error("a size overflowing an int was accepted")
+	}
+	if _, n := readOffsetDelta(bytes.Repeat([]byte{0xff}, 12)); n != 0 {
+		t.Error("an offset overflowing an int was accepted")
+	}
+}
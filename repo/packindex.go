@@ -0,0 +1,397 @@
+package repo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultUnpackLimit is the number of objects below which incoming packs
+// are exploded into loose objects instead of being kept.
+const defaultUnpackLimit = 100
+
+// packIndexSignature starts every version 2 pack index.
+var packIndexSignature = []byte{0xff, 't', 'O', 'c'}
+
+// storePack stores the objects of an incoming pack. Small packs become
+// loose objects, larger ones are kept and indexed, like git does with
+// transfer.unpackLimit. limitKey is fetch.unpackLimit or
+// receive.unpackLimit, which take precedence.
+func (r *Repository) storePack(pack []byte, limitKey string) error {
+	limit := defaultUnpackLimit
+	for _, key := range []string{limitKey, "transfer.unpackLimit"} {
+		if value, err := strconv.Atoi(r.configValue(key)); err == nil && value > 0 {
+			limit = value
+			break
+		}
+	}
+	if len(pack) >= 12 && int(binary.BigEndian.Uint32(pack[8:12])) < limit {
+		_, err := r.unpackObjects(pack)
+		return err
+	}
+	_, err := r.indexPack(pack)
+	return err
+}
+
+// indexPack keeps pack in objects/pack with an index next to it and
+// returns its name, pack-<checksum>.
+func (r *Repository) indexPack(pack []byte) (string, error) {
+	objects, err := r.readPack(pack)
+	if err != nil {
+		return "", err
+	}
+	index, err := writePackIndex(pack, objects)
+	if err != nil {
+		return "", err
+	}
+
+	name := "pack-" + hex.EncodeToString(pack[len(pack)-20:])
+	dir := r.path("objects", "pack")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	// the index goes last, a pack without one is ignored
+	for _, file := range []struct {
+		path string
+		data []byte
+	}{
+		{filepath.Join(dir, name+".pack"), pack},
+		{filepath.Join(dir, name+".idx"), index},
+	} {
+		err := writeFileAtomic(file.path, 0444, func(w io.Writer) error {
+			_, err := w.Write(file.data)
+			return err
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	r.packMutex.Lock()
+	r.packs = nil
+	r.packMutex.Unlock()
+	return name, nil
+}
+
+// writePackIndex builds the version 2 index of pack, whose objects have
+// been read by readPack.
+func writePackIndex(pack []byte, objects []packObject) ([]byte, error) {
+	sorted := make([]packObject, len(objects))
+	copy(sorted, objects)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].hash < sorted[j].hash })
+
+	var index bytes.Buffer
+	index.Write(packIndexSignature)
+	binary.Write(&index, binary.BigEndian, uint32(2))
+
+	fanout := [256]uint32{}
+	names := make([][]byte, len(sorted))
+	for i, object := range sorted {
+		name, err := hex.DecodeString(object.hash)
+		if err != nil {
+			return nil, err
+		}
+		names[i] = name
+		for b := int(name[0]); b < 256; b++ {
+			fanout[b]++
+		}
+	}
+	binary.Write(&index, binary.BigEndian, fanout)
+	for _, name := range names {
+		index.Write(name)
+	}
+	for _, object := range sorted {
+		binary.Write(&index, binary.BigEndian, object.crc)
+	}
+	large := []uint64{}
+	for _, object := range sorted {
+		offset := uint64(object.offset)
+		if offset < 1<<31 {
+			binary.Write(&index, binary.BigEndian, uint32(offset))
+			continue
+		}
+		binary.Write(&index, binary.BigEndian, uint32(1<<31|len(large)))
+		large = append(large, offset)
+	}
+	for _, offset := range large {
+		binary.Write(&index, binary.BigEndian, offset)
+	}
+
+	index.Write(pack[len(pack)-20:])
+	checksum := sha1.Sum(index.Bytes())
+	index.Write(checksum[:])
+	return index.Bytes(), nil
+}
+
+// packFile is a kept pack and its loaded index.
+type packFile struct {
+	path  string
+	index []byte
+	count int
+}
+
+// loadPackIndex reads the index of the pack at path, which ends in .pack.
+func loadPackIndex(path string) (*packFile, error) {
+	index, err := os.ReadFile(strings.TrimSuffix(path, ".pack") + ".idx")
+	if err != nil {
+		return nil, err
+	}
+	if len(index) < 8+256*4+40 || !bytes.Equal(index[:4], packIndexSignature) || binary.BigEndian.Uint32(index[4:8]) != 2 {
+		return nil, fmt.Errorf("unsupported pack index %s", path)
+	}
+	count := int(binary.BigEndian.Uint32(index[8+255*4:]))
+	if len(index) < 8+256*4+count*28+40 {
+		return nil, fmt.Errorf("truncated pack index %s", path)
+	}
+	return &packFile{path: path, index: index, count: count}, nil
+}
+
+// fanout returns how many objects of the pack have a first byte up to b.
+func (p *packFile) fanout(b int) int {
+	if b < 0 {
+		return 0
+	}
+	return int(binary.BigEndian.Uint32(p.index[8+b*4:]))
+}
+
+// find returns the offset of object in the pack.
+func (p *packFile) find(object []byte) (int64, bool) {
+	names := p.index[8+256*4:]
+	lo, hi := p.fanout(int(object[0])-1), p.fanout(int(object[0]))
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(names[(lo+i)*20:(lo+i)*20+20], object) >= 0
+	})
+	if i >= hi || !bytes.Equal(names[i*20:i*20+20], object) {
+		return 0, false
+	}
+
+	offsets := names[p.count*24:]
+	offset := binary.BigEndian.Uint32(offsets[i*4:])
+	if offset&(1<<31) == 0 {
+		return int64(offset), true
+	}
+	large := offsets[p.count*4:]
+	return int64(binary.BigEndian.Uint64(large[int(offset&^(1<<31))*8:])), true
+}
+
+// withPrefix returns the objects of the pack whose hex names start with
+// prefix, which has at least two characters.
+func (p *packFile) withPrefix(prefix string) []string {
+	first, err := strconv.ParseUint(prefix[:2], 16, 8)
+	if err != nil {
+		return nil
+	}
+	names := p.index[8+256*4:]
+	matches := []string{}
+	for i := p.fanout(int(first) - 1); i < p.fanout(int(first)); i++ {
+		if name := hex.EncodeToString(names[i*20 : i*20+20]); strings.HasPrefix(name, prefix) {
+			matches = append(matches, name)
+		}
+	}
+	return matches
+}
+
+// packFiles returns the kept packs of the repository, loading their
+// indexes the first time.
+func (r *Repository) packFiles() ([]*packFile, error) {
+	r.packMutex.Lock()
+	defer r.packMutex.Unlock()
+	if r.packs != nil {
+		return r.packs, nil
+	}
+	paths, err := filepath.Glob(r.path("objects", "pack", "*.pack"))
+	if err != nil {
+		return nil, err
+	}
+	packs := []*packFile{}
+	for _, path := range paths {
+		pack, err := loadPackIndex(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		packs = append(packs, pack)
+	}
+	r.packs = packs
+	return packs, nil
+}
+
+// findPacked returns the pack holding object and its offset there.
+func (r *Repository) findPacked(object string) (*packFile, int64, bool) {
+	name, err := hex.DecodeString(object)
+	if err != nil || len(name) != 20 {
+		return nil, 0, false
+	}
+	packs, err := r.packFiles()
+	if err != nil {
+		return nil, 0, false
+	}
+	for _, pack := range packs {
+		if offset, found := pack.find(name); found {
+			return pack, offset, true
+		}
+	}
+	return nil, 0, false
+}
+
+// packEntry is the header of an entry of a pack file.
+type packEntry struct {
+	objectType int
+	size       int
+	// baseOffset and baseHash locate the base of ofs-deltas and
+	// ref-deltas.
+	baseOffset int64
+	baseHash   string
+	// dataOffset is where the compressed data starts.
+	dataOffset int64
+}
+
+// readPackEntry decodes the header of the entry at offset in pack.
+func readPackEntry(file *os.File, pack *packFile, offset int64) (*packEntry, error) {
+	header := make([]byte, 32)
+	n, err := file.ReadAt(header, offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	header = header[:n]
+	objectType, size, n := readPackEntryHeader(header)
+	if n == 0 {
+		return nil, fmt.Errorf("bad pack entry at %d in %s", offset, pack.path)
+	}
+	entry := &packEntry{objectType: objectType, size: size}
+	switch objectType {
+	case packOfsDelta:
+		distance, m := readOffsetDelta(header[n:])
+		if m == 0 || int64(distance) > offset {
+			return nil, fmt.Errorf("bad delta offset at %d in %s", offset, pack.path)
+		}
+		entry.baseOffset = offset - int64(distance)
+		n += m
+	case packRefDelta:
+		if n+20 > len(header) {
+			return nil, fmt.Errorf("truncated pack entry at %d in %s", offset, pack.path)
+		}
+		entry.baseHash = hex.EncodeToString(header[n : n+20])
+		n += 20
+	}
+	entry.dataOffset = offset + int64(n)
+	return entry, nil
+}
+
+// inflater returns a reader of the inflated data of entry.
+func (entry *packEntry) inflater(file *os.File) (io.ReadCloser, error) {
+	return zlib.NewReader(io.NewSectionReader(file, entry.dataOffset, math.MaxInt64-entry.dataOffset))
+}
+
+// readPackedObjectInfo returns the type and size of the entry at offset in
+// pack. Only the start of deltas is inflated, to read the size of the object
+// they build.
+func (r *Repository) readPackedObjectInfo(pack *packFile, offset int64) (string, int, error) {
+	file, err := os.Open(pack.path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	entry, err := readPackEntry(file, pack, offset)
+	if err != nil {
+		return "", 0, err
+	}
+	if entry.objectType != packOfsDelta && entry.objectType != packRefDelta {
+		typeName, ok := packTypeNames[entry.objectType]
+		if !ok {
+			return "", 0, fmt.Errorf("unknown object type %d in %s", entry.objectType, pack.path)
+		}
+		return typeName, entry.size, nil
+	}
+
+	zlibReader, err := entry.inflater(file)
+	if err != nil {
+		return "", 0, err
+	}
+	defer zlibReader.Close()
+	// the delta starts with the sizes of its base and of its result
+	reader := bufio.NewReader(zlibReader)
+	size := 0
+	for i := 0; i < 2; i++ {
+		size = 0
+		for shift := 0; ; shift += 7 {
+			c, err := reader.ReadByte()
+			if err != nil {
+				return "", 0, fmt.Errorf("truncated delta at %d in %s", offset, pack.path)
+			}
+			size |= int(c&0x7f) << shift
+			if c&0x80 == 0 {
+				break
+			}
+		}
+	}
+
+	var typeName string
+	if entry.objectType == packOfsDelta {
+		typeName, _, err = r.readPackedObjectInfo(pack, entry.baseOffset)
+	} else {
+		typeName, _, err = r.ReadObjectInfo(entry.baseHash)
+	}
+	return typeName, size, err
+}
+
+// readPackedObject returns the type and contents of the entry at offset in
+// pack, applying deltas.
+func (r *Repository) readPackedObject(pack *packFile, offset int64) (string, []byte, error) {
+	file, err := os.Open(pack.path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer file.Close()
+
+	entry, err := readPackEntry(file, pack, offset)
+	if err != nil {
+		return "", nil, err
+	}
+	zlibReader, err := entry.inflater(file)
+	if err != nil {
+		return "", nil, err
+	}
+	data, err := io.ReadAll(zlibReader)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(data) != entry.size {
+		return "", nil, fmt.Errorf("bad pack entry size at %d in %s", offset, pack.path)
+	}
+
+	var base []byte
+	var typeName string
+	switch entry.objectType {
+	case packOfsDelta:
+		typeName, base, err = r.readPackedObject(pack, entry.baseOffset)
+	case packRefDelta:
+		typeName, base, err = r.ReadObject(entry.baseHash)
+	default:
+		var ok bool
+		if typeName, ok = packTypeNames[entry.objectType]; !ok {
+			return "", nil, fmt.Errorf("unknown object type %d in %s", entry.objectType, pack.path)
+		}
+		return typeName, data, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	data, err = applyDelta(base, data)
+	return typeName, data, err
+}
@@ -0,0 +1,141 @@
+package repo
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testPack returns a pack of the history of a new repository and the
+// commit at its tip.
+func testPack(t *testing.T) ([]byte, string) {
+	t.Helper()
+	source := initTestRepo(t)
+	commitFile(t, source, "a", "1\n")
+	head := commitFile(t, source, "d/b", "2\n")
+	objects, err := source.reachableObjects([]string{head}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pack, err := source.createPack(objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pack, head
+}
+
+func TestIndexPack(t *testing.T) {
+	pack, head := testPack(t)
+	r, err := InitBare(filepath.Join(t.TempDir(), "repo.git"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, err := r.indexPack(pack)
+	if err != nil {
+		t.Fatalf("indexPack: %v", err)
+	}
+	if want := "pack-" + hex.EncodeToString(pack[len(pack)-20:]); name != want {
+		t.Errorf("pack is named %s, want %s", name, want)
+	}
+	objects, err := r.reachableObjects([]string{head}, nil)
+	if err != nil {
+		t.Fatalf("reading the indexed pack: %v", err)
+	}
+	if len(objects) != 7 {
+		t.Errorf("the pack has %d objects, want 7", len(objects))
+	}
+
+	// the same pack again replaces the read-only files
+	if _, err := r.indexPack(pack); err != nil {
+		t.Errorf("indexing the pack again: %v", err)
+	}
+	entries, err := os.ReadDir(filepath.Join(r.GitDir(), "objects", "pack"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("objects/pack has %d files, want the pack and its index", len(entries))
+	}
+}
+
+func TestIndexPackReplacesTruncatedPack(t *testing.T) {
+	pack, head := testPack(t)
+	r, err := InitBare(filepath.Join(t.TempDir(), "repo.git"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// what a crash in the middle of writing the pack could leave behind
+	file := filepath.Join(r.GitDir(), "objects", "pack", "pack-"+hex.EncodeToString(pack[len(pack)-20:])+".pack")
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, pack[:len(pack)/2], 0444); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.indexPack(pack); err != nil {
+		t.Fatalf("indexPack: %v", err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil || len(data) != len(pack) {
+		t.Fatalf("the pack has %d of %d bytes, %v", len(data), len(pack), err)
+	}
+	if _, err := r.reachableObjects([]string{head}, nil); err != nil {
+		t.Errorf("reading the indexed pack: %v", err)
+	}
+}
+
+func TestIndexPackReportsWriteErrors(t *testing.T) {
+	pack, _ := testPack(t)
+	r, err := InitBare(filepath.Join(t.TempDir(), "repo.git"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a directory in the way of the pack makes the rename fail
+	name := "pack-" + hex.EncodeToString(pack[len(pack)-20:])
+	blocker := filepath.Join(r.GitDir(), "objects", "pack", name+".pack", "x")
+	if err := os.MkdirAll(blocker, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.indexPack(pack); err == nil {
+		t.Error("indexPack succeeded without writing the pack")
+	}
+	if _, err := os.Stat(filepath.Join(r.GitDir(), "objects", "pack", name+".idx")); err == nil {
+		t.Error("the index was written without its pack")
+	}
+}
+
+func TestReadPackedObjectInfo(t *testing.T) {
+	base := []byte("hello world\n")
+	// copy the base, insert "more\n"
+	ops := []byte{0x80 | 0x01 | 0x10, 0, byte(len(base)), 5, 'm', 'o', 'r', 'e', '\n'}
+	delta := append(append(deltaSize(len(base)), deltaSize(len(base)+5)...), ops...)
+	baseEntry := append([]byte{packBlob<<4 | byte(len(base))}, compress(t, base)...)
+	deltaEntry := append([]byte{packOfsDelta<<4 | byte(len(delta)), byte(len(baseEntry))}, compress(t, delta)...)
+	pack := append([]byte("PACK"), 0, 0, 0, 2, 0, 0, 0, 2)
+	pack = append(append(pack, baseEntry...), deltaEntry...)
+	hasher := sha1.New()
+	hasher.Write(pack)
+	pack = hasher.Sum(pack)
+
+	r, err := InitBare(filepath.Join(t.TempDir(), "repo.git"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.indexPack(pack); err != nil {
+		t.Fatalf("indexPack: %v", err)
+	}
+	for content, wantSize := range map[string]int{"hello world\n": 12, "hello world\nmore\n": 17} {
+		object := HashObject(strings.NewReader(content), "blob", len(content))
+		objectType, size, err := r.ReadObjectInfo(object)
+		if err != nil || objectType != "blob" || size != wantSize {
+			t.Errorf("%q is %s of size %d, %v", content, objectType, size, err)
+		}
+	}
+	if _, _, err := r.ReadObjectInfo(strings.Repeat("0", 40)); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("a missing object returned %v", err)
+	}
+}
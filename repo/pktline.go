@@ -0,0 +1,231 @@
+package repo
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxPktPayload is the largest payload a single pkt-line can carry.
+const maxPktPayload = 65516
+
+// pktType tells data packets apart from the special zero length ones.
+type pktType int
+
+const (
+	pktData pktType = iota
+	// pktFlush ("0000") ends a message.
+	pktFlush
+	// pktDelim ("0001") separates sections in protocol v2.
+	pktDelim
+	// pktResponseEnd ("0002") ends a stateless protocol v2 response.
+	pktResponseEnd
+)
+
+// pktReader reads pkt-lines: a four digit hex length, including the four
+// digits themselves, followed by the payload.
+type pktReader struct {
+	reader *bufio.Reader
+}
+
+func newPktReader(reader io.Reader) *pktReader {
+	if buffered, ok := reader.(*bufio.Reader); ok {
+		return &pktReader{reader: buffered}
+	}
+	return &pktReader{reader: bufio.NewReader(reader)}
+}
+
+// Read returns the next packet. The payload is nil for special packets.
+func (p *pktReader) Read() (pktType, []byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(p.reader, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return pktData, nil, fmt.Errorf("truncated pkt-line header")
+		}
+		return pktData, nil, err
+	}
+	length, err := strconv.ParseUint(string(header), 16, 16)
+	if err != nil {
+		return pktData, nil, fmt.Errorf("invalid pkt-line length %q", header)
+	}
+	switch length {
+	case 0:
+		return pktFlush, nil, nil
+	case 1:
+		return pktDelim, nil, nil
+	case 2:
+		return pktResponseEnd, nil, nil
+	case 3:
+		return pktData, nil, fmt.Errorf("invalid pkt-line length %q", header)
+	}
+	payload := make([]byte, length-4)
+	if _, err := io.ReadFull(p.reader, payload); err != nil {
+		return pktData, nil, fmt.Errorf("truncated pkt-line: %w", err)
+	}
+	return pktData, payload, nil
+}
+
+// ReadLine returns the next data packet as a string without its trailing
+// newline. It reports io.EOF at a flush packet.
+func (p *pktReader) ReadLine() (string, error) {
+	kind, payload, err := p.Read()
+	if err != nil {
+		return "", err
+	}
+	if kind != pktData {
+		return "", io.EOF
+	}
+	return strings.TrimSuffix(string(payload), "\n"), nil
+}
+
+// pktWriter writes pkt-lines. The first error sticks and is returned by
+// every later call.
+type pktWriter struct {
+	writer io.Writer
+	err    error
+}
+
+func newPktWriter(writer io.Writer) *pktWriter {
+	return &pktWriter{writer: writer}
+}
+
+// Write sends data as one packet.
+func (p *pktWriter) Write(data []byte) error {
+	if p.err != nil {
+		return p.err
+	}
+	if len(data) > maxPktPayload {
+		p.err = fmt.Errorf("pkt-line payload of %d bytes is too long", len(data))
+		return p.err
+	}
+	if _, p.err = fmt.Fprintf(p.writer, "%04x", len(data)+4); p.err == nil {
+		_, p.err = p.writer.Write(data)
+	}
+	return p.err
+}
+
+// WriteString sends s as one packet.
+func (p *pktWriter) WriteString(s string) error {
+	return p.Write([]byte(s))
+}
+
+// Flush sends a flush packet.
+func (p *pktWriter) Flush() error {
+	return p.special("0000")
+}
+
+// Delim sends a delimiter packet.
+func (p *pktWriter) Delim() error {
+	return p.special("0001")
+}
+
+// ResponseEnd sends a response-end packet.
+func (p *pktWriter) ResponseEnd() error {
+	return p.special("0002")
+}
+
+func (p *pktWriter) special(packet string) error {
+	if p.err == nil {
+		_, p.err = io.WriteString(p.writer, packet)
+	}
+	return p.err
+}
+
+// Advertisement is the list of refs and capabilities a server sends before
+// upload-pack or receive-pack.
+type Advertisement struct {
+	// Refs in the order the server sent them.
+	Refs []Ref
+	// Peeled maps annotated tags to the object they point to.
+	Peeled map[string]string
+	// Symrefs maps symbolic refs such as HEAD to their targets.
+	Symrefs      map[string]string
+	Capabilities []string
+}
+
+// Lookup returns the hash the remote advertised for name.
+func (adv *Advertisement) Lookup(name string) (string, bool) {
+	for _, ref := range adv.Refs {
+		if ref.Name == name {
+			return ref.Hash, true
+		}
+	}
+	return "", false
+}
+
+// HasCapability reports whether the server advertised capability.
+func (adv *Advertisement) HasCapability(capability string) bool {
+	return hasCapability(adv.Capabilities, capability)
+}
+
+// refMap returns the advertised refs keyed by name.
+func (adv *Advertisement) refMap() map[string]string {
+	refs := make(map[string]string, len(adv.Refs))
+	for _, ref := range adv.Refs {
+		refs[ref.Name] = ref.Hash
+	}
+	return refs
+}
+
+// parseAdvertisement reads a v0/v1 ref advertisement. The smart HTTP
+// "# service=" header, if present, is skipped.
+func parseAdvertisement(reader io.Reader) (*Advertisement, error) {
+	pkt := newPktReader(reader)
+	adv := &Advertisement{Peeled: map[string]string{}, Symrefs: map[string]string{}}
+
+	first := true
+	for {
+		kind, payload, err := pkt.Read()
+		if err == io.EOF {
+			if first {
+				return nil, errors.New("empty ref advertisement")
+			}
+			return adv, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if kind == pktFlush {
+			if first {
+				// the flush after the "# service=" header
+				continue
+			}
+			return adv, nil
+		}
+		if kind != pktData {
+			return nil, errors.New("unexpected packet in ref advertisement")
+		}
+		line := strings.TrimSuffix(string(payload), "\n")
+		if strings.HasPrefix(line, "# service=") || line == "version 1" {
+			continue
+		}
+
+		line, capabilities, hasCapabilities := strings.Cut(line, "\x00")
+		if hasCapabilities {
+			adv.Capabilities = strings.Fields(capabilities)
+			for _, capability := range adv.Capabilities {
+				if symref, found := strings.CutPrefix(capability, "symref="); found {
+					from, to, _ := strings.Cut(symref, ":")
+					adv.Symrefs[from] = to
+				}
+			}
+		}
+		first = false
+
+		hash, name, found := strings.Cut(line, " ")
+		if !found || !isHash(hash) {
+			return nil, fmt.Errorf("invalid ref advertisement line %q", line)
+		}
+		if name == "capabilities^{}" {
+			continue
+		}
+		if tag, peeled := strings.CutSuffix(name, "^{}"); peeled {
+			adv.Peeled[tag] = hash
+			continue
+		}
+		adv.Refs = append(adv.Refs, Ref{Name: name, Hash: hash})
+	}
+}
@@ -0,0 +1,116 @@
+package repo
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestPktLineRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	pkt := newPktWriter(&buf)
+	pkt.WriteString("hello\n")
+	pkt.Delim()
+	pkt.Write([]byte{})
+	pkt.Flush()
+	pkt.ResponseEnd()
+	if err := pkt.Write(bytes.Repeat([]byte("x"), maxPktPayload)); err != nil {
+		t.Fatal(err)
+	}
+	if want := "000ahello\n000100040000" + "0002" + "fff0"; !strings.HasPrefix(buf.String(), want) {
+		t.Errorf("written packets start with %q, want %q", buf.String()[:len(want)], want)
+	}
+
+	reader := newPktReader(&buf)
+	for _, want := range []struct {
+		kind    pktType
+		payload string
+	}{{pktData, "hello\n"}, {pktDelim, ""}, {pktData, ""}, {pktFlush, ""}, {pktResponseEnd, ""}, {pktData, strings.Repeat("x", maxPktPayload)}} {
+		kind, payload, err := reader.Read()
+		if err != nil || kind != want.kind || string(payload) != want.payload {
+			t.Errorf("read %d %.10q %v, want %d %.10q", kind, payload, err, want.kind, want.payload)
+		}
+	}
+	if _, _, err := reader.Read(); err != io.EOF {
+		t.Errorf("reading past the end returned %v", err)
+	}
+}
+
+func TestPktLineErrors(t *testing.T) {
+	for _, bad := range []string{"00", "0003", "zzzz", "0009abc", "-001"} {
+		if _, _, err := newPktReader(strings.NewReader(bad)).Read(); err == nil || err == io.EOF {
+			t.Errorf("reading %q returned %v", bad, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	pkt := newPktWriter(&buf)
+	if err := pkt.Write(make([]byte, maxPktPayload+1)); err == nil {
+		t.Error("an oversized packet was written")
+	}
+	// the error sticks
+	if err := pkt.Flush(); err == nil || buf.Len() != 0 {
+		t.Errorf("flush after an error returned %v and wrote %q", err, buf.String())
+	}
+
+	failing := newPktWriter(failingWriter{})
+	failing.WriteString("a")
+	if err := failing.Flush(); err == nil {
+		t.Error("writing to a failing writer succeeded")
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestParseAdvertisement(t *testing.T) {
+	head := strings.Repeat("1", 40)
+	tag := strings.Repeat("2", 40)
+	peeled := strings.Repeat("3", 40)
+	var buf bytes.Buffer
+	pkt := newPktWriter(&buf)
+	pkt.WriteString("# service=git-upload-pack\n")
+	pkt.Flush()
+	pkt.WriteString(head + " HEAD\x00multi_ack symref=HEAD:refs/heads/main side-band-64k\n")
+	pkt.WriteString(head + " refs/heads/main\n")
+	pkt.WriteString(tag + " refs/tags/v1\n")
+	pkt.WriteString(peeled + " refs/tags/v1^{}\n")
+	pkt.Flush()
+
+	adv, err := parseAdvertisement(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(adv.Refs) != 3 || adv.Refs[0].Name != "HEAD" || adv.Refs[2].Hash != tag {
+		t.Errorf("refs are %+v", adv.Refs)
+	}
+	if hash, ok := adv.Lookup("refs/heads/main"); !ok || hash != head {
+		t.Errorf("refs/heads/main is %s, %v", hash, ok)
+	}
+	if adv.Peeled["refs/tags/v1"] != peeled || adv.Symrefs["HEAD"] != "refs/heads/main" {
+		t.Errorf("peeled are %v, symrefs %v", adv.Peeled, adv.Symrefs)
+	}
+	if !adv.HasCapability("side-band-64k") || !adv.HasCapability("symref") || adv.HasCapability("side-band") {
+		t.Errorf("capabilities are %v", adv.Capabilities)
+	}
+
+	// an empty repository only advertises its capabilities
+	buf.Reset()
+	pkt.WriteString(zeroHash + " capabilities^{}\x00report-status delete-refs\n")
+	pkt.Flush()
+	adv, err = parseAdvertisement(&buf)
+	if err != nil || len(adv.Refs) != 0 || !adv.HasCapability("delete-refs") {
+		t.Errorf("empty advertisement is %+v, %v", adv, err)
+	}
+
+	for _, bad := range []string{"", "0000", "0008abcd0000", "000fnot-a-hash\n0000", "0001"} {
+		if adv, err := parseAdvertisement(strings.NewReader(bad)); err == nil {
+			t.Errorf("%q parsed as %+v", bad, adv)
+		}
+	}
+}
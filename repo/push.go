@@ -0,0 +1,658 @@
+package repo
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrPushRejected is returned when the remote did not accept every ref.
+var ErrPushRejected = errors.New("failed to push some refs")
+
+// PushOptions selects what Push sends and where.
+type PushOptions struct {
+	// Remote is a remote name or URL. Empty means origin.
+	Remote string
+	// Refspecs are [+]<src>[:<dst>] specs. With none, and neither All nor
+	// Tags set, the current branch is pushed to the branch of the same name.
+	Refspecs []string
+	// All pushes every branch and Tags every tag.
+	All  bool
+	Tags bool
+	// FollowTags also pushes the annotated tags missing on the remote that
+	// point into the history being pushed. push.followTags sets it too.
+	FollowTags bool
+	// Force allows non-fast-forward updates of every ref.
+	Force bool
+	// Leases allow non-fast-forward updates of refs whose remote value
+	// still matches what we expect, like --force-with-lease.
+	Leases []Lease
+	// SetUpstream records the remote and branch as the upstream of each
+	// pushed branch.
+	SetUpstream bool
+
+	UserName string
+	Password string
+	// Progress receives the "remote: " messages of the server, if set.
+	Progress io.Writer
+}
+
+// Lease is a --force-with-lease expectation for a remote ref.
+type Lease struct {
+	// Ref is the remote ref the lease protects. Empty protects every ref.
+	Ref string
+	// Expect is the hash or ref name the remote ref must still point to.
+	// Empty means the remote-tracking ref, and ZeroHash that the remote
+	// ref must not exist.
+	Expect string
+}
+
+// ZeroHash is the all zero object name used for missing refs.
+const ZeroHash = zeroHash
+
+// RefUpdateStatus is the outcome of a pushed ref update.
+type RefUpdateStatus string
+
+const (
+	RefUpdateOK       RefUpdateStatus = "ok"
+	RefUpdateUpToDate RefUpdateStatus = "up to date"
+	RefUpdateRejected RefUpdateStatus = "rejected"
+	// RefUpdateRemoteRejected is an update the remote refused.
+	RefUpdateRemoteRejected RefUpdateStatus = "remote rejected"
+)
+
+// RefUpdate is one ref Push tried to update on the remote.
+type RefUpdate struct {
+	// Src is the local ref, empty for deletions and raw hashes.
+	Src string
+	// Dst is the remote ref.
+	Dst string
+	Old string
+	New string
+	// Forced is set for updates that are not fast-forwards.
+	Forced bool
+	Status RefUpdateStatus
+	Reason string
+}
+
+// IsDelete reports whether the update removes the remote ref.
+func (update RefUpdate) IsDelete() bool {
+	return update.New == zeroHash
+}
+
+// PushResult is what Push did on the remote.
+type PushResult struct {
+	URL     string
+	Updates []RefUpdate
+}
+
+// Push updates refs on a remote and sends the objects they need.
+func (r *Repository) Push(opts PushOptions) (*PushResult, error) {
+
+	remoteConfig, err := r.ResolveRemote(opts.Remote)
+	if err != nil {
+		return nil, err
+	}
+	remote, userName, password := splitCredentials(remoteConfig.PushTarget())
+	result := &PushResult{URL: remote}
+
+	if opts.UserName != "" {
+		userName = opts.UserName
+	}
+	if opts.Password != "" {
+		password = opts.Password
+	}
+
+	t, err := r.openTransport(remote, userName, password)
+	if err != nil {
+		return nil, err
+	}
+	body, err := t.advertise("git-receive-pack", "")
+	if err != nil {
+		return nil, err
+	}
+	adv, err := parseAdvertisement(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ref advertisement from %s: %w", remote, err)
+	}
+	remoteRefs := adv.refMap()
+
+	updates, err := r.planPush(opts, remoteRefs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range updates {
+		if err := r.checkUpdate(&updates[i], opts.Leases, remoteRefs, remoteConfig); err != nil {
+			return nil, err
+		}
+	}
+	result.Updates = updates
+
+	pending := []*RefUpdate{}
+	for i := range updates {
+		if updates[i].Status == "" {
+			if updates[i].IsDelete() && !adv.HasCapability("delete-refs") {
+				updates[i].Status = RefUpdateRejected
+				updates[i].Reason = "remote does not support deleting refs"
+				continue
+			}
+			pending = append(pending, &updates[i])
+		}
+	}
+
+	if len(pending) > 0 {
+		// only ask for what the server offers, it may refuse anything else
+		requested := []string{}
+		switch {
+		case adv.HasCapability("report-status-v2"):
+			requested = append(requested, "report-status-v2")
+		case adv.HasCapability("report-status"):
+			requested = append(requested, "report-status")
+		}
+		reportStatus := len(requested) > 0
+		sideband := adv.HasCapability("side-band-64k")
+		if sideband {
+			requested = append(requested, "side-band-64k")
+		}
+		if adv.HasCapability("delete-refs") {
+			requested = append(requested, "delete-refs")
+		}
+
+		data, err := r.createPushRequest(pending, remoteRefs, requested)
+		if err != nil {
+			return nil, err
+		}
+		body, err := t.request("git-receive-pack", "", data)
+		if err != nil {
+			return nil, err
+		}
+		report := &pushReport{unpack: "ok", refs: map[string]string{}}
+		if reportStatus {
+			if report, err = readPushReport(body, sideband, opts.Progress); err != nil {
+				return result, err
+			}
+		} else {
+			// without a report, every update that was sent counts as done
+			for _, update := range pending {
+				report.refs[update.Dst] = ""
+			}
+		}
+
+		accepted := []*RefUpdate{}
+		for _, update := range pending {
+			reason, reported := report.refs[update.Dst]
+			switch {
+			case report.unpack != "ok":
+				update.Status, update.Reason = RefUpdateRemoteRejected, "unpacker error"
+			case !reported:
+				update.Status, update.Reason = RefUpdateRemoteRejected, "no report from remote"
+			case reason != "":
+				update.Status, update.Reason = RefUpdateRemoteRejected, reason
+			default:
+				update.Status = RefUpdateOK
+				accepted = append(accepted, update)
+			}
+		}
+		if err := r.recordPush(accepted, remoteConfig, opts.SetUpstream); err != nil {
+			return result, err
+		}
+		if report.unpack != "ok" {
+			return result, fmt.Errorf("remote unpack failed: %s", report.unpack)
+		}
+	}
+
+	for _, update := range updates {
+		if update.Status == RefUpdateRejected || update.Status == RefUpdateRemoteRejected {
+			return result, ErrPushRejected
+		}
+	}
+	return result, nil
+}
+
+// planPush expands the refspecs of opts into ref updates.
+func (r *Repository) planPush(opts PushOptions, remoteRefs map[string]string) ([]RefUpdate, error) {
+	specs := append([]string{}, opts.Refspecs...)
+	if opts.All {
+		specs = append(specs, "refs/heads/*:refs/heads/*")
+	}
+	if opts.Tags {
+		specs = append(specs, "refs/tags/*:refs/tags/*")
+	}
+	if len(specs) == 0 {
+		head, err := r.HeadBranch()
+		if err != nil {
+			return nil, err
+		}
+		if head == "" {
+			return nil, errors.New("you are not currently on a branch")
+		}
+		specs = append(specs, head+":"+head)
+	}
+
+	updates := []RefUpdate{}
+	seen := map[string]bool{}
+	add := func(update RefUpdate) error {
+		if seen[update.Dst] {
+			return fmt.Errorf("multiple updates for ref '%s' not allowed", update.Dst)
+		}
+		seen[update.Dst] = true
+		updates = append(updates, update)
+		return nil
+	}
+
+	for _, spec := range specs {
+		refspec, err := ParseRefspec(spec)
+		if err != nil {
+			return nil, err
+		}
+		force := refspec.Force || opts.Force
+
+		switch {
+		case refspec.IsDelete():
+			dst := remoteDst(refspec.Dst, "", remoteRefs)
+			if err := add(RefUpdate{Dst: dst, New: zeroHash, Forced: force}); err != nil {
+				return nil, err
+			}
+		case refspec.IsPattern():
+			refs, err := r.ListRefs("")
+			if err != nil {
+				return nil, err
+			}
+			for _, ref := range refs {
+				dst, ok := refspec.Match(ref.Name)
+				if !ok {
+					continue
+				}
+				if err := add(RefUpdate{Src: ref.Name, Dst: dst, New: ref.Hash, Forced: force}); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			src, hash, err := r.resolvePushSource(refspec.Src)
+			if err != nil {
+				return nil, err
+			}
+			dst := refspec.Dst
+			if dst == "" {
+				if src == "" {
+					return nil, fmt.Errorf("the destination of '%s' must be a full ref name", spec)
+				}
+				dst = src
+			}
+			dst = remoteDst(dst, src, remoteRefs)
+			if err := add(RefUpdate{Src: src, Dst: dst, New: hash, Forced: force}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	followTags := opts.FollowTags
+	if config, err := r.Config(); err == nil && !followTags {
+		followTags = config.GetBool("push.followTags", false)
+	}
+	if followTags {
+		tags, err := r.followTags(updates, remoteRefs)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range tags {
+			if !seen[tag.Dst] {
+				add(tag)
+			}
+		}
+	}
+	return updates, nil
+}
+
+// followTags returns updates creating the annotated tags the remote lacks
+// whose commit is reachable from one of updates.
+func (r *Repository) followTags(updates []RefUpdate, remoteRefs map[string]string) ([]RefUpdate, error) {
+	refs, err := r.ListRefs("refs/tags/")
+	if err != nil {
+		return nil, err
+	}
+	tags := []RefUpdate{}
+	for _, ref := range refs {
+		if _, found := remoteRefs[ref.Name]; found {
+			continue
+		}
+		peeled, isTag, err := r.peel(ref.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if !isTag {
+			continue
+		}
+		for _, update := range updates {
+			if update.IsDelete() {
+				continue
+			}
+			if reachable, err := r.isAncestor(peeled, update.New); err == nil && reachable {
+				tags = append(tags, RefUpdate{Src: ref.Name, Dst: ref.Name, New: ref.Hash})
+				break
+			}
+		}
+	}
+	return tags, nil
+}
+
+// resolvePushSource resolves the source side of a refspec to a full ref
+// name and hash. Raw hashes have no ref name.
+func (r *Repository) resolvePushSource(src string) (string, string, error) {
+	if src == "HEAD" || src == "@" {
+		head, err := r.HeadBranch()
+		if err != nil {
+			return "", "", err
+		}
+		if head == "" {
+			hash, err := r.ReadRef("HEAD")
+			return "", hash, err
+		}
+		src = head
+	}
+	if isHash(src) && r.HasObject(src) {
+		return "", src, nil
+	}
+	name, err := r.ExpandRef(src)
+	if err != nil {
+		return "", "", fmt.Errorf("src refspec %s does not match any", src)
+	}
+	hash, err := r.ReadRef(name)
+	if err != nil {
+		return "", "", err
+	}
+	return name, hash, nil
+}
+
+// remoteDst qualifies the destination of a refspec. Names already on the
+// remote win, otherwise the kind of the source ref decides.
+func remoteDst(dst, src string, remoteRefs map[string]string) string {
+	if strings.HasPrefix(dst, "refs/") {
+		return dst
+	}
+	for _, prefix := range []string{"refs/heads/", "refs/tags/"} {
+		if _, found := remoteRefs[prefix+dst]; found {
+			return prefix + dst
+		}
+	}
+	if strings.HasPrefix(src, "refs/tags/") {
+		return "refs/tags/" + dst
+	}
+	return "refs/heads/" + dst
+}
+
+// checkUpdate fills in the old value of update and rejects it if the
+// remote ref cannot be moved to the new value.
+func (r *Repository) checkUpdate(update *RefUpdate, leases []Lease, remoteRefs map[string]string, remote Remote) error {
+	update.Old = zeroHash
+	if hash, found := remoteRefs[update.Dst]; found {
+		update.Old = hash
+	}
+
+	reject := func(reason string) error {
+		update.Status = RefUpdateRejected
+		update.Reason = reason
+		return nil
+	}
+
+	if update.IsDelete() && update.Old == zeroHash {
+		return reject("remote ref does not exist")
+	}
+	if update.Old == update.New {
+		update.Status = RefUpdateUpToDate
+		return nil
+	}
+
+	if lease, found := findLease(leases, update.Dst); found {
+		expect, err := r.leaseExpectation(lease, update.Dst, remote)
+		if err != nil {
+			return err
+		}
+		if expect != update.Old {
+			return reject("stale info")
+		}
+		update.Forced = !update.IsDelete() && update.Old != zeroHash && !r.fastForward(update.Old, update.New)
+		return nil
+	}
+
+	if update.IsDelete() || update.Old == zeroHash {
+		return nil
+	}
+	if strings.HasPrefix(update.Dst, "refs/tags/") && !update.Forced {
+		return reject("already exists")
+	}
+	if !r.fastForward(update.Old, update.New) {
+		if !update.Forced {
+			if !r.HasObject(update.Old) {
+				return reject("fetch first")
+			}
+			return reject("non-fast-forward")
+		}
+		return nil
+	}
+	update.Forced = false
+	return nil
+}
+
+// fastForward reports whether moving a ref from old to new keeps old in
+// its history.
+func (r *Repository) fastForward(old, new string) bool {
+	if !r.HasObject(old) {
+		return false
+	}
+	ancestor, err := r.isAncestor(old, new)
+	return err == nil && ancestor
+}
+
+// findLease returns the lease protecting ref, preferring one that names it.
+func findLease(leases []Lease, ref string) (Lease, bool) {
+	var fallback *Lease
+	for i, lease := range leases {
+		if lease.Ref == "" {
+			fallback = &leases[i]
+			continue
+		}
+		if lease.Ref == ref || remoteDst(lease.Ref, "", map[string]string{ref: ""}) == ref {
+			return lease, true
+		}
+	}
+	if fallback != nil {
+		return *fallback, true
+	}
+	return Lease{}, false
+}
+
+// leaseExpectation returns the hash a leased remote ref must still have.
+func (r *Repository) leaseExpectation(lease Lease, ref string, remote Remote) (string, error) {
+	if lease.Expect == zeroHash {
+		return zeroHash, nil
+	}
+	if lease.Expect == "" {
+		tracking := trackingRef(remote.Fetch, ref)
+		if tracking == "" {
+			// without a remote-tracking ref there is nothing to compare
+			return "", nil
+		}
+		hash, err := r.ReadRef(tracking)
+		if errors.Is(err, ErrRefNotFound) {
+			return zeroHash, nil
+		}
+		return hash, err
+	}
+	if isHash(lease.Expect) {
+		return lease.Expect, nil
+	}
+	_, hash, err := r.resolvePushSource(lease.Expect)
+	return hash, err
+}
+
+// createPushRequest builds the receive-pack request: the ref update
+// commands followed by a pack of the objects the remote is missing.
+func (r *Repository) createPushRequest(updates []*RefUpdate, remoteRefs map[string]string, capabilities []string) ([]byte, error) {
+	var request bytes.Buffer
+	pkt := newPktWriter(&request)
+	tips := []string{}
+	for i, update := range updates {
+		line := fmt.Sprintf("%s %s %s", update.Old, update.New, update.Dst)
+		if i == 0 {
+			line += "\x00" + strings.Join(capabilities, " ")
+		}
+		pkt.WriteString(line + "\n")
+		if !update.IsDelete() {
+			tips = append(tips, update.New)
+		}
+	}
+	if err := pkt.Flush(); err != nil {
+		return nil, err
+	}
+
+	if len(tips) == 0 {
+		return request.Bytes(), nil
+	}
+
+	exclude := []string{}
+	for _, hash := range remoteRefs {
+		exclude = append(exclude, hash)
+	}
+	missingObjects, err := r.reachableObjects(tips, exclude)
+	if err != nil {
+		return nil, err
+	}
+	pack, err := r.createPack(missingObjects)
+	if err != nil {
+		return nil, err
+	}
+	request.Write(pack)
+	return request.Bytes(), nil
+}
+
+// recordPush updates remote-tracking refs after a successful push and,
+// if asked, the upstream of each pushed branch.
+func (r *Repository) recordPush(updates []*RefUpdate, remote Remote, setUpstream bool) error {
+	if remote.Name != "" {
+		for _, update := range updates {
+			tracking := trackingRef(remote.Fetch, update.Dst)
+			if tracking == "" {
+				continue
+			}
+			if update.IsDelete() {
+				if err := r.DeleteRef(tracking); err != nil && !errors.Is(err, ErrRefNotFound) {
+					return err
+				}
+			} else if err := r.WriteRef(tracking, update.New, "update by push"); err != nil {
+				return err
+			}
+		}
+	}
+	if !setUpstream {
+		return nil
+	}
+
+	configFile, err := r.ConfigFile(ScopeLocal)
+	if err != nil {
+		return err
+	}
+	remoteName := remote.Name
+	if remoteName == "" {
+		remoteName = remote.URL
+	}
+	for _, update := range updates {
+		branch, isBranch := strings.CutPrefix(update.Src, "refs/heads/")
+		if !isBranch || update.IsDelete() || !strings.HasPrefix(update.Dst, "refs/heads/") {
+			continue
+		}
+		if err := configFile.Set("branch."+branch+".remote", remoteName); err != nil {
+			return err
+		}
+		if err := configFile.Set("branch."+branch+".merge", update.Dst); err != nil {
+			return err
+		}
+	}
+	return configFile.Save()
+}
+
+func (r *Repository) createPack(objects []string) ([]byte, error) {
+
+	header := []byte("PACK")
+	header = append(header, paddInteger(2, 4)...)
+	header = append(header, paddInteger(len(objects), 4)...)
+
+	body := []byte{}
+	for _, object := range objects {
+		encoded, err := r.encodePack(object)
+		if err != nil {
+			return nil, err
+		}
+		body = append(body, encoded...)
+	}
+
+	contents := append(header, []byte(body)...)
+
+	hasher := sha1.New()
+	hasher.Write(contents)
+	contents = append(contents, hasher.Sum(nil)...)
+
+	return contents, nil
+}
+
+func (r *Repository) encodePack(object string) ([]byte, error) {
+	objectType, data, err := r.ReadObject(object)
+	if err != nil {
+		return nil, err
+	}
+	header := []byte{}
+
+	enum := 0
+	if objectType == "commit" {
+		enum = 1
+	} else if objectType == "tree" {
+		enum = 2
+	} else if objectType == "blob" {
+		enum = 3
+	} else if objectType == "tag" {
+		enum = 4
+	}
+	size := len(data)
+	byt := byte(enum<<4 | size&0x0f)
+	size >>= 4
+	for size > 0 {
+		header = append(header, byt|0x80)
+		byt = byte(size & 0x7f)
+		size >>= 7
+	}
+	header = append(header, byt)
+
+	var buf bytes.Buffer
+	writer, _ := zlib.NewWriterLevel(&buf, zlib.BestSpeed)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	writer.Close()
+
+	compressedData := buf.Bytes()
+	header = append(header, compressedData...)
+	return header, nil
+}
+
+func hasCapability(capabilities []string, name string) bool {
+	for _, capability := range capabilities {
+		if capability == name || strings.HasPrefix(capability, name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// isHash reports whether s is a full 40 character hex object name.
+func isHash(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
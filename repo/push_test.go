@@ -0,0 +1,73 @@
+package repo
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// pktLines encodes lines as pkt-lines, with "" for a flush packet.
+func pktLines(lines ...string) string {
+	var encoded strings.Builder
+	for _, line := range lines {
+		if line == "" {
+			encoded.WriteString("0000")
+		} else {
+			fmt.Fprintf(&encoded, "%04x%s", len(line)+4, line)
+		}
+	}
+	return encoded.String()
+}
+
+// receivePackServer serves a receive-pack advertising capabilities for an
+// empty repository, answers pushes with response, and returns the
+// capabilities each push requested.
+func receivePackServer(t *testing.T, capabilities, response string) (string, *[]string) {
+	t.Helper()
+	requested := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "application/x-git-receive-pack-advertisement")
+			io.WriteString(w, pktLines("# service=git-receive-pack\n", "", ZeroHash+" capabilities^{}\x00"+capabilities+"\n", ""))
+			return
+		}
+		body, _ := io.ReadAll(req.Body)
+		line := string(body[4:])
+		_, caps, _ := strings.Cut(line[:strings.Index(line, "\n")], "\x00")
+		requested = append(requested, caps)
+		w.Header().Set("Content-Type", "application/x-git-receive-pack-result")
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL + "/repo.git", &requested
+}
+
+func TestPushRequestsAdvertisedCapabilities(t *testing.T) {
+	r := initTestRepo(t)
+	commitFile(t, r, "a", "1\n")
+	report := pktLines("unpack ok\n", "ok refs/heads/master\n", "")
+
+	for _, test := range []struct {
+		advertised, requested, response string
+	}{
+		{"report-status delete-refs ofs-delta", "report-status delete-refs", report},
+		{"report-status report-status-v2 side-band-64k", "report-status-v2 side-band-64k", pktLines("\x01"+report, "\x02remote: done\n", "")},
+		{"ofs-delta", "", ""},
+	} {
+		url, requested := receivePackServer(t, test.advertised, test.response)
+		result, err := r.Push(PushOptions{Remote: url, Refspecs: []string{"refs/heads/master"}})
+		if err != nil {
+			t.Errorf("pushing to a server with %q: %v", test.advertised, err)
+			continue
+		}
+		if len(*requested) != 1 || (*requested)[0] != test.requested {
+			t.Errorf("pushing to a server with %q requested %q, want %q", test.advertised, *requested, test.requested)
+		}
+		if len(result.Updates) != 1 || result.Updates[0].Status != RefUpdateOK {
+			t.Errorf("pushing to a server with %q: updates are %+v", test.advertised, result.Updates)
+		}
+	}
+}
@@ -0,0 +1,371 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReflogEntry is one line of a reflog: the ref moved from Old to New.
+type ReflogEntry struct {
+	Old string
+	New string
+	// Committer is who moved the ref, "name <email> timestamp timezone".
+	Committer string
+	Message   string
+}
+
+// Time returns when the ref moved.
+func (entry ReflogEntry) Time() time.Time {
+	return time.Unix(signatureTime(entry.Committer), 0)
+}
+
+// ReadReflog returns the reflog of ref, oldest entry first. A ref without
+// a reflog has no entries.
+func (r *Repository) ReadReflog(ref string) ([]ReflogEntry, error) {
+	data, err := os.ReadFile(r.path("logs", ref))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reflog of %s: %w", ref, err)
+	}
+	entries := []ReflogEntry{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+		header, message, _ := strings.Cut(line, "\t")
+		if len(header) < 82 || header[40] != ' ' || header[81] != ' ' {
+			continue
+		}
+		entries = append(entries, ReflogEntry{Old: header[:40], New: header[41:81], Committer: header[82:], Message: message})
+	}
+	return entries, nil
+}
+
+// HasReflog reports whether ref has a reflog.
+func (r *Repository) HasReflog(ref string) bool {
+	info, err := os.Stat(r.path("logs", ref))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// ReflogRefs returns the refs that have a reflog, HEAD first.
+func (r *Repository) ReflogRefs() ([]string, error) {
+	refs := []string{}
+	if r.HasReflog("HEAD") {
+		refs = append(refs, "HEAD")
+	}
+	root := r.path("logs")
+	err := filepath.Walk(filepath.Join(root, "refs"), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		refs = append(refs, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(refs[min(len(refs), 1):])
+	return refs, nil
+}
+
+// shouldLogRef reports whether updates of ref are recorded. Refs that have
+// a reflog always are; otherwise core.logAllRefUpdates decides, which by
+// default logs HEAD, branches, remote-tracking branches and notes outside
+// bare repositories.
+func (r *Repository) shouldLogRef(ref string) bool {
+	if r.HasReflog(ref) {
+		return true
+	}
+	setting := strings.ToLower(r.configValue("core.logallrefupdates"))
+	switch setting {
+	case "always":
+		return true
+	case "":
+		if r.IsBare() {
+			return false
+		}
+	default:
+		if enabled, _ := parseConfigBool(setting); !enabled {
+			return false
+		}
+	}
+	return ref == "HEAD" || strings.HasPrefix(ref, "refs/heads/") ||
+		strings.HasPrefix(ref, "refs/remotes/") || strings.HasPrefix(ref, "refs/notes/")
+}
+
+// logRefUpdate records that ref moved from old to new in its reflog, and in
+// the one of HEAD if HEAD points to ref.
+func (r *Repository) logRefUpdate(ref, old, new, message string) error {
+	refs := []string{ref}
+	if ref != "HEAD" {
+		if head, err := r.HeadBranch(); err == nil && head == ref {
+			refs = append(refs, "HEAD")
+		}
+	}
+	var line string
+	for _, name := range refs {
+		if !r.shouldLogRef(name) {
+			continue
+		}
+		if line == "" {
+			identity := r.reflogIdentity(time.Now())
+			// a message is a single line
+			message = strings.Join(strings.Fields(message), " ")
+			line = fmt.Sprintf("%s %s %s\t%s\n", old, new, identity, message)
+		}
+		if err := r.appendReflog(name, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reflogIdentity returns who is moving refs. Unlike commits, reflogs do not
+// need a configured identity and fall back to the login and host names.
+func (r *Repository) reflogIdentity(when time.Time) string {
+	if signature, err := r.signature(when); err == nil {
+		return signature
+	}
+	name, err := r.getUserName()
+	if err != nil {
+		name = "unknown"
+		if current, err := user.Current(); err == nil {
+			name = current.Username
+		}
+	}
+	email, err := r.getEmail()
+	if err != nil {
+		host, _ := os.Hostname()
+		email = name + "@" + host
+	}
+	return fmt.Sprintf("%s <%s> %d %s", name, email, when.Unix(), when.Format("-0700"))
+}
+
+// appendReflog adds line to the reflog of ref. It holds the lock of the
+// reflog meanwhile, so the line cannot get lost in a rewrite of the log.
+func (r *Repository) appendReflog(ref, line string) error {
+	path := r.path("logs", ref)
+	lock, err := lock(path)
+	if err != nil {
+		return fmt.Errorf("cannot lock reflog of %s: %w", ref, err)
+	}
+	defer lock.Rollback()
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open reflog of %s: %w", ref, err)
+	}
+	if _, err := file.WriteString(line); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write reflog of %s: %w", ref, err)
+	}
+	return file.Close()
+}
+
+// writeReflog replaces the reflog of ref, which lock holds, with entries.
+func writeReflog(lock *lockFile, entries []ReflogEntry) error {
+	var content strings.Builder
+	for _, entry := range entries {
+		fmt.Fprintf(&content, "%s %s %s\t%s\n", entry.Old, entry.New, entry.Committer, entry.Message)
+	}
+	if _, err := lock.Write([]byte(content.String())); err != nil {
+		return err
+	}
+	return lock.Commit()
+}
+
+// deleteReflog removes the reflog of ref and the directories it leaves
+// empty.
+func (r *Repository) deleteReflog(ref string) error {
+	path := r.path("logs", ref)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	removeEmptyRefDirs(path, ref)
+	return nil
+}
+
+// ReflogPruneOptions tells ExpireReflog and DeleteReflogEntries what to do
+// beside dropping entries.
+type ReflogPruneOptions struct {
+	// Expire makes ExpireReflog drop entries not newer than it. Zero
+	// keeps them.
+	Expire time.Time
+	// ExpireUnreachable makes ExpireReflog drop entries not newer than it
+	// whose old or new commit is not reachable from the current value of
+	// the ref, or of any ref for the reflog of HEAD. Zero keeps them.
+	ExpireUnreachable time.Time
+	// Rewrite sets the old value of each kept entry to the new value of
+	// the kept entry before it, so the log still reads as a chain.
+	Rewrite bool
+	// UpdateRef points the ref at the new value of the last kept entry.
+	UpdateRef bool
+	// DryRun reports what would be dropped without changing anything.
+	DryRun bool
+}
+
+// ExpireReflog drops the entries of the reflog of ref that opts expires
+// and returns them, oldest first.
+func (r *Repository) ExpireReflog(ref string, opts ReflogPruneOptions) ([]ReflogEntry, error) {
+	var reachable map[string]bool
+	return r.pruneReflog(ref, opts, func(_ int, entry ReflogEntry) (bool, error) {
+		when := entry.Time()
+		if !opts.Expire.IsZero() && !when.After(opts.Expire) {
+			return true, nil
+		}
+		if opts.ExpireUnreachable.IsZero() || when.After(opts.ExpireUnreachable) {
+			return false, nil
+		}
+		if reachable == nil {
+			tips, err := r.reflogTips(ref)
+			if err != nil {
+				return false, err
+			}
+			if reachable, err = r.reachableCommits(tips); err != nil {
+				return false, err
+			}
+		}
+		return !reachable[entry.New] || (entry.Old != zeroHash && !reachable[entry.Old]), nil
+	})
+}
+
+// DeleteReflogEntries drops the entries ref@{n} of the reflog of ref for
+// each n of positions, 0 being the newest, and returns them oldest first.
+func (r *Repository) DeleteReflogEntries(ref string, positions []int, opts ReflogPruneOptions) ([]ReflogEntry, error) {
+	entries, err := r.ReadReflog(ref)
+	if err != nil {
+		return nil, err
+	}
+	doomed := map[int]bool{}
+	for _, n := range positions {
+		if n < 0 || n >= len(entries) {
+			return nil, fmt.Errorf("reflog of %s has no entry %d", ref, n)
+		}
+		doomed[len(entries)-1-n] = true
+	}
+	return r.pruneReflog(ref, opts, func(i int, _ ReflogEntry) (bool, error) {
+		return doomed[i], nil
+	})
+}
+
+// pruneReflog drops the entries of the reflog of ref for which drop is true
+// and returns them. The reflog is locked while it is read and rewritten,
+// and the ref only moves if it still has the value it had then.
+func (r *Repository) pruneReflog(ref string, opts ReflogPruneOptions, drop func(int, ReflogEntry) (bool, error)) ([]ReflogEntry, error) {
+	lock, err := lock(r.path("logs", ref))
+	if err != nil {
+		return nil, fmt.Errorf("cannot lock reflog of %s: %w", ref, err)
+	}
+	defer lock.Rollback()
+	current, err := r.ReadRef(ref)
+	if errors.Is(err, ErrRefNotFound) {
+		current = zeroHash
+	} else if err != nil {
+		return nil, err
+	}
+	entries, err := r.ReadReflog(ref)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	kept, dropped := []ReflogEntry{}, []ReflogEntry{}
+	for i, entry := range entries {
+		doomed, err := drop(i, entry)
+		if err != nil {
+			return nil, err
+		}
+		if doomed {
+			dropped = append(dropped, entry)
+		} else {
+			kept = append(kept, entry)
+		}
+	}
+	if opts.DryRun || len(dropped) == 0 {
+		return dropped, nil
+	}
+	if opts.Rewrite {
+		previous := zeroHash
+		for i := range kept {
+			kept[i].Old = previous
+			previous = kept[i].New
+		}
+	}
+	if opts.UpdateRef && len(kept) > 0 && kept[len(kept)-1].New != zeroHash {
+		// the log is rewritten below, the move is not recorded in it
+		if _, _, err := r.updateRef(ref, kept[len(kept)-1].New, current); err != nil {
+			return nil, err
+		}
+	}
+	return dropped, writeReflog(lock, kept)
+}
+
+// reflogTips returns what entries of the reflog of ref must be reachable
+// from to be kept: the ref itself, and every ref for HEAD, which moves
+// between branches.
+func (r *Repository) reflogTips(ref string) ([]string, error) {
+	tips := []string{}
+	tip, err := r.ReadRef(ref)
+	if err == nil {
+		tips = append(tips, tip)
+	} else if !errors.Is(err, ErrRefNotFound) {
+		return nil, err
+	}
+	if ref == "HEAD" {
+		refs, err := r.ListRefs("refs/")
+		if err != nil {
+			return nil, err
+		}
+		for _, other := range refs {
+			tips = append(tips, other.Hash)
+		}
+	}
+	return tips, nil
+}
+
+// reachableCommits returns the commits reachable from tips. Tips that are
+// not commits only reach themselves.
+func (r *Repository) reachableCommits(tips []string) (map[string]bool, error) {
+	reachable := map[string]bool{}
+	queue := []string{}
+	for _, tip := range tips {
+		commit, err := r.peelTo(tip, "commit")
+		if err != nil {
+			reachable[tip] = true
+			continue
+		}
+		queue = append(queue, commit)
+	}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if reachable[current] {
+			continue
+		}
+		reachable[current] = true
+		commit, err := r.ReadCommit(current)
+		if errors.Is(err, ErrObjectNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		queue = append(queue, commit.Parents...)
+	}
+	return reachable, nil
+}
@@ -0,0 +1,105 @@
+package repo
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestReflog(t *testing.T) {
+	r := initTestRepo(t)
+	first := commitFile(t, r, "a", "1\n")
+	second := commitFile(t, r, "a", "2\n")
+	if err := r.WriteRef("refs/heads/topic", first, "branch: Created from HEAD~"); err != nil {
+		t.Fatal(err)
+	}
+
+	for ref, want := range map[string][]string{
+		"HEAD":              {zeroHash, first, second},
+		"refs/heads/master": {zeroHash, first, second},
+		"refs/heads/topic":  {zeroHash, first},
+	} {
+		entries, err := r.ReadReflog(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != len(want)-1 {
+			t.Errorf("reflog of %s has %d entries, want %d", ref, len(entries), len(want)-1)
+			continue
+		}
+		for i, entry := range entries {
+			if entry.Old != want[i] || entry.New != want[i+1] {
+				t.Errorf("entry %d of %s moves %s to %s, want %s to %s", i, ref, entry.Old, entry.New, want[i], want[i+1])
+			}
+		}
+	}
+	refs, err := r.ReflogRefs()
+	if err != nil || len(refs) != 3 || refs[0] != "HEAD" {
+		t.Errorf("refs with reflogs are %v, %v", refs, err)
+	}
+}
+
+func TestDeleteReflogEntries(t *testing.T) {
+	r := initTestRepo(t)
+	first := commitFile(t, r, "a", "1\n")
+	second := commitFile(t, r, "a", "2\n")
+	commitFile(t, r, "a", "3\n")
+
+	dropped, err := r.DeleteReflogEntries("refs/heads/master", []int{0}, ReflogPruneOptions{Rewrite: true, UpdateRef: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dropped) != 1 {
+		t.Errorf("%d entries were dropped", len(dropped))
+	}
+	if head, _ := r.ReadRef("refs/heads/master"); head != second {
+		t.Errorf("master is at %s, want %s", head, second)
+	}
+	entries, err := r.ReadReflog("refs/heads/master")
+	if err != nil || len(entries) != 2 || entries[1].Old != first || entries[1].New != second {
+		t.Errorf("reflog is %+v, %v", entries, err)
+	}
+}
+
+func TestPruneReflogRefMoved(t *testing.T) {
+	r := initTestRepo(t)
+	first := commitFile(t, r, "a", "1\n")
+	second := commitFile(t, r, "a", "2\n")
+	before, err := r.ReadReflog("refs/heads/master")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the ref moves while its reflog is pruned
+	_, err = r.pruneReflog("refs/heads/master", ReflogPruneOptions{UpdateRef: true}, func(i int, _ ReflogEntry) (bool, error) {
+		if i == 0 {
+			if _, _, err := r.updateRef("refs/heads/master", first, second); err != nil {
+				return false, err
+			}
+		}
+		return i == 1, nil
+	})
+	if !errors.Is(err, ErrRefMismatch) {
+		t.Errorf("pruning returned %v", err)
+	}
+	if after, _ := r.ReadReflog("refs/heads/master"); len(after) != len(before) {
+		t.Errorf("the reflog has %d entries, want %d", len(after), len(before))
+	}
+	if _, err := os.Stat(r.path("logs", "refs/heads/master.lock")); err == nil {
+		t.Error("the reflog lock was left behind")
+	}
+}
+
+func TestAppendReflogLocked(t *testing.T) {
+	r := initTestRepo(t)
+	commitFile(t, r, "a", "1\n")
+	if err := os.WriteFile(r.path("logs", "HEAD.lock"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.appendReflog("HEAD", zeroHash+" "+zeroHash+" x <x> 0 +0000\tline\n"); !errors.Is(err, ErrLocked) {
+		t.Errorf("appending to a locked reflog returned %v", err)
+	}
+	if _, err := r.ExpireReflog("HEAD", ReflogPruneOptions{}); !errors.Is(err, ErrLocked) {
+		t.Errorf("expiring a locked reflog returned %v", err)
+	}
+}
@@ -0,0 +1,403 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrRefNotFound is returned when a ref does not exist.
+var ErrRefNotFound = errors.New("ref not found")
+
+// maxSymrefDepth bounds how many symbolic refs are followed, as in git.
+const maxSymrefDepth = 5
+
+// Ref is a named pointer to an object.
+type Ref struct {
+	Name string
+	Hash string
+}
+
+// ErrSymrefLoop is returned when following symbolic refs leads back to a
+// ref already seen, or goes deeper than git allows.
+var ErrSymrefLoop = errors.New("symbolic ref loop")
+
+// ErrNotSymbolicRef is returned when a ref that should be symbolic holds a
+// hash or does not exist.
+var ErrNotSymbolicRef = errors.New("not a symbolic ref")
+
+// readRefValue returns the content of ref name: the name of the ref it
+// points to if it is symbolic, its hash otherwise. Loose refs take
+// precedence over packed-refs, which hold no symbolic refs.
+func (r *Repository) readRefValue(name string) (value string, symbolic bool, err error) {
+	data, err := os.ReadFile(r.path(name))
+	if os.IsNotExist(err) {
+		packed, found, err := r.findPackedRef(name)
+		if err != nil {
+			return "", false, err
+		}
+		if !found {
+			return "", false, fmt.Errorf("%w: %s", ErrRefNotFound, name)
+		}
+		return packed.Hash, false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read ref %s: %w", name, err)
+	}
+	content := strings.TrimSpace(string(data))
+	if target, found := strings.CutPrefix(content, "ref: "); found {
+		return strings.TrimSpace(target), true, nil
+	}
+	// FETCH_HEAD has a description after the first hash
+	if fields := strings.Fields(content); len(fields) > 0 {
+		return fields[0], false, nil
+	}
+	return content, false, nil
+}
+
+// ReadRef returns the hash a ref points to, following symbolic refs.
+func (r *Repository) ReadRef(name string) (string, error) {
+	target, err := r.ResolveRefName(name)
+	if err != nil {
+		return "", err
+	}
+	hash, _, err := r.readRefValue(target)
+	return hash, err
+}
+
+// ResolveRefName follows symbolic refs from name and returns the name of
+// the ref they end at, which may not exist yet, as the branch of a new
+// repository's HEAD.
+func (r *Repository) ResolveRefName(name string) (string, error) {
+	seen := map[string]bool{}
+	for depth := 0; ; depth++ {
+		value, symbolic, err := r.readRefValue(name)
+		if errors.Is(err, ErrRefNotFound) || (err == nil && !symbolic) {
+			return name, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		seen[name] = true
+		if seen[value] || depth == maxSymrefDepth {
+			return "", fmt.Errorf("%w: ref %s", ErrSymrefLoop, name)
+		}
+		name = value
+	}
+}
+
+// SymbolicRef returns the name of the ref the symbolic ref name points to,
+// without following it further.
+func (r *Repository) SymbolicRef(name string) (string, error) {
+	value, symbolic, err := r.readRefValue(name)
+	if errors.Is(err, ErrRefNotFound) || (err == nil && !symbolic) {
+		return "", fmt.Errorf("%w: %s", ErrNotSymbolicRef, name)
+	}
+	return value, err
+}
+
+// ErrRefMismatch is returned when a ref does not have the value an update
+// expects.
+var ErrRefMismatch = errors.New("ref does not have the expected value")
+
+// WriteRef points a ref at hash, following symbolic refs, and records the
+// move with message in the reflogs of the ref and of HEAD when it points
+// there.
+func (r *Repository) WriteRef(name, hash, message string) error {
+	return r.UpdateRef(name, hash, "", message)
+}
+
+// UpdateRef points name at new like WriteRef, or deletes it if new is the
+// zero hash, provided it points at old. The check and the update are done
+// under the lock of the ref, so concurrent updates cannot be lost. An
+// empty old skips the check, and the zero hash requires that the ref does
+// not exist.
+func (r *Repository) UpdateRef(name, new, old, message string) error {
+	target, current, err := r.updateRef(name, new, old)
+	if err != nil || new == zeroHash {
+		return err
+	}
+	// only log an update that happened
+	return r.logRefUpdate(target, current, new, message)
+}
+
+// updateRef is UpdateRef without recording the update in the reflogs. It
+// returns the ref that was updated and the value it had.
+func (r *Repository) updateRef(name, new, old string) (string, string, error) {
+	target, err := r.ResolveRefName(name)
+	if err != nil {
+		return "", "", err
+	}
+	lock, err := lock(r.path(target))
+	if err != nil {
+		return "", "", fmt.Errorf("cannot lock ref '%s': %w", target, err)
+	}
+	defer lock.Rollback()
+
+	current, err := r.ReadRef(target)
+	if errors.Is(err, ErrRefNotFound) {
+		current = zeroHash
+	} else if err != nil {
+		return "", "", err
+	}
+	if old != "" && old != current {
+		if current == zeroHash {
+			return "", "", fmt.Errorf("%w: '%s' does not exist but expected %s", ErrRefMismatch, target, old)
+		}
+		return "", "", fmt.Errorf("%w: '%s' is at %s but expected %s", ErrRefMismatch, target, current, old)
+	}
+	if new == zeroHash {
+		return target, current, r.removeRef(target, lock)
+	}
+
+	if _, err := lock.Write([]byte(new + "\n")); err != nil {
+		return "", "", fmt.Errorf("failed to write ref %s: %w", target, err)
+	}
+	return target, current, lock.Commit()
+}
+
+// writeRefFile stores hash as the loose ref name.
+func (r *Repository) writeRefFile(name, hash string) error {
+	return writeFileLocked(r.path(name), []byte(hash+"\n"))
+}
+
+// WriteSymbolicRef points the symbolic ref name, such as HEAD, at the ref
+// target. A non-empty message records the move in the reflog of name, if
+// target exists.
+func (r *Repository) WriteSymbolicRef(name, target, message string) error {
+	lock, err := lock(r.path(name))
+	if err != nil {
+		return fmt.Errorf("cannot lock ref '%s': %w", name, err)
+	}
+	defer lock.Rollback()
+	if _, err := lock.Write([]byte("ref: " + target + "\n")); err != nil {
+		return fmt.Errorf("failed to write ref %s: %w", name, err)
+	}
+	if message == "" {
+		return lock.Commit()
+	}
+	old, err := r.ReadRef(name)
+	if errors.Is(err, ErrRefNotFound) || errors.Is(err, ErrSymrefLoop) {
+		old = zeroHash
+	} else if err != nil {
+		return err
+	}
+	if err := lock.Commit(); err != nil {
+		return err
+	}
+	new, err := r.ReadRef(target)
+	if errors.Is(err, ErrRefNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return r.logRefUpdate(name, old, new, message)
+}
+
+// DeleteRef removes a ref and its reflog. A symbolic ref is removed itself,
+// not the ref it points to.
+func (r *Repository) DeleteRef(name string) error {
+	lock, err := lock(r.path(name))
+	if err != nil {
+		return fmt.Errorf("cannot lock ref '%s': %w", name, err)
+	}
+	defer lock.Rollback()
+	return r.removeRef(name, lock)
+}
+
+// removeRef deletes name from packed-refs and its loose file, releasing
+// its lock, then its reflog and the directories that leaves empty. The
+// packed entry goes first so the ref never shows an older packed value.
+func (r *Repository) removeRef(name string, lock *lockFile) error {
+	packed, err := r.removePackedRef(name)
+	if err != nil {
+		lock.Rollback()
+		return err
+	}
+	err = os.Remove(r.path(name))
+	lock.Rollback()
+	if err != nil && !(os.IsNotExist(err) && packed) {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: %s", ErrRefNotFound, name)
+		}
+		return err
+	}
+	removeEmptyRefDirs(r.path(name), name)
+	return r.deleteReflog(name)
+}
+
+// removeEmptyRefDirs removes the directories above file, where the ref
+// name or its reflog was stored, while they are empty. Like git it keeps
+// the top level ones such as refs/heads.
+func removeEmptyRefDirs(file, name string) {
+	parts := strings.Split(name, "/")
+	if len(parts) < 3 || parts[0] != "refs" {
+		return
+	}
+	root := strings.TrimSuffix(file, name)
+	removeEmptyDirs(filepath.Dir(file), filepath.Join(root, "refs", parts[1]))
+}
+
+// removeEmptyDirs removes dir and its parents below root while they are
+// empty.
+func removeEmptyDirs(dir, root string) {
+	for ; dir != root && strings.HasPrefix(dir, root+"/"); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
+
+// renameRefs moves every ref under oldPrefix, loose or packed, with its
+// reflog to newPrefix. Symbolic refs pointing under oldPrefix follow.
+func (r *Repository) renameRefs(oldPrefix, newPrefix string) error {
+	// loose refs go first: symbolic ones must still resolve to be found
+	loose, err := r.looseRefs()
+	if err != nil {
+		return err
+	}
+	for _, ref := range loose {
+		rest, found := strings.CutPrefix(ref.Name, oldPrefix)
+		if !found {
+			continue
+		}
+		data, err := os.ReadFile(r.path(ref.Name))
+		if err != nil {
+			return err
+		}
+		content := strings.Replace(string(data), "ref: "+oldPrefix, "ref: "+newPrefix, 1)
+		if err := writeFileLocked(r.path(newPrefix+rest), []byte(content)); err != nil {
+			return err
+		}
+		if r.HasReflog(ref.Name) {
+			if err := os.MkdirAll(filepath.Dir(r.path("logs", newPrefix+rest)), 0755); err != nil {
+				return err
+			}
+			if err := os.Rename(r.path("logs", ref.Name), r.path("logs", newPrefix+rest)); err != nil {
+				return err
+			}
+			removeEmptyRefDirs(r.path("logs", ref.Name), ref.Name)
+		}
+		if err := os.Remove(r.path(ref.Name)); err != nil {
+			return err
+		}
+		removeEmptyRefDirs(r.path(ref.Name), ref.Name)
+	}
+	_, err = r.rewritePackedRefs(func(ref string) (string, bool) {
+		if rest, found := strings.CutPrefix(ref, oldPrefix); found {
+			return newPrefix + rest, true
+		}
+		return ref, true
+	})
+	return err
+}
+
+// ListRefs returns the refs whose names start with prefix, sorted by name,
+// merging loose refs and packed-refs.
+func (r *Repository) ListRefs(prefix string) ([]Ref, error) {
+	loose, err := r.looseRefs()
+	if err != nil {
+		return nil, err
+	}
+	packed, err := r.readPackedRefs()
+	if err != nil {
+		return nil, err
+	}
+	refs := []Ref{}
+	seen := map[string]bool{}
+	for _, ref := range loose {
+		seen[ref.Name] = true
+		if strings.HasPrefix(ref.Name, prefix) {
+			refs = append(refs, ref.Ref)
+		}
+	}
+	for _, ref := range packed {
+		if !seen[ref.Name] && strings.HasPrefix(ref.Name, prefix) {
+			refs = append(refs, ref.Ref)
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Name < refs[j].Name })
+	return refs, nil
+}
+
+// HeadBranch returns the ref HEAD points to, e.g. refs/heads/master, or ""
+// if HEAD is detached. The branch may be unborn, without any commit yet.
+func (r *Repository) HeadBranch() (string, error) {
+	value, symbolic, err := r.readRefValue("HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	if !symbolic {
+		return "", nil
+	}
+	return value, nil
+}
+
+// refLookupOrder is the order in which an abbreviated ref name is tried.
+var refLookupOrder = []string{"%s", "refs/%s", "refs/tags/%s", "refs/heads/%s", "refs/remotes/%s", "refs/remotes/%s/HEAD"}
+
+// ExpandRef returns the full name of an abbreviated ref using the lookup
+// order of git rev-parse: name, refs/name, refs/tags/name,
+// refs/heads/name, refs/remotes/name and refs/remotes/name/HEAD.
+func (r *Repository) ExpandRef(name string) (string, error) {
+	for _, format := range refLookupOrder {
+		candidate := fmt.Sprintf(format, name)
+		if !strings.HasPrefix(candidate, "refs/") && !isPseudoRef(candidate) {
+			continue
+		}
+		if _, err := r.ReadRef(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrRefNotFound, name)
+}
+
+// ValidRefName reports whether name is a valid ref name by the rules of
+// git check-ref-format: slash separated components that do not start with
+// a dot or end with ".lock", without "..", "@{", control characters, space
+// or any of ~^:?*[\, and not ending with a slash or a dot.
+func ValidRefName(name string) bool {
+	if name == "" || name == "@" || strings.HasSuffix(name, ".") || strings.Contains(name, "..") || strings.Contains(name, "@{") {
+		return false
+	}
+	for _, component := range strings.Split(name, "/") {
+		if component == "" || strings.HasPrefix(component, ".") || strings.HasSuffix(component, ".lock") {
+			return false
+		}
+	}
+	for _, c := range name {
+		if c < 0x20 || c == 0x7f || strings.ContainsRune(" ~^:?*[\\", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// isPseudoRef reports whether name is a ref stored at the top of the git
+// directory, such as HEAD, FETCH_HEAD or ORIG_HEAD.
+func isPseudoRef(name string) bool {
+	if !strings.HasSuffix(name, "HEAD") {
+		return false
+	}
+	for _, c := range name {
+		if (c < 'A' || c > 'Z') && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// ShortRefName strips the refs/heads/, refs/tags/ or refs/remotes/ prefix
+// from a ref name for display.
+func ShortRefName(name string) string {
+	for _, prefix := range []string{"refs/heads/", "refs/tags/", "refs/remotes/"} {
+		if short, found := strings.CutPrefix(name, prefix); found {
+			return short
+		}
+	}
+	return name
+}
@@ -0,0 +1,191 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdateRef(t *testing.T) {
+	r := initTestRepo(t)
+	first := commitFile(t, r, "a", "1\n")
+	second := commitFile(t, r, "a", "2\n")
+
+	if err := r.UpdateRef("refs/heads/topic", first, ZeroHash, "create"); err != nil {
+		t.Fatalf("creating topic: %v", err)
+	}
+	if err := r.UpdateRef("refs/heads/topic", second, ZeroHash, "create again"); !errors.Is(err, ErrRefMismatch) {
+		t.Errorf("creating an existing ref returned %v, want ErrRefMismatch", err)
+	}
+	if err := r.UpdateRef("refs/heads/topic", second, second, "stale"); !errors.Is(err, ErrRefMismatch) {
+		t.Errorf("updating from a stale value returned %v, want ErrRefMismatch", err)
+	}
+	if err := r.UpdateRef("refs/heads/topic", second, first, "move"); err != nil {
+		t.Fatalf("moving topic: %v", err)
+	}
+	hash, err := r.ReadRef("refs/heads/topic")
+	if err != nil || hash != second {
+		t.Errorf("topic is %s, %v, want %s", hash, err, second)
+	}
+
+	entries, err := r.ReadReflog("refs/heads/topic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[1].Old != first || entries[1].New != second || entries[1].Message != "move" {
+		t.Errorf("reflog of topic is %+v", entries)
+	}
+
+	if err := r.UpdateRef("refs/heads/topic", ZeroHash, second, "delete"); err != nil {
+		t.Fatalf("deleting topic: %v", err)
+	}
+	if _, err := r.ReadRef("refs/heads/topic"); !errors.Is(err, ErrRefNotFound) {
+		t.Errorf("reading the deleted ref returned %v, want ErrRefNotFound", err)
+	}
+	if r.HasReflog("refs/heads/topic") {
+		t.Error("the reflog of the deleted ref is still there")
+	}
+}
+
+func TestUpdateRefThroughHead(t *testing.T) {
+	r := initTestRepo(t)
+	first := commitFile(t, r, "a", "1\n")
+	second := commitFile(t, r, "a", "2\n")
+
+	if err := r.UpdateRef("HEAD", first, second, "reset"); err != nil {
+		t.Fatal(err)
+	}
+	branch, err := r.HeadBranch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := r.ReadRef(branch)
+	if err != nil || hash != first {
+		t.Errorf("%s is %s, %v, want %s", branch, hash, err, first)
+	}
+	entries, err := r.ReadReflog("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := entries[len(entries)-1]; last.New != first || last.Message != "reset" {
+		t.Errorf("last HEAD reflog entry is %+v", last)
+	}
+}
+
+func TestValidRefName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"refs/heads/main":      true,
+		"refs/heads/feature/x": true,
+		"refs/heads/a..b":      false,
+		"refs/heads/x.lock":    false,
+		"refs/heads/a b":       false,
+		"refs/heads/":          false,
+		"refs/heads/x@{1}":     false,
+	} {
+		if got := ValidRefName(name); got != want {
+			t.Errorf("ValidRefName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestSymbolicRefs(t *testing.T) {
+	r := initTestRepo(t)
+	head := commitFile(t, r, "a", "1\n")
+
+	if err := r.WriteSymbolicRef("refs/heads/alias", "refs/heads/master", ""); err != nil {
+		t.Fatal(err)
+	}
+	if hash, err := r.ReadRef("refs/heads/alias"); err != nil || hash != head {
+		t.Errorf("alias is %s, %v", hash, err)
+	}
+	if target, err := r.SymbolicRef("refs/heads/alias"); err != nil || target != "refs/heads/master" {
+		t.Errorf("alias points to %s, %v", target, err)
+	}
+	for _, name := range []string{"refs/heads/master", "refs/heads/missing"} {
+		if _, err := r.SymbolicRef(name); !errors.Is(err, ErrNotSymbolicRef) {
+			t.Errorf("SymbolicRef(%s) returned %v", name, err)
+		}
+	}
+	// deleting a symbolic ref leaves its target alone
+	if err := r.DeleteRef("refs/heads/alias"); err != nil {
+		t.Fatal(err)
+	}
+	if hash, err := r.ReadRef("refs/heads/master"); err != nil || hash != head {
+		t.Errorf("after deleting alias master is %s, %v", hash, err)
+	}
+
+	// git follows at most five symbolic refs
+	for i := 0; i < 6; i++ {
+		name := fmt.Sprintf("refs/heads/chain%d", i)
+		if err := r.WriteSymbolicRef(name, fmt.Sprintf("refs/heads/chain%d", i+1), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.WriteRef("refs/heads/chain6", head, "end"); err != nil {
+		t.Fatal(err)
+	}
+	if hash, err := r.ReadRef("refs/heads/chain1"); err != nil || hash != head {
+		t.Errorf("five symbolic refs lead to %s, %v", hash, err)
+	}
+	if _, err := r.ReadRef("refs/heads/chain0"); !errors.Is(err, ErrSymrefLoop) {
+		t.Errorf("six symbolic refs returned %v", err)
+	}
+
+	for target, link := range map[string]string{"refs/heads/self": "refs/heads/self", "refs/heads/ping": "refs/heads/pong", "refs/heads/pong": "refs/heads/ping"} {
+		if err := r.WriteSymbolicRef(target, link, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"refs/heads/self", "refs/heads/ping"} {
+		if _, err := r.ResolveRefName(name); !errors.Is(err, ErrSymrefLoop) {
+			t.Errorf("resolving the loop %s returned %v", name, err)
+		}
+		if err := r.WriteRef(name, head, "loop"); !errors.Is(err, ErrSymrefLoop) {
+			t.Errorf("writing through the loop %s returned %v", name, err)
+		}
+	}
+}
+
+func TestCommitFollowsHead(t *testing.T) {
+	r := initTestRepo(t)
+	first := commitFile(t, r, "a", "1\n")
+
+	if err := r.WriteRef("refs/heads/topic", first, "branch"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.WriteSymbolicRef("HEAD", "refs/heads/topic", "checkout: moving from master to topic"); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := r.ReadReflog("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := entries[len(entries)-1]; last.Old != first || last.New != first || last.Message != "checkout: moving from master to topic" {
+		t.Errorf("the switch is logged as %+v", last)
+	}
+	second := commitFile(t, r, "a", "2\n")
+	for name, want := range map[string]string{"refs/heads/master": first, "refs/heads/topic": second} {
+		if hash, err := r.ReadRef(name); err != nil || hash != want {
+			t.Errorf("%s is %s, %v, want %s", name, hash, err, want)
+		}
+	}
+
+	// a detached HEAD moves itself
+	if err := os.WriteFile(filepath.Join(r.GitDir(), "HEAD"), []byte(first+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if branch, err := r.HeadBranch(); err != nil || branch != "" {
+		t.Errorf("the detached HEAD is on %q, %v", branch, err)
+	}
+	third := commitFile(t, r, "b", "3\n")
+	if hash, err := r.ReadRef("HEAD"); err != nil || hash != third {
+		t.Errorf("HEAD is %s, %v, want %s", hash, err, third)
+	}
+	for name, want := range map[string]string{"refs/heads/master": first, "refs/heads/topic": second} {
+		if hash, _ := r.ReadRef(name); hash != want {
+			t.Errorf("the detached commit moved %s to %s", name, hash)
+		}
+	}
+}
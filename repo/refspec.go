@@ -0,0 +1,87 @@
+package repo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Refspec maps source refs to destination refs, as in
+// "+refs/heads/*:refs/remotes/origin/*".
+type Refspec struct {
+	Force bool
+	Src   string
+	Dst   string
+}
+
+// ParseRefspec parses [+]<src>[:<dst>]. An empty src deletes dst.
+func ParseRefspec(spec string) (Refspec, error) {
+	refspec := Refspec{}
+	spec, refspec.Force = strings.CutPrefix(spec, "+")
+	src, dst, hasDst := strings.Cut(spec, ":")
+	refspec.Src = src
+	if hasDst {
+		refspec.Dst = dst
+	}
+	if refspec.Src == "" && refspec.Dst == "" {
+		return Refspec{}, fmt.Errorf("invalid refspec '%s'", spec)
+	}
+	if strings.Count(src, "*") > 1 || strings.Count(refspec.Dst, "*") > 1 ||
+		(hasDst && src != "" && strings.Contains(src, "*") != strings.Contains(refspec.Dst, "*")) {
+		return Refspec{}, fmt.Errorf("invalid refspec '%s'", spec)
+	}
+	return refspec, nil
+}
+
+// String formats the refspec the way it is written in config.
+func (refspec Refspec) String() string {
+	spec := refspec.Src
+	if refspec.Dst != "" {
+		spec += ":" + refspec.Dst
+	}
+	if refspec.Force {
+		spec = "+" + spec
+	}
+	return spec
+}
+
+// IsDelete reports whether the refspec deletes its destination.
+func (refspec Refspec) IsDelete() bool {
+	return refspec.Src == "" && refspec.Dst != ""
+}
+
+// IsPattern reports whether the refspec uses a "*" wildcard.
+func (refspec Refspec) IsPattern() bool {
+	return strings.Contains(refspec.Src, "*")
+}
+
+// Match maps name from the source side to the destination side and reports
+// whether the refspec applies to it.
+func (refspec Refspec) Match(name string) (string, bool) {
+	if !refspec.IsPattern() {
+		if name == refspec.Src {
+			return refspec.Dst, true
+		}
+		return "", false
+	}
+	prefix, suffix, _ := strings.Cut(refspec.Src, "*")
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) || len(name) < len(prefix)+len(suffix) {
+		return "", false
+	}
+	matched := name[len(prefix) : len(name)-len(suffix)]
+	return strings.Replace(refspec.Dst, "*", matched, 1), true
+}
+
+// trackingRef returns the remote-tracking ref that fetch refspecs map the
+// remote ref name to, or "" if none does.
+func trackingRef(fetch []string, name string) string {
+	for _, spec := range fetch {
+		refspec, err := ParseRefspec(spec)
+		if err != nil || refspec.Dst == "" {
+			continue
+		}
+		if dst, ok := refspec.Match(name); ok {
+			return dst
+		}
+	}
+	return ""
+}
@@ -0,0 +1,134 @@
+package repo
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseRefspec(t *testing.T) {
+	for _, test := range []struct {
+		spec string
+		want Refspec
+	}{
+		{"master", Refspec{Src: "master"}},
+		{"+refs/heads/*:refs/remotes/origin/*", Refspec{Force: true, Src: "refs/heads/*", Dst: "refs/remotes/origin/*"}},
+		{":refs/heads/old", Refspec{Dst: "refs/heads/old"}},
+		{"HEAD:refs/heads/other", Refspec{Src: "HEAD", Dst: "refs/heads/other"}},
+	} {
+		refspec, err := ParseRefspec(test.spec)
+		if err != nil || refspec != test.want {
+			t.Errorf("ParseRefspec(%q) = %+v, %v, want %+v", test.spec, refspec, err, test.want)
+		}
+		if refspec.String() != test.spec {
+			t.Errorf("%q is formatted as %q", test.spec, refspec.String())
+		}
+	}
+	for _, bad := range []string{"", ":", "+", "refs/*/*:refs/*", "refs/heads/*:refs/heads/master", "refs/heads/master:refs/heads/*"} {
+		if refspec, err := ParseRefspec(bad); err == nil {
+			t.Errorf("ParseRefspec(%q) = %+v", bad, refspec)
+		}
+	}
+}
+
+func TestRefspecMatch(t *testing.T) {
+	refspec, _ := ParseRefspec("+refs/heads/*:refs/remotes/origin/*")
+	for name, want := range map[string]string{
+		"refs/heads/master":  "refs/remotes/origin/master",
+		"refs/heads/a/b":     "refs/remotes/origin/a/b",
+		"refs/tags/v1":       "",
+		"refs/heads-like/xy": "",
+	} {
+		if dst, ok := refspec.Match(name); dst != want || ok != (want != "") {
+			t.Errorf("%s matches %q, %v, want %q", name, dst, ok, want)
+		}
+	}
+	exact, _ := ParseRefspec("refs/heads/master:refs/heads/main")
+	if dst, ok := exact.Match("refs/heads/master"); !ok || dst != "refs/heads/main" {
+		t.Errorf("refs/heads/master matches %q, %v", dst, ok)
+	}
+	if _, ok := exact.Match("refs/heads/main"); ok {
+		t.Error("the destination matches as a source")
+	}
+}
+
+func TestPlanPush(t *testing.T) {
+	r := initTestRepo(t)
+	first := commitFile(t, r, "a", "1\n")
+	second := commitFile(t, r, "a", "2\n")
+	if err := r.WriteRef("refs/heads/topic", first, "branch"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.WriteRef("refs/tags/v1", first, "tag"); err != nil {
+		t.Fatal(err)
+	}
+	remoteRefs := map[string]string{"refs/heads/master": first, "refs/tags/v1": second, "refs/heads/old": first}
+
+	for _, test := range []struct {
+		opts PushOptions
+		want string
+	}{
+		{PushOptions{}, "refs/heads/master:refs/heads/master=" + second},
+		{PushOptions{Refspecs: []string{"topic:other"}}, "refs/heads/topic:refs/heads/other=" + first},
+		{PushOptions{Refspecs: []string{"v1:v1"}}, "refs/tags/v1:refs/tags/v1=" + first},
+		{PushOptions{Refspecs: []string{":old"}}, ":refs/heads/old=" + zeroHash},
+		{PushOptions{Refspecs: []string{"+" + first + ":refs/heads/master"}}, "+:refs/heads/master=" + first},
+		{PushOptions{Refspecs: []string{"HEAD:master"}, Force: true}, "+refs/heads/master:refs/heads/master=" + second},
+		{PushOptions{All: true}, "refs/heads/master:refs/heads/master=" + second + " refs/heads/topic:refs/heads/topic=" + first},
+		{PushOptions{Tags: true}, "refs/tags/v1:refs/tags/v1=" + first},
+	} {
+		updates, err := r.planPush(test.opts, remoteRefs)
+		if err != nil {
+			t.Errorf("planPush(%+v): %v", test.opts, err)
+			continue
+		}
+		got := ""
+		for i, update := range updates {
+			if i > 0 {
+				got += " "
+			}
+			if update.Forced {
+				got += "+"
+			}
+			got += fmt.Sprintf("%s:%s=%s", update.Src, update.Dst, update.New)
+		}
+		if got != test.want {
+			t.Errorf("planPush(%+v) = %s, want %s", test.opts, got, test.want)
+		}
+	}
+
+	for _, specs := range [][]string{{"missing"}, {"master:refs/heads/x", "topic:refs/heads/x"}, {first}} {
+		if updates, err := r.planPush(PushOptions{Refspecs: specs}, remoteRefs); err == nil {
+			t.Errorf("planPush(%v) = %+v", specs, updates)
+		}
+	}
+}
+
+func TestCheckUpdate(t *testing.T) {
+	r := initTestRepo(t)
+	first := commitFile(t, r, "a", "1\n")
+	second := commitFile(t, r, "a", "2\n")
+	remoteRefs := map[string]string{"refs/heads/master": second, "refs/heads/old": first, "refs/tags/v1": first}
+
+	for _, test := range []struct {
+		update RefUpdate
+		want   RefUpdateStatus
+	}{
+		{RefUpdate{Dst: "refs/heads/new", New: second}, ""},
+		{RefUpdate{Dst: "refs/heads/old", New: second}, ""},
+		{RefUpdate{Dst: "refs/heads/master", New: second}, RefUpdateUpToDate},
+		{RefUpdate{Dst: "refs/heads/master", New: first}, RefUpdateRejected},
+		{RefUpdate{Dst: "refs/heads/master", New: first, Forced: true}, ""},
+		{RefUpdate{Dst: "refs/tags/v1", New: second}, RefUpdateRejected},
+		{RefUpdate{Dst: "refs/tags/v1", New: second, Forced: true}, ""},
+		{RefUpdate{Dst: "refs/heads/old", New: zeroHash}, ""},
+		{RefUpdate{Dst: "refs/heads/missing", New: zeroHash}, RefUpdateRejected},
+	} {
+		update := test.update
+		if err := r.checkUpdate(&update, nil, remoteRefs, Remote{}); err != nil {
+			t.Fatal(err)
+		}
+		if update.Status != test.want {
+			t.Errorf("%s to %s is %q (%s), want %q", update.Dst, update.New, update.Status, update.Reason, test.want)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package repo
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DefaultRemote is the remote used when none is named.
+const DefaultRemote = "origin"
+
+// Remote is a named remote repository from the [remote "<name>"] config
+// section.
+type Remote struct {
+	Name    string
+	URL     string
+	PushURL string
+	Fetch   []string
+}
+
+// PushTarget returns the URL pushes go to.
+func (remote Remote) PushTarget() string {
+	if remote.PushURL != "" {
+		return remote.PushURL
+	}
+	return remote.URL
+}
+
+// Remotes returns the configured remotes in config order.
+func (r *Repository) Remotes() ([]Remote, error) {
+	config, err := r.Config()
+	if err != nil {
+		return nil, err
+	}
+	remotes := []Remote{}
+	for _, name := range config.Subsections("remote") {
+		remotes = append(remotes, remoteFromConfig(config, name))
+	}
+	return remotes, nil
+}
+
+// Remote returns the remote called name.
+func (r *Repository) Remote(name string) (Remote, error) {
+	config, err := r.Config()
+	if err != nil {
+		return Remote{}, err
+	}
+	remote := remoteFromConfig(config, name)
+	if remote.URL == "" {
+		return Remote{}, fmt.Errorf("no such remote '%s'", name)
+	}
+	return remote, nil
+}
+
+func remoteFromConfig(config *Config, name string) Remote {
+	remote := Remote{Name: name, Fetch: config.GetAll("remote." + name + ".fetch")}
+	remote.URL, _ = config.Get("remote." + name + ".url")
+	remote.PushURL, _ = config.Get("remote." + name + ".pushurl")
+	return remote
+}
+
+// ResolveRemote returns the remote called nameOrURL, or an unnamed remote
+// if nameOrURL is a URL or path. An empty name means DefaultRemote.
+func (r *Repository) ResolveRemote(nameOrURL string) (Remote, error) {
+	if nameOrURL == "" {
+		nameOrURL = DefaultRemote
+	}
+	remote, err := r.Remote(nameOrURL)
+	if err == nil {
+		return remote, nil
+	}
+	if strings.ContainsAny(nameOrURL, ":/") {
+		return Remote{URL: nameOrURL}, nil
+	}
+	return Remote{}, fmt.Errorf("'%s' does not appear to be a git repository", nameOrURL)
+}
+
+// AddRemote adds a remote that fetches all branches of url.
+func (r *Repository) AddRemote(name, url string) error {
+	if !validRemoteName(name) {
+		return fmt.Errorf("'%s' is not a valid remote name", name)
+	}
+	configFile, err := r.ConfigFile(ScopeLocal)
+	if err != nil {
+		return err
+	}
+	if _, found := configFile.Get("remote." + name + ".url"); found {
+		return fmt.Errorf("remote %s already exists", name)
+	}
+	if err := configFile.Add("remote."+name+".url", url); err != nil {
+		return err
+	}
+	if err := configFile.Add("remote."+name+".fetch", defaultFetchRefspec(name)); err != nil {
+		return err
+	}
+	return configFile.Save()
+}
+
+// RemoveRemote removes a remote, its remote-tracking refs and the branch
+// settings that point at it.
+func (r *Repository) RemoveRemote(name string) error {
+	configFile, err := r.ConfigFile(ScopeLocal)
+	if err != nil {
+		return err
+	}
+	if !configFile.RemoveSection("remote", name) {
+		return fmt.Errorf("no such remote: '%s'", name)
+	}
+	// removing sections changes the entries, so find the branches first
+	branches := []string{}
+	for _, entry := range configFile.Entries() {
+		if entry.Section == "branch" && entry.Key == "remote" && entry.Value == name {
+			branches = append(branches, entry.Subsection)
+		}
+	}
+	for _, branch := range branches {
+		configFile.RemoveSection("branch", branch)
+	}
+	if err := configFile.Save(); err != nil {
+		return err
+	}
+	prefix := "refs/remotes/" + name + "/"
+	_, err = r.rewritePackedRefs(func(ref string) (string, bool) {
+		return ref, !strings.HasPrefix(ref, prefix)
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(r.path("refs", "remotes", name)); err != nil {
+		return err
+	}
+	return os.RemoveAll(r.path("logs", "refs", "remotes", name))
+}
+
+// RenameRemote renames a remote along with its remote-tracking refs.
+func (r *Repository) RenameRemote(oldName, newName string) error {
+	if !validRemoteName(newName) {
+		return fmt.Errorf("'%s' is not a valid remote name", newName)
+	}
+	configFile, err := r.ConfigFile(ScopeLocal)
+	if err != nil {
+		return err
+	}
+	if _, found := configFile.Get("remote." + newName + ".url"); found {
+		return fmt.Errorf("remote %s already exists", newName)
+	}
+	if !configFile.RenameSection("remote", oldName, newName) {
+		return fmt.Errorf("no such remote: '%s'", oldName)
+	}
+
+	fetch := configFile.GetAll("remote." + newName + ".fetch")
+	if _, err := configFile.UnsetAll("remote." + newName + ".fetch"); err != nil {
+		return err
+	}
+	for _, refspec := range fetch {
+		refspec = strings.Replace(refspec, "refs/remotes/"+oldName+"/", "refs/remotes/"+newName+"/", 1)
+		if err := configFile.Add("remote."+newName+".fetch", refspec); err != nil {
+			return err
+		}
+	}
+	for _, entry := range configFile.Entries() {
+		if entry.Section == "branch" && entry.Key == "remote" && entry.Value == oldName {
+			if err := configFile.Set("branch."+entry.Subsection+".remote", newName); err != nil {
+				return err
+			}
+		}
+	}
+	if err := configFile.Save(); err != nil {
+		return err
+	}
+
+	return r.renameRefs("refs/remotes/"+oldName+"/", "refs/remotes/"+newName+"/")
+}
+
+// SetRemoteURL changes the URL of a remote, or its push URL if push is set.
+func (r *Repository) SetRemoteURL(name, url string, push bool) error {
+	configFile, err := r.ConfigFile(ScopeLocal)
+	if err != nil {
+		return err
+	}
+	if _, found := configFile.Get("remote." + name + ".url"); !found {
+		return fmt.Errorf("no such remote '%s'", name)
+	}
+	key := "remote." + name + ".url"
+	if push {
+		key = "remote." + name + ".pushurl"
+	}
+	if err := configFile.Set(key, url); err != nil {
+		return err
+	}
+	return configFile.Save()
+}
+
+func defaultFetchRefspec(name string) string {
+	return "+refs/heads/*:refs/remotes/" + name + "/*"
+}
+
+func validRemoteName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, " \t\n:\\*?[~^") && !strings.HasPrefix(name, "-") &&
+		!strings.Contains(name, "..") && !strings.HasPrefix(name, "/") && !strings.HasSuffix(name, "/")
+}
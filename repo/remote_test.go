@@ -0,0 +1,53 @@
+package repo
+
+import "testing"
+
+func TestRemoveRemote(t *testing.T) {
+	r := initTestRepo(t)
+	for name, url := range map[string]string{"origin": "/srv/origin.git", "upstream": "/srv/upstream.git"} {
+		if err := r.AddRemote(name, url); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config, err := r.ConfigFile(ScopeLocal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, branch := range []struct{ name, remote string }{{"a", "origin"}, {"b", "origin"}, {"c", "upstream"}} {
+		if err := config.Set("branch."+branch.name+".remote", branch.remote); err != nil {
+			t.Fatal(err)
+		}
+		if err := config.Set("branch."+branch.name+".merge", "refs/heads/"+branch.name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := config.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.RemoveRemote("origin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RemoveRemote("origin"); err == nil {
+		t.Error("removing a missing remote succeeded")
+	}
+
+	config, err = r.ConfigFile(ScopeLocal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"remote.origin.url", "branch.a.remote", "branch.a.merge", "branch.b.remote", "branch.b.merge"} {
+		if value, found := config.Get(key); found {
+			t.Errorf("%s is still %s", key, value)
+		}
+	}
+	for key, want := range map[string]string{
+		"remote.upstream.url": "/srv/upstream.git",
+		"branch.c.remote":     "upstream",
+		"branch.c.merge":      "refs/heads/c",
+	} {
+		if value, _ := config.Get(key); value != want {
+			t.Errorf("%s is %q, want %q", key, value, want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package repo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setupTestEnv keeps the configuration of the user out of the tests and
+// gives them an identity to commit with.
+func setupTestEnv(t *testing.T) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_CONFIG_GLOBAL", "")
+	t.Setenv("GIT_DIR", "")
+	t.Setenv("GIT_WORK_TREE", "")
+	t.Setenv("GOGIT_USERNAME", "Test User")
+	t.Setenv("GOGIT_EMAIL", "test@example.com")
+}
+
+// initTestRepo creates an empty repository in a temporary directory.
+func initTestRepo(t *testing.T) *Repository {
+	t.Helper()
+	setupTestEnv(t)
+	r, err := Init(filepath.Join(t.TempDir(), "repo"))
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	return r
+}
+
+// commitFile writes content to the file name of the working tree, stages
+// it and commits it, returning the new commit.
+func commitFile(t *testing.T, r *Repository, name, content string) string {
+	t.Helper()
+	file := filepath.Join(r.WorkTree(), name)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Add([]string{name}); err != nil {
+		t.Fatalf("Add %s: %v", name, err)
+	}
+	hash, err := r.Commit(CommitOptions{Message: "change " + name + "\n"})
+	if err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	return hash
+}
+
+func TestCommit(t *testing.T) {
+	r := initTestRepo(t)
+	first := commitFile(t, r, "README", "hello\n")
+	second := commitFile(t, r, "README", "hello again\n")
+
+	head, err := r.ResolveRevision("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head != second {
+		t.Errorf("HEAD is %s, want %s", head, second)
+	}
+	commit, err := r.ReadCommit(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commit.Parents) != 1 || commit.Parents[0] != first {
+		t.Errorf("parents are %v, want [%s]", commit.Parents, first)
+	}
+	if commit.Message != "change README\n" {
+		t.Errorf("message is %q", commit.Message)
+	}
+}
+
+func TestCommitWritesNestedTrees(t *testing.T) {
+	r := initTestRepo(t)
+	for _, name := range []string{"top", "d/g", "d/e/f", "d.txt", "d-x"} {
+		commitFile(t, r, name, name+"\n")
+	}
+
+	tree, err := r.ResolveRevision("HEAD^{tree}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := r.ListTree(tree, ListTreeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a subtree sorts as if its name ended with a slash
+	want := []string{"d-x", "d.txt", "d", "top"}
+	if len(files) != len(want) {
+		t.Fatalf("root tree has %d entries, want %d", len(files), len(want))
+	}
+	for i, file := range files {
+		if file.Path != want[i] {
+			t.Errorf("entry %d is %s, want %s", i, file.Path, want[i])
+		}
+	}
+	if !files[2].IsTree() {
+		t.Errorf("d has mode %o, want a tree", files[2].Mode)
+	}
+
+	blob, err := r.ResolveRevision("HEAD:d/e/f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, contents, err := r.ReadObject(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "d/e/f\n" {
+		t.Errorf("d/e/f has %q", contents)
+	}
+}
@@ -0,0 +1,125 @@
+package repo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// pushReport is the report-status receive-pack sends after a push.
+type pushReport struct {
+	// unpack is "ok" or the reason the pack was not accepted.
+	unpack string
+	// refs maps each ref to "" if it was updated or the reason it was not.
+	refs map[string]string
+}
+
+// readPushReport parses the receive-pack response. With sideband, the
+// report is demultiplexed from band 1 and progress messages on band 2 are
+// copied to progress with a "remote: " prefix.
+func readPushReport(body []byte, sideband bool, progress io.Writer) (*pushReport, error) {
+	if sideband {
+		data, err := demuxSideband(newPktReader(bytes.NewReader(body)), progress)
+		if err != nil {
+			return nil, err
+		}
+		body = data
+	}
+
+	pkt := newPktReader(bytes.NewReader(body))
+	report := &pushReport{refs: map[string]string{}}
+	for {
+		line, err := pkt.ReadLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid report-status: %w", err)
+		}
+		switch {
+		case strings.HasPrefix(line, "unpack "):
+			report.unpack = strings.TrimPrefix(line, "unpack ")
+		case strings.HasPrefix(line, "ok "):
+			report.refs[strings.TrimPrefix(line, "ok ")] = ""
+		case strings.HasPrefix(line, "ng "):
+			ref, reason, _ := strings.Cut(strings.TrimPrefix(line, "ng "), " ")
+			if reason == "" {
+				reason = "failed"
+			}
+			report.refs[ref] = reason
+		case strings.HasPrefix(line, "option "):
+			// report-status-v2 details about the last ref, not needed here
+		default:
+			return nil, fmt.Errorf("invalid report-status line %q", line)
+		}
+	}
+	if report.unpack == "" {
+		return nil, errors.New("remote did not send a report-status")
+	}
+	return report, nil
+}
+
+// demuxSideband reads side-band packets until a flush and returns the
+// band 1 data. Band 2 goes to progress and band 3 is a fatal remote error.
+func demuxSideband(pkt *pktReader, progress io.Writer) ([]byte, error) {
+	remote := &remoteWriter{out: progress}
+	defer remote.Flush()
+
+	var data bytes.Buffer
+	for {
+		kind, payload, err := pkt.Read()
+		if err == io.EOF || (err == nil && kind != pktData) {
+			return data.Bytes(), nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid side-band packet: %w", err)
+		}
+		if len(payload) == 0 {
+			continue
+		}
+		switch payload[0] {
+		case 1:
+			data.Write(payload[1:])
+		case 2:
+			remote.Write(payload[1:])
+		case 3:
+			return nil, fmt.Errorf("remote error: %s", strings.TrimSpace(string(payload[1:])))
+		default:
+			return nil, fmt.Errorf("invalid side-band channel %d", payload[0])
+		}
+	}
+}
+
+// remoteWriter prefixes every line written to it with "remote: ". Both
+// "\n" and the "\r" used by progress meters end a line.
+type remoteWriter struct {
+	out     io.Writer
+	pending []byte
+}
+
+func (w *remoteWriter) Write(data []byte) (int, error) {
+	if w.out == nil {
+		return len(data), nil
+	}
+	w.pending = append(w.pending, data...)
+	for {
+		end := bytes.IndexAny(w.pending, "\r\n")
+		if end == -1 {
+			return len(data), nil
+		}
+		if _, err := fmt.Fprintf(w.out, "remote: %s", w.pending[:end+1]); err != nil {
+			return 0, err
+		}
+		w.pending = w.pending[end+1:]
+	}
+}
+
+// Flush writes a final line that was not terminated.
+func (w *remoteWriter) Flush() {
+	if w.out != nil && len(w.pending) > 0 {
+		fmt.Fprintf(w.out, "remote: %s\n", w.pending)
+		w.pending = nil
+	}
+}
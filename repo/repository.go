@@ -0,0 +1,266 @@
+// Package repo implements the core of gogit: objects, the index, refs and
+// the network protocol. Every operation returns an error instead of exiting,
+// so the package can be embedded in other tools; package main is only a
+// command line front end over it.
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrBareRepository is returned by operations that need a working tree when
+// the repository has none.
+var ErrBareRepository = errors.New("this operation must be run in a work tree")
+
+// Repository is a git repository on disk made of a .git directory and the
+// working tree it belongs to. The working tree is empty for bare
+// repositories.
+type Repository struct {
+	gitDir   string
+	workTree string
+	// commonDir holds what all worktrees share, like objects and branches.
+	// It is gitDir except in linked worktrees, whose git directory names
+	// it in its commondir file.
+	commonDir string
+
+	// packs caches the indexes of objects/pack, loaded on first use.
+	packMutex sync.Mutex
+	packs     []*packFile
+
+	// packed caches packed-refs, valid while the file matches packedInfo.
+	packedMutex sync.Mutex
+	packed      []packedRef
+	packedInfo  os.FileInfo
+}
+
+// Open opens the repository whose working tree is directory. The .git entry
+// may be a directory or a file pointing at one with "gitdir: <path>".
+func Open(directory string) (*Repository, error) {
+	if directory == "" {
+		directory = "."
+	}
+	directory, err := filepath.Abs(directory)
+	if err != nil {
+		return nil, err
+	}
+	gitDir, err := findGitDir(directory)
+	if err != nil {
+		return nil, err
+	}
+	if gitDir == "" {
+		return nil, fmt.Errorf("not a git repository: %s", directory)
+	}
+	return &Repository{gitDir: gitDir, workTree: directory, commonDir: commonGitDir(gitDir)}, nil
+}
+
+// OpenGitDir opens the repository stored in gitDir with the given working
+// tree, which may be empty for a bare repository.
+func OpenGitDir(gitDir, workTree string) (*Repository, error) {
+	gitDir, err := filepath.Abs(gitDir)
+	if err != nil {
+		return nil, err
+	}
+	if !isGitDir(gitDir) {
+		return nil, fmt.Errorf("not a git repository: %s", gitDir)
+	}
+	if workTree != "" {
+		if workTree, err = filepath.Abs(workTree); err != nil {
+			return nil, err
+		}
+	}
+	return &Repository{gitDir: gitDir, workTree: workTree, commonDir: commonGitDir(gitDir)}, nil
+}
+
+// OpenDir opens the repository at path, which may be a bare repository or
+// a working tree, the way servers find the repository a client asks for.
+func OpenDir(path string) (*Repository, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if isGitDir(path) {
+		return OpenGitDir(path, "")
+	}
+	r, err := Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' does not appear to be a git repository", path)
+	}
+	return r, nil
+}
+
+// Discover finds the repository containing directory by walking up to the
+// filesystem root, the way git does. GIT_DIR and GIT_WORK_TREE override the
+// search when they are set.
+func Discover(directory string) (*Repository, error) {
+	if directory == "" {
+		directory = "."
+	}
+	directory, err := filepath.Abs(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	workTree := os.Getenv("GIT_WORK_TREE")
+	if gitDir := os.Getenv("GIT_DIR"); gitDir != "" {
+		if workTree == "" {
+			workTree = directory
+		}
+		return OpenGitDir(gitDir, workTree)
+	}
+
+	for dir := directory; ; dir = filepath.Dir(dir) {
+		gitDir, err := findGitDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		if gitDir != "" {
+			if workTree == "" {
+				workTree = dir
+			}
+			return OpenGitDir(gitDir, workTree)
+		}
+		if isGitDir(dir) {
+			return OpenGitDir(dir, workTree)
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	return nil, fmt.Errorf("not a git repository (or any of the parent directories): %s", directory)
+}
+
+// findGitDir returns the git directory referred to by dir/.git, or "" if
+// there is none.
+func findGitDir(dir string) (string, error) {
+	dotGit := filepath.Join(dir, ".git")
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	if info.IsDir() {
+		return dotGit, nil
+	}
+
+	data, err := os.ReadFile(dotGit)
+	if err != nil {
+		return "", err
+	}
+	target, found := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !found {
+		return "", fmt.Errorf("invalid gitfile format: %s", dotGit)
+	}
+	target = strings.TrimSpace(target)
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(dir, target)
+	}
+	if !isGitDir(target) {
+		return "", fmt.Errorf("not a git repository: %s", target)
+	}
+	return target, nil
+}
+
+// isGitDir reports whether dir looks like a git directory, possibly the
+// one of a linked worktree.
+func isGitDir(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, "HEAD")); err != nil {
+		return false
+	}
+	common := commonGitDir(dir)
+	for _, name := range []string{"objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(common, name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// commonGitDir returns the directory holding what the worktrees of gitDir
+// share: the one its commondir file names, or gitDir itself.
+func commonGitDir(gitDir string) string {
+	data, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+	common := strings.TrimSpace(string(data))
+	if common == "" {
+		return gitDir
+	}
+	if !filepath.IsAbs(common) {
+		common = filepath.Join(gitDir, common)
+	}
+	return filepath.Clean(common)
+}
+
+// worktreePaths are the paths below the common directory that still belong
+// to each worktree, as in git.
+var worktreePaths = []string{"logs/HEAD", "refs/bisect", "refs/worktree", "refs/rewritten", "logs/refs/bisect", "logs/refs/worktree", "logs/refs/rewritten"}
+
+// commonPaths are the top level entries of a git directory shared by all
+// worktrees. Everything else, like HEAD and the index, is per worktree.
+var commonPaths = map[string]bool{
+	"branches": true, "config": true, "description": true, "hooks": true, "info": true, "logs": true,
+	"objects": true, "packed-refs": true, "refs": true, "remotes": true, "shallow": true, "worktrees": true,
+}
+
+// isCommonPath reports whether name, relative to the git directory, is
+// shared by all worktrees.
+func isCommonPath(name string) bool {
+	for _, own := range worktreePaths {
+		if name == own || strings.HasPrefix(name, own+"/") {
+			return false
+		}
+	}
+	top, _, _ := strings.Cut(name, "/")
+	return commonPaths[top]
+}
+
+// GitDir returns the path of the .git directory.
+func (r *Repository) GitDir() string {
+	return r.gitDir
+}
+
+// WorkTree returns the path of the working tree.
+func (r *Repository) WorkTree() string {
+	return r.workTree
+}
+
+// IsBare reports whether the repository has no working tree.
+func (r *Repository) IsBare() bool {
+	return r.workTree == ""
+}
+
+// Rel converts a path given relative to the current directory into a path
+// relative to the root of the working tree, as stored in the index.
+func (r *Repository) Rel(name string) (string, error) {
+	if r.IsBare() {
+		return "", ErrBareRepository
+	}
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(r.workTree, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("%s: '%s' is outside repository at '%s'", name, name, r.workTree)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// path joins elem to the .git directory, or to the common directory for
+// files shared by all worktrees.
+func (r *Repository) path(elem ...string) string {
+	name := path.Join(elem...)
+	if r.commonDir != "" && isCommonPath(name) {
+		return path.Join(r.commonDir, name)
+	}
+	return path.Join(r.gitDir, name)
+}
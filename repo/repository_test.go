@@ -0,0 +1,90 @@
+package repo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiscover(t *testing.T) {
+	r := initTestRepo(t)
+	sub := filepath.Join(r.WorkTree(), "a", "b")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := Discover(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.GitDir() != r.GitDir() || found.WorkTree() != r.WorkTree() {
+		t.Errorf("found %s with working tree %s", found.GitDir(), found.WorkTree())
+	}
+
+	// a .git file points to the git directory
+	linked := filepath.Join(t.TempDir(), "linked")
+	if err := os.MkdirAll(linked, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(linked, ".git"), []byte("gitdir: "+r.GitDir()+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	found, err = Discover(linked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.GitDir() != r.GitDir() || found.WorkTree() != linked {
+		t.Errorf("through a gitfile found %s with working tree %s", found.GitDir(), found.WorkTree())
+	}
+
+	// the git directory itself is a bare repository
+	if found, err := Discover(r.GitDir()); err != nil || !found.IsBare() {
+		t.Errorf("discovering from the git directory returned %+v, %v", found, err)
+	}
+
+	if _, err := Discover(t.TempDir()); err == nil {
+		t.Error("a repository was found outside of any")
+	}
+
+	t.Setenv("GIT_DIR", r.GitDir())
+	found, err = Discover(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.GitDir() != r.GitDir() || found.WorkTree() != sub {
+		t.Errorf("with GIT_DIR found %s with working tree %s", found.GitDir(), found.WorkTree())
+	}
+}
+
+func TestCommonDir(t *testing.T) {
+	r := initTestRepo(t)
+	// a linked worktree keeps HEAD and the index and shares the rest
+	gitDir := filepath.Join(r.GitDir(), "worktrees", "other")
+	if err := os.MkdirAll(gitDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(gitDir, "commondir"), []byte("../..\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(gitDir, "HEAD"), []byte("ref: refs/heads/other\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := OpenGitDir(gitDir, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"HEAD":             filepath.Join(gitDir, "HEAD"),
+		"index":            filepath.Join(gitDir, "index"),
+		"config":           filepath.Join(r.GitDir(), "config"),
+		"refs/heads/other": filepath.Join(r.GitDir(), "refs", "heads", "other"),
+		"objects/ab":       filepath.Join(r.GitDir(), "objects", "ab"),
+		"logs/HEAD":        filepath.Join(gitDir, "logs", "HEAD"),
+		"logs/refs/heads":  filepath.Join(r.GitDir(), "logs", "refs", "heads"),
+		"refs/bisect/bad":  filepath.Join(gitDir, "refs", "bisect", "bad"),
+	} {
+		if got := worktree.path(name); got != want {
+			t.Errorf("%s is at %s, want %s", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package repo
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// ResetMode selects what Reset changes besides the current branch.
+type ResetMode int
+
+const (
+	// ResetMixed makes the index match the commit, keeping the working
+	// tree.
+	ResetMixed ResetMode = iota
+	// ResetSoft only moves the branch.
+	ResetSoft
+	// ResetHard makes the index and the tracked files of the working tree
+	// match the commit.
+	ResetHard
+)
+
+// Reset points the current branch, or HEAD itself when detached, at the
+// commit revision names, recording the old value in ORIG_HEAD, and updates
+// the index and working tree as mode says. An empty revision means HEAD,
+// which may then be unborn: its branch stays as it is and the index is
+// emptied.
+func (r *Repository) Reset(revision string, mode ResetMode) error {
+	if mode != ResetSoft && r.IsBare() {
+		return ErrBareRepository
+	}
+	head, err := r.headCommit()
+	if err != nil {
+		return err
+	}
+	commit, tree, err := r.resetTarget(revision)
+	if err != nil {
+		return err
+	}
+	if commit != "" {
+		if head != "" {
+			if err := r.writeRefFile("ORIG_HEAD", head); err != nil {
+				return err
+			}
+		}
+		if revision == "" {
+			revision = "HEAD"
+		}
+		if err := r.UpdateRef("HEAD", commit, "", "reset: moving to "+revision); err != nil {
+			return err
+		}
+	}
+	switch mode {
+	case ResetMixed:
+		return r.resetIndex(tree, nil)
+	case ResetHard:
+		return r.resetWorkTree(tree)
+	}
+	return nil
+}
+
+// ResetPaths makes the index entries paths select match the commit
+// revision names, leaving HEAD and the working tree alone: entries that are
+// not in the commit are removed, which unstages new files. An empty
+// revision means HEAD, which may be unborn.
+func (r *Repository) ResetPaths(revision string, paths []string) error {
+	if r.IsBare() {
+		return ErrBareRepository
+	}
+	_, tree, err := r.resetTarget(revision)
+	if err != nil {
+		return err
+	}
+	return r.resetIndex(tree, paths)
+}
+
+// resetTarget returns the commit revision names and its tree. An empty
+// revision on an unborn branch gives neither.
+func (r *Repository) resetTarget(revision string) (commit, tree string, err error) {
+	if revision == "" {
+		head, err := r.headCommit()
+		if err != nil || head == "" {
+			return "", "", err
+		}
+		revision = head
+	}
+	object, err := r.ResolveRevision(revision)
+	if err != nil {
+		return "", "", err
+	}
+	if commit, err = r.peelTo(object, "commit"); err != nil {
+		return "", "", fmt.Errorf("could not parse object '%s': %w", revision, err)
+	}
+	parsed, err := r.ReadCommit(commit)
+	if err != nil {
+		return "", "", err
+	}
+	return commit, parsed.Tree, nil
+}
+
+// treeIndexEntries returns index entries for the files of tree, without
+// stat information. An empty tree has none.
+func (r *Repository) treeIndexEntries(tree string) ([]IndexEntry, error) {
+	if tree == "" {
+		return []IndexEntry{}, nil
+	}
+	files, err := r.ListTree(tree, ListTreeOptions{Recursive: true})
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]IndexEntry, 0, len(files))
+	for _, file := range files {
+		if err := verifyPath(file.Path); err != nil {
+			return nil, err
+		}
+		hash, err := hex.DecodeString(file.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tree entry %s: %w", file.Path, err)
+		}
+		entries = append(entries, IndexEntry{Mode: file.Mode, Sha1: hash, Path: file.Path, Flags: len(file.Path)})
+	}
+	return entries, nil
+}
+
+// resetIndex makes the index entries paths select, all if none, match
+// tree.
+func (r *Repository) resetIndex(tree string, paths []string) error {
+	lock, err := r.lockIndex()
+	if err != nil {
+		return err
+	}
+	defer lock.Rollback()
+	current, err := r.ReadIndex()
+	if err != nil {
+		return err
+	}
+	target, err := r.treeIndexEntries(tree)
+	if err != nil {
+		return err
+	}
+	return r.writeToIndexFile(lock, replaceIndexEntries(current, target, paths))
+}
+
+// replaceIndexEntries returns the index current with the entries paths
+// select, all if none, replaced by those of target. Entries that do not
+// change keep their stat information so they are not taken for modified
+// files.
+func replaceIndexEntries(current, target []IndexEntry, paths []string) []IndexEntry {
+	old := make(map[string]IndexEntry, len(current))
+	entries := []IndexEntry{}
+	for _, entry := range current {
+		if entry.Stage() == 0 {
+			old[entry.Path] = entry
+		}
+		if !MatchPathspec(paths, entry.Path) {
+			entries = append(entries, entry)
+		}
+	}
+	for _, entry := range target {
+		if !MatchPathspec(paths, entry.Path) {
+			continue
+		}
+		if previous, found := old[entry.Path]; found && previous.Mode == entry.Mode && previous.Hash() == entry.Hash() {
+			entry = previous
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries
+}
+
+// resetWorkTree checks out tree into the working tree and the index, and
+// removes the files that were tracked but are not in tree. Untracked files
+// are left alone.
+func (r *Repository) resetWorkTree(tree string) error {
+	lock, err := r.lockIndex()
+	if err != nil {
+		return err
+	}
+	defer lock.Rollback()
+	current, err := r.ReadIndex()
+	if err != nil {
+		return err
+	}
+	entries := []IndexEntry{}
+	if tree != "" {
+		if err := r.checkoutTreeAt(tree, "", &entries); err != nil {
+			return err
+		}
+	}
+	kept := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		kept[entry.Path] = true
+	}
+	for _, entry := range current {
+		if kept[entry.Path] {
+			continue
+		}
+		file := path.Join(r.workTree, entry.Path)
+		if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		removeEmptyDirs(filepath.Dir(file), r.workTree)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return r.writeToIndexFile(lock, entries)
+}
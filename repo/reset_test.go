@@ -0,0 +1,195 @@
+package repo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stagedContent returns the contents of path in the index.
+func stagedContent(t *testing.T, r *Repository, path string) string {
+	t.Helper()
+	entries, err := r.ReadIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.Path == path {
+			_, data, err := r.ReadObject(entry.Hash())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return string(data)
+		}
+	}
+	t.Fatalf("%s is not staged", path)
+	return ""
+}
+
+// indexPaths returns the paths of the index, separated by spaces.
+func indexPaths(t *testing.T, r *Repository) string {
+	t.Helper()
+	entries, err := r.ReadIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{}
+	for _, entry := range entries {
+		paths = append(paths, entry.Path)
+	}
+	return strings.Join(paths, " ")
+}
+
+// workTreeFile returns the contents of name in the working tree, or
+// "missing" if there is no such file.
+func workTreeFile(t *testing.T, r *Repository, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(r.WorkTree(), filepath.FromSlash(name)))
+	if os.IsNotExist(err) {
+		return "missing"
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestReset(t *testing.T) {
+	r := initTestRepo(t)
+	first := commitFile(t, r, "a", "1\n")
+	commitFile(t, r, "a", "2\n")
+	second := commitFile(t, r, "d/b", "new\n")
+	if err := os.WriteFile(filepath.Join(r.WorkTree(), "a"), []byte("3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(r.WorkTree(), "untracked"), []byte("u\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// soft only moves the branch
+	if err := r.Reset("HEAD~2", ResetSoft); err != nil {
+		t.Fatal(err)
+	}
+	if hash, _ := r.ReadRef("refs/heads/master"); hash != first {
+		t.Errorf("after a soft reset master is %s", hash)
+	}
+	if hash, _ := r.ReadRef("ORIG_HEAD"); hash != second {
+		t.Errorf("ORIG_HEAD is %s, want %s", hash, second)
+	}
+	if got := stagedContent(t, r, "a"); got != "2\n" || indexPaths(t, r) != "a d/b" {
+		t.Errorf("a soft reset changed the index to %s with a %q", indexPaths(t, r), got)
+	}
+
+	// mixed resets the index, keeping the working tree
+	if err := r.Reset("", ResetMixed); err != nil {
+		t.Fatal(err)
+	}
+	if got := stagedContent(t, r, "a"); got != "1\n" || indexPaths(t, r) != "a" {
+		t.Errorf("after a mixed reset the index has %s with a %q", indexPaths(t, r), got)
+	}
+	if workTreeFile(t, r, "a") != "3\n" || workTreeFile(t, r, "d/b") != "new\n" {
+		t.Error("a mixed reset changed the working tree")
+	}
+	status, err := r.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Modified) != 1 || status.Modified[0] != "a" {
+		t.Errorf("after a mixed reset the modified files are %v", status.Modified)
+	}
+
+	// hard resets the tracked files too
+	if err := r.Reset(second, ResetHard); err != nil {
+		t.Fatal(err)
+	}
+	if workTreeFile(t, r, "a") != "2\n" || workTreeFile(t, r, "d/b") != "new\n" || indexPaths(t, r) != "a d/b" {
+		t.Errorf("after a hard reset a is %q, d/b %q and the index %s", workTreeFile(t, r, "a"), workTreeFile(t, r, "d/b"), indexPaths(t, r))
+	}
+	entries, err := r.ReadReflog("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := entries[len(entries)-1]; last.New != second || last.Message != "reset: moving to "+second {
+		t.Errorf("the reset is logged as %+v", last)
+	}
+	if err := r.Reset(first, ResetHard); err != nil {
+		t.Fatal(err)
+	}
+	if workTreeFile(t, r, "a") != "1\n" || workTreeFile(t, r, "d/b") != "missing" || workTreeFile(t, r, "untracked") != "u\n" {
+		t.Error("a hard reset did not remove only the tracked files missing from the commit")
+	}
+	if _, err := os.Stat(filepath.Join(r.WorkTree(), "d")); !os.IsNotExist(err) {
+		t.Errorf("the emptied directory is still there: %v", err)
+	}
+
+	if err := r.Reset("missing", ResetMixed); err == nil {
+		t.Error("a reset to a missing revision succeeded")
+	}
+	tree, _ := r.ResolveRevision("HEAD^{tree}")
+	if err := r.Reset(tree, ResetSoft); err == nil {
+		t.Error("a reset to a tree succeeded")
+	}
+	if hash, _ := r.ReadRef("HEAD"); hash != first {
+		t.Errorf("failed resets moved HEAD to %s", hash)
+	}
+}
+
+func TestResetUnborn(t *testing.T) {
+	r := initTestRepo(t)
+	if err := os.WriteFile(filepath.Join(r.WorkTree(), "a"), []byte("1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Add([]string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reset("", ResetMixed); err != nil {
+		t.Fatal(err)
+	}
+	if paths := indexPaths(t, r); paths != "" {
+		t.Errorf("resetting an unborn branch left %s in the index", paths)
+	}
+	if _, err := r.ReadRef("HEAD"); !errors.Is(err, ErrRefNotFound) {
+		t.Errorf("resetting an unborn branch gave it a commit: %v", err)
+	}
+
+	bare, err := InitBare(filepath.Join(t.TempDir(), "bare.git"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bare.Reset("", ResetMixed); !errors.Is(err, ErrBareRepository) {
+		t.Errorf("a mixed reset in a bare repository returned %v", err)
+	}
+}
+
+func TestResetPaths(t *testing.T) {
+	r := initTestRepo(t)
+	first := commitFile(t, r, "a", "1\n")
+	commitFile(t, r, "b", "1\n")
+	for name, content := range map[string]string{"a": "2\n", "b": "2\n", "new": "new\n"} {
+		if err := os.WriteFile(filepath.Join(r.WorkTree(), name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Add([]string{"a", "b", "new"}); err != nil {
+		t.Fatal(err)
+	}
+	head, _ := r.ReadRef("HEAD")
+
+	if err := r.ResetPaths("", []string{"a", "new"}); err != nil {
+		t.Fatal(err)
+	}
+	if indexPaths(t, r) != "a b" || stagedContent(t, r, "a") != "1\n" || stagedContent(t, r, "b") != "2\n" {
+		t.Errorf("after resetting a and new the index has %s", indexPaths(t, r))
+	}
+	if err := r.ResetPaths(first, []string{"b"}); err != nil {
+		t.Fatal(err)
+	}
+	if indexPaths(t, r) != "a" {
+		t.Errorf("resetting b to a commit without it left %s", indexPaths(t, r))
+	}
+	if hash, _ := r.ReadRef("HEAD"); hash != head || workTreeFile(t, r, "a") != "2\n" || workTreeFile(t, r, "new") != "new\n" {
+		t.Error("resetting paths moved HEAD or changed the working tree")
+	}
+}
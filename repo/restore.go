@@ -0,0 +1,143 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// RestoreOptions selects what Restore restores and from where.
+type RestoreOptions struct {
+	// Source is the tree-ish to restore from. Empty means the index when
+	// only restoring the working tree and HEAD otherwise.
+	Source string
+	// Staged restores the index.
+	Staged bool
+	// Worktree restores the working tree. It is implied when Staged is not
+	// set.
+	Worktree bool
+}
+
+// Restore makes the files paths select match the source, in the index,
+// the working tree or both. Files the source does not have are removed
+// from them, as git restore does. Every path must match a file of the
+// source or the index.
+func (r *Repository) Restore(paths []string, opts RestoreOptions) error {
+	if r.IsBare() {
+		return ErrBareRepository
+	}
+	if !opts.Staged {
+		opts.Worktree = true
+	}
+	lock, err := r.lockIndex()
+	if err != nil {
+		return err
+	}
+	defer lock.Rollback()
+	current, err := r.ReadIndex()
+	if err != nil {
+		return err
+	}
+
+	source := []IndexEntry{}
+	if opts.Source != "" || opts.Staged {
+		tree, err := r.restoreSourceTree(opts.Source)
+		if err != nil {
+			return err
+		}
+		if source, err = r.treeIndexEntries(tree); err != nil {
+			return err
+		}
+	} else {
+		for _, entry := range current {
+			if entry.Stage() == 0 {
+				source = append(source, entry)
+			}
+		}
+	}
+	for _, spec := range paths {
+		if !pathspecMatchesAny(spec, source) && !pathspecMatchesAny(spec, current) {
+			return fmt.Errorf("pathspec '%s' did not match any file(s) known to git", spec)
+		}
+	}
+
+	entries := current
+	if opts.Staged {
+		entries = replaceIndexEntries(current, source, paths)
+	}
+	if opts.Worktree {
+		if err := r.restoreWorkTree(current, source, entries, paths); err != nil {
+			return err
+		}
+	}
+	return r.writeToIndexFile(lock, entries)
+}
+
+// restoreSourceTree returns the tree source names, HEAD if empty. An unborn
+// HEAD has the empty tree, given as "".
+func (r *Repository) restoreSourceTree(source string) (string, error) {
+	if source == "" {
+		head, err := r.headCommit()
+		if err != nil || head == "" {
+			return "", err
+		}
+		source = head
+	}
+	object, err := r.ResolveRevision(source)
+	if err != nil {
+		return "", err
+	}
+	tree, err := r.peelTo(object, "tree")
+	if err != nil {
+		return "", fmt.Errorf("could not resolve %s: %w", source, err)
+	}
+	return tree, nil
+}
+
+// restoreWorkTree writes the files of source that paths select to the
+// working tree and removes those only in the index current. The entries
+// of the new index that record the written contents get fresh stat
+// information, so they no longer look modified.
+func (r *Repository) restoreWorkTree(current, source, entries []IndexEntry, paths []string) error {
+	positions := make(map[string]int, len(entries))
+	for i, entry := range entries {
+		positions[entry.Path] = i
+	}
+	restored := map[string]bool{}
+	for _, entry := range source {
+		if !MatchPathspec(paths, entry.Path) || entry.Mode == 0o160000 {
+			continue
+		}
+		written, err := r.checkoutFile(entry.Path, entry.Hash(), entry.Mode)
+		if err != nil {
+			return err
+		}
+		restored[entry.Path] = true
+		if i, found := positions[entry.Path]; found && entries[i].Stage() == 0 && entries[i].Mode == entry.Mode && entries[i].Hash() == entry.Hash() {
+			entries[i] = written
+		}
+	}
+	for _, entry := range current {
+		if restored[entry.Path] || !MatchPathspec(paths, entry.Path) {
+			continue
+		}
+		file := path.Join(r.workTree, entry.Path)
+		if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		removeEmptyDirs(filepath.Dir(file), r.workTree)
+	}
+	return nil
+}
+
+// pathspecMatchesAny reports whether spec selects one of entries.
+func pathspecMatchesAny(spec string, entries []IndexEntry) bool {
+	for _, entry := range entries {
+		if MatchPathspec([]string{spec}, entry.Path) {
+			return true
+		}
+	}
+	return false
+}
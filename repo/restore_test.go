@@ -0,0 +1,98 @@
+package repo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRestore(t *testing.T) {
+	r := initTestRepo(t)
+	first := commitFile(t, r, "a", "1\n")
+	commitFile(t, r, "a", "2\n")
+	commitFile(t, r, "d/b", "b\n")
+	writeFile := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(r.WorkTree(), filepath.FromSlash(name)), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("a", "3\n")
+	if err := r.Add([]string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	writeFile("a", "4\n")
+
+	// the working tree comes from the index by default
+	if err := r.Restore([]string{"a"}, RestoreOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if workTreeFile(t, r, "a") != "3\n" || stagedContent(t, r, "a") != "3\n" {
+		t.Errorf("after restoring a it is %q, staged %q", workTreeFile(t, r, "a"), stagedContent(t, r, "a"))
+	}
+	status, err := r.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Modified) != 0 {
+		t.Errorf("the restored file looks modified: %v", status.Modified)
+	}
+
+	// --staged comes from HEAD and leaves the working tree
+	if err := r.Restore([]string{"a"}, RestoreOptions{Staged: true}); err != nil {
+		t.Fatal(err)
+	}
+	if workTreeFile(t, r, "a") != "3\n" || stagedContent(t, r, "a") != "2\n" {
+		t.Errorf("after restoring the staged a it is %q, staged %q", workTreeFile(t, r, "a"), stagedContent(t, r, "a"))
+	}
+
+	// both from another commit, removing what it does not have
+	if err := r.Restore([]string{"."}, RestoreOptions{Source: first, Staged: true, Worktree: true}); err != nil {
+		t.Fatal(err)
+	}
+	if workTreeFile(t, r, "a") != "1\n" || stagedContent(t, r, "a") != "1\n" || workTreeFile(t, r, "d/b") != "missing" || indexPaths(t, r) != "a" {
+		t.Errorf("after restoring from %s a is %q and the index %s", first, workTreeFile(t, r, "a"), indexPaths(t, r))
+	}
+	if hash, _ := r.ReadRef("HEAD"); hash == first {
+		t.Error("restore moved HEAD")
+	}
+
+	if err := r.Restore([]string{"missing"}, RestoreOptions{}); err == nil {
+		t.Error("restoring an unknown path succeeded")
+	}
+	if err := r.Restore([]string{"a"}, RestoreOptions{Source: "missing"}); err == nil {
+		t.Error("restoring from an unknown source succeeded")
+	}
+}
+
+func TestRestoreKeepsExecutableMode(t *testing.T) {
+	r := initTestRepo(t)
+	script := filepath.Join(r.WorkTree(), "run.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Add([]string{"run.sh"}); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, r, "plain", "text\n")
+	entries, err := r.ReadIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if want := map[string]int{"plain": 0o100644, "run.sh": 0o100755}[entry.Path]; entry.Mode != want {
+			t.Errorf("%s is recorded with mode %o, want %o", entry.Path, entry.Mode, want)
+		}
+	}
+
+	if err := os.Remove(script); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Restore([]string{"run.sh"}, RestoreOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(script)
+	if err != nil || info.Mode().Perm()&0o111 == 0 {
+		t.Errorf("the restored script is %v, %v", info, err)
+	}
+}
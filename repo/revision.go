@@ -0,0 +1,424 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownRevision is returned when a revision names nothing.
+var ErrUnknownRevision = errors.New("unknown revision")
+
+// ErrAmbiguousRevision is returned when an abbreviated object name matches
+// several objects.
+var ErrAmbiguousRevision = errors.New("ambiguous")
+
+// minAbbrev is the shortest object name prefix accepted or printed.
+const minAbbrev = 4
+
+// ResolveRevision returns the object a revision names, as described in
+// gitrevisions(7). Supported are full and abbreviated object names, refs
+// looked up like ExpandRef, @ for HEAD, <ref>@{<n>} reflog entries,
+// <branch>@{upstream} (or @{u}), the suffixes ~<n>, ^<n> and
+// ^{<type>}, <rev>:<path> for an entry of a tree and :[<stage>:]<path> for
+// an entry of the index.
+func (r *Repository) ResolveRevision(revision string) (string, error) {
+	if rest, found := strings.CutPrefix(revision, ":"); found {
+		return r.resolveIndexPath(rest)
+	}
+	if rev, path, found := cutRevisionPath(revision); found {
+		object, err := r.ResolveRevision(rev)
+		if err != nil {
+			return "", err
+		}
+		tree, err := r.peelTo(object, "tree")
+		if err != nil {
+			return "", err
+		}
+		return r.resolveTreePath(tree, path, rev)
+	}
+
+	base, suffixes := splitRevision(revision)
+	hash, err := r.resolveRevisionBase(base)
+	if err != nil {
+		return "", err
+	}
+	for suffixes != "" {
+		operator := suffixes[0]
+		suffixes = suffixes[1:]
+		if operator == '^' && strings.HasPrefix(suffixes, "{") {
+			end := strings.IndexByte(suffixes, '}')
+			if end == -1 {
+				return "", fmt.Errorf("invalid revision '%s'", revision)
+			}
+			objectType := suffixes[1:end]
+			suffixes = suffixes[end+1:]
+			if objectType == "" {
+				if hash, _, err = r.peel(hash); err != nil {
+					return "", err
+				}
+				continue
+			}
+			if hash, err = r.peelTo(hash, objectType); err != nil {
+				return "", err
+			}
+			continue
+		}
+		if operator != '^' && operator != '~' {
+			return "", fmt.Errorf("invalid revision '%s'", revision)
+		}
+
+		digits := len(suffixes) - len(strings.TrimLeft(suffixes, "0123456789"))
+		n := 1
+		if digits > 0 {
+			if n, err = strconv.Atoi(suffixes[:digits]); err != nil {
+				return "", fmt.Errorf("invalid revision '%s'", revision)
+			}
+			suffixes = suffixes[digits:]
+		}
+		if operator == '~' {
+			for i := 0; i < n; i++ {
+				if hash, err = r.nthParent(hash, 1, revision); err != nil {
+					return "", err
+				}
+			}
+		} else if hash, err = r.nthParent(hash, n, revision); err != nil {
+			return "", err
+		}
+	}
+	return hash, nil
+}
+
+// cutRevisionPath splits <rev>:<path> at the first colon that is not
+// inside braces, such as those of @{...}.
+func cutRevisionPath(revision string) (string, string, bool) {
+	depth := 0
+	for i, c := range revision {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ':':
+			if depth == 0 {
+				return revision[:i], revision[i+1:], true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// splitRevision splits a revision into the name it starts from, including
+// any @{...}, and the ~ and ^ suffixes that follow.
+func splitRevision(revision string) (string, string) {
+	if at := strings.Index(revision, "@{"); at != -1 {
+		if end := strings.IndexByte(revision[at:], '}'); end != -1 {
+			return revision[:at+end+1], revision[at+end+1:]
+		}
+	}
+	if i := strings.IndexAny(revision, "~^"); i != -1 {
+		return revision[:i], revision[i:]
+	}
+	return revision, ""
+}
+
+// resolveRevisionBase resolves the name a revision starts from.
+func (r *Repository) resolveRevisionBase(base string) (string, error) {
+	if name, selector, found := strings.Cut(base, "@{"); found {
+		selector = strings.TrimSuffix(selector, "}")
+		switch strings.ToLower(selector) {
+		case "u", "upstream":
+			ref, err := r.upstreamRef(name)
+			if err != nil {
+				return "", err
+			}
+			return r.ReadRef(ref)
+		}
+		n, err := strconv.Atoi(selector)
+		if err != nil || n < 0 {
+			return "", fmt.Errorf("unsupported reflog selector '@{%s}'", selector)
+		}
+		return r.reflogEntry(name, n)
+	}
+
+	switch {
+	case base == "":
+		return "", errors.New("empty revision")
+	case base == "@":
+		base = "HEAD"
+	case isHash(base):
+		return base, nil
+	}
+	if name, err := r.ExpandRef(base); err == nil {
+		return r.ReadRef(name)
+	}
+	if len(base) >= minAbbrev && isHex(strings.ToLower(base)) {
+		objects, err := r.objectsWithPrefix(base)
+		if err != nil {
+			return "", err
+		}
+		if len(objects) == 1 {
+			return objects[0], nil
+		}
+		if len(objects) > 1 {
+			return "", fmt.Errorf("short object ID %s is %w", base, ErrAmbiguousRevision)
+		}
+	}
+	return "", fmt.Errorf("%w '%s'", ErrUnknownRevision, base)
+}
+
+// isHex reports whether s is made of lower case hex digits.
+func isHex(s string) bool {
+	return strings.Trim(s, "0123456789abcdef") == ""
+}
+
+// objectsWithPrefix returns the objects, loose or packed, whose names
+// start with prefix.
+func (r *Repository) objectsWithPrefix(prefix string) ([]string, error) {
+	prefix = strings.ToLower(prefix)
+	if len(prefix) < 2 || !isHex(prefix) {
+		return nil, nil
+	}
+	seen := map[string]bool{}
+	entries, err := os.ReadDir(r.path("objects", prefix[:2]))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if name := prefix[:2] + entry.Name(); len(name) == 40 && strings.HasPrefix(name, prefix) {
+			seen[name] = true
+		}
+	}
+	packs, err := r.packFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, pack := range packs {
+		for _, name := range pack.withPrefix(prefix) {
+			seen[name] = true
+		}
+	}
+	objects := make([]string, 0, len(seen))
+	for name := range seen {
+		objects = append(objects, name)
+	}
+	sort.Strings(objects)
+	return objects, nil
+}
+
+// AbbreviateHash returns the shortest prefix of hash, of at least length
+// characters, that names no other object.
+func (r *Repository) AbbreviateHash(hash string, length int) (string, error) {
+	length = max(length, minAbbrev)
+	for ; length < len(hash); length++ {
+		objects, err := r.objectsWithPrefix(hash[:length])
+		if err != nil {
+			return "", err
+		}
+		if len(objects) <= 1 {
+			return hash[:length], nil
+		}
+	}
+	return hash, nil
+}
+
+// nthParent returns parent n of commit, or commit itself for 0.
+func (r *Repository) nthParent(object string, n int, revision string) (string, error) {
+	commit, err := r.peelTo(object, "commit")
+	if err != nil {
+		return "", err
+	}
+	if n == 0 {
+		return commit, nil
+	}
+	parsed, err := r.ReadCommit(commit)
+	if err != nil {
+		return "", err
+	}
+	if n > len(parsed.Parents) {
+		return "", fmt.Errorf("%w '%s'", ErrUnknownRevision, revision)
+	}
+	return parsed.Parents[n-1], nil
+}
+
+// peelTo follows tags, and commits to their tree, from object until it
+// reaches an object of objectType. "object" accepts any object.
+func (r *Repository) peelTo(object, objectType string) (string, error) {
+	start := object
+	for {
+		current, contents, err := r.ReadObject(object)
+		if err != nil {
+			return "", err
+		}
+		if current == objectType || objectType == "object" {
+			return object, nil
+		}
+		switch {
+		case current == "tag":
+			if object, _, err = parseTagTarget(contents); err != nil {
+				return "", err
+			}
+		case current == "commit" && objectType == "tree":
+			commit, err := parseCommit(contents)
+			if err != nil {
+				return "", err
+			}
+			object = commit.Tree
+		default:
+			return "", fmt.Errorf("%s^{%s}: expected %s type, but the object dereferences to %s type", start, objectType, objectType, current)
+		}
+	}
+}
+
+// resolveTreePath returns the entry at path below tree.
+func (r *Repository) resolveTreePath(tree, path, revision string) (string, error) {
+	object := tree
+	for _, name := range strings.Split(path, "/") {
+		if name == "" {
+			continue
+		}
+		objectType, contents, err := r.ReadObject(object)
+		if err != nil {
+			return "", err
+		}
+		if objectType != "tree" {
+			return "", fmt.Errorf("path '%s' does not exist in '%s'", path, revision)
+		}
+		entries, err := parseTree(contents)
+		if err != nil {
+			return "", err
+		}
+		found := false
+		for _, entry := range entries {
+			if entry.Name == name {
+				object, found = entry.Hash, true
+				break
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("path '%s' does not exist in '%s'", path, revision)
+		}
+	}
+	return object, nil
+}
+
+// resolveIndexPath returns the blob staged for [<stage>:]<path>.
+func (r *Repository) resolveIndexPath(spec string) (string, error) {
+	stage, path := 0, spec
+	if len(spec) >= 2 && spec[1] == ':' && spec[0] >= '0' && spec[0] <= '3' {
+		stage, path = int(spec[0]-'0'), spec[2:]
+	}
+	entries, err := r.ReadIndex()
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		if entry.Path == path && entry.Stage() == stage {
+			return entry.Hash(), nil
+		}
+	}
+	if stage != 0 {
+		return "", fmt.Errorf("path '%s' is not in the index at stage %d", path, stage)
+	}
+	return "", fmt.Errorf("path '%s' is not in the index", path)
+}
+
+// reflogEntry returns the value ref had n changes ago, from its reflog.
+// An empty ref means the current branch.
+func (r *Repository) reflogEntry(ref string, n int) (string, error) {
+	switch ref {
+	case "":
+		head, err := r.HeadBranch()
+		if err != nil {
+			return "", err
+		}
+		ref = head
+		if ref == "" {
+			ref = "HEAD"
+		}
+	case "@":
+		ref = "HEAD"
+	default:
+		name, err := r.ExpandRef(ref)
+		if err != nil {
+			return "", fmt.Errorf("%w '%s'", ErrUnknownRevision, ref)
+		}
+		ref = name
+	}
+	entries, err := r.ReadReflog(ref)
+	if err != nil {
+		return "", err
+	}
+	if n >= len(entries) {
+		if n == 0 {
+			return r.ReadRef(ref)
+		}
+		return "", fmt.Errorf("log for '%s' only has %d entries", ShortRefName(ref), len(entries))
+	}
+	return entries[len(entries)-1-n].New, nil
+}
+
+// upstreamRef returns the remote-tracking ref branch.<name>.merge maps to,
+// or the merged branch itself for a remote of ".". An empty name or "@"
+// means the current branch.
+func (r *Repository) upstreamRef(name string) (string, error) {
+	var branch string
+	if name == "" || name == "@" || name == "HEAD" {
+		head, err := r.HeadBranch()
+		if err != nil {
+			return "", err
+		}
+		if head == "" {
+			return "", errors.New("HEAD does not point to a branch")
+		}
+		branch = head
+	} else {
+		full, err := r.ExpandRef(name)
+		if err != nil || !strings.HasPrefix(full, "refs/heads/") {
+			return "", fmt.Errorf("no such branch: '%s'", name)
+		}
+		branch = full
+	}
+	short := strings.TrimPrefix(branch, "refs/heads/")
+	remoteName := r.configValue("branch." + short + ".remote")
+	merge := r.configValue("branch." + short + ".merge")
+	if remoteName == "" || merge == "" {
+		return "", fmt.Errorf("no upstream configured for branch '%s'", short)
+	}
+	if remoteName == "." {
+		return merge, nil
+	}
+	remote, err := r.Remote(remoteName)
+	if err != nil {
+		return "", err
+	}
+	tracking := trackingRef(remote.Fetch, merge)
+	if tracking == "" {
+		return "", fmt.Errorf("upstream branch '%s' not stored as a remote-tracking branch", merge)
+	}
+	return tracking, nil
+}
+
+// SymbolicFullName returns the full ref name a revision refers to, such
+// as refs/heads/main for HEAD or refs/remotes/origin/main for main@{u}.
+// It returns "" for revisions that are not refs, and HEAD when HEAD is
+// detached.
+func (r *Repository) SymbolicFullName(revision string) (string, error) {
+	if name, selector, found := strings.Cut(revision, "@{"); found {
+		switch strings.ToLower(strings.TrimSuffix(selector, "}")) {
+		case "u", "upstream":
+			return r.upstreamRef(name)
+		}
+		return "", nil
+	}
+	if revision == "@" {
+		revision = "HEAD"
+	}
+	if name, err := r.ExpandRef(revision); err == nil {
+		return r.ResolveRefName(name)
+	}
+	return "", nil
+}
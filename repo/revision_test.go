@@ -0,0 +1,123 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestResolveRevision(t *testing.T) {
+	r := initTestRepo(t)
+	first := commitFile(t, r, "a", "1\n")
+	second := commitFile(t, r, "d/b", "2\n")
+	if err := r.WriteRef("refs/heads/side", first, "branch"); err != nil {
+		t.Fatal(err)
+	}
+	// a merge of master and side, written by hand
+	tree, err := r.ResolveRevision("HEAD^{tree}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	merge, err := r.WriteObject("commit", []byte(fmt.Sprintf(
+		"tree %s\nparent %s\nparent %s\nauthor A <a@example.com> 1 +0000\ncommitter A <a@example.com> 1 +0000\n\nmerge\n",
+		tree, second, first)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.WriteRef("refs/heads/merged", merge, "merge"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.CreateTag(TagOptions{Name: "v1", Target: first, Annotated: true, Message: "v1"}); err != nil {
+		t.Fatal(err)
+	}
+	blob, err := r.WriteObject("blob", []byte("2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := r.ConfigFile(ScopeLocal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Set("branch.master.remote", ".")
+	config.Set("branch.master.merge", "refs/heads/side")
+	if err := config.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	for revision, want := range map[string]string{
+		"HEAD":              second,
+		"@":                 second,
+		"master":            second,
+		"heads/master":      second,
+		"refs/heads/master": second,
+		second[:7]:          second,
+		"HEAD~":             first,
+		"HEAD~1":            first,
+		"HEAD^":             first,
+		"HEAD~0":            second,
+		"merged^2":          first,
+		"merged^1~1":        first,
+		"merged^0":          merge,
+		"v1^{}":             first,
+		"v1^{commit}":       first,
+		"HEAD^{tree}":       tree,
+		"HEAD:d/b":          blob,
+		"merged:d/b":        blob,
+		":d/b":              blob,
+		":0:d/b":            blob,
+		"master@{1}":        first,
+		"@{0}":              second,
+		"@{u}":              first,
+		"master@{upstream}": first,
+	} {
+		if got, err := r.ResolveRevision(revision); err != nil || got != want {
+			t.Errorf("%s is %s, %v, want %s", revision, got, err, want)
+		}
+	}
+
+	for _, revision := range []string{"missing", "HEAD~2", "HEAD^3", "merged^3", "HEAD:missing", ":missing", "master@{5}", "v1^{blob}", "HEAD^{tree", "side@{u}"} {
+		if got, err := r.ResolveRevision(revision); err == nil {
+			t.Errorf("%s resolved to %s", revision, got)
+		}
+	}
+	if _, err := r.ResolveRevision("missing"); !errors.Is(err, ErrUnknownRevision) {
+		t.Errorf("an unknown revision returned %v", err)
+	}
+}
+
+func TestSymbolicFullName(t *testing.T) {
+	r := initTestRepo(t)
+	head := commitFile(t, r, "a", "1\n")
+	if err := r.AddRemote("origin", "/srv/origin.git"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.WriteRef("refs/remotes/origin/master", head, "fetch"); err != nil {
+		t.Fatal(err)
+	}
+	config, err := r.ConfigFile(ScopeLocal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Set("branch.master.remote", "origin")
+	config.Set("branch.master.merge", "refs/heads/master")
+	if err := config.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	for revision, want := range map[string]string{
+		"HEAD":          "refs/heads/master",
+		"master":        "refs/heads/master",
+		"@{u}":          "refs/remotes/origin/master",
+		"origin/master": "refs/remotes/origin/master",
+		head:            "",
+	} {
+		if got, err := r.SymbolicFullName(revision); err != nil || got != want {
+			t.Errorf("%s is %q, %v, want %q", revision, got, err, want)
+		}
+	}
+
+	abbreviated, err := r.AbbreviateHash(head, 7)
+	if err != nil || len(abbreviated) != 7 || abbreviated != head[:7] {
+		t.Errorf("%s is abbreviated to %s, %v", head, abbreviated, err)
+	}
+}
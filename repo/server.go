@@ -0,0 +1,552 @@
+package repo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// advertisedRef is a ref as the server side of the protocol shows it.
+type advertisedRef struct {
+	Ref
+	// peeled is the object an annotated tag points to, or "".
+	peeled string
+	// symref is the target of a symbolic ref such as HEAD, or "".
+	symref string
+}
+
+// AdvertiseRefs writes what service, git-upload-pack or git-receive-pack,
+// sends before it reads a request: the refs with their capabilities, or for
+// upload-pack in protocol v2 the capability advertisement.
+func (r *Repository) AdvertiseRefs(w io.Writer, service string, version int) error {
+	pkt := newPktWriter(w)
+	var capabilities []string
+	switch service {
+	case "git-upload-pack":
+		if version == 2 {
+			for _, line := range []string{"version 2", "agent=" + agent, "ls-refs", "fetch=include-tag", "object-format=sha1"} {
+				pkt.WriteString(line + "\n")
+			}
+			return pkt.Flush()
+		}
+		capabilities = []string{"side-band-64k", "side-band", "ofs-delta", "include-tag", "no-progress"}
+	case "git-receive-pack":
+		capabilities = []string{"report-status", "report-status-v2", "delete-refs", "side-band-64k", "quiet", "ofs-delta"}
+	default:
+		return fmt.Errorf("unknown service '%s'", service)
+	}
+
+	refs, err := r.advertisedRefs(service == "git-upload-pack")
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		if ref.symref != "" {
+			capabilities = append(capabilities, "symref="+ref.Name+":"+ref.symref)
+		}
+	}
+	capabilities = append(capabilities, "agent="+agent, "object-format=sha1")
+
+	if len(refs) == 0 {
+		pkt.WriteString(zeroHash + " capabilities^{}\x00" + strings.Join(capabilities, " ") + "\n")
+	}
+	for i, ref := range refs {
+		line := ref.Hash + " " + ref.Name
+		if i == 0 {
+			line += "\x00" + strings.Join(capabilities, " ")
+		}
+		pkt.WriteString(line + "\n")
+		if ref.peeled != "" {
+			pkt.WriteString(ref.peeled + " " + ref.Name + "^{}\n")
+		}
+	}
+	return pkt.Flush()
+}
+
+// advertisedRefs returns the refs of the repository, preceded by HEAD if
+// withHead is set and HEAD points to a commit.
+func (r *Repository) advertisedRefs(withHead bool) ([]advertisedRef, error) {
+	refs := []advertisedRef{}
+	if withHead {
+		if hash, err := r.ReadRef("HEAD"); err == nil {
+			head, err := r.HeadBranch()
+			if err != nil {
+				return nil, err
+			}
+			refs = append(refs, advertisedRef{Ref: Ref{Name: "HEAD", Hash: hash}, symref: head})
+		}
+	}
+	all, err := r.ListRefs("")
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range all {
+		advertised := advertisedRef{Ref: ref}
+		if strings.HasPrefix(ref.Name, "refs/tags/") {
+			peeled, isTag, err := r.peel(ref.Hash)
+			if err != nil {
+				return nil, err
+			}
+			if isTag {
+				advertised.peeled = peeled
+			}
+		}
+		refs = append(refs, advertised)
+	}
+	return refs, nil
+}
+
+// UploadPack answers one upload-pack request read from req, the server
+// side of fetch and clone. Requests are stateless: every negotiation must
+// end with "done".
+func (r *Repository) UploadPack(req io.Reader, w io.Writer, version int) error {
+	pkt := newPktReader(req)
+	out := newPktWriter(w)
+	if version == 2 {
+		return r.serveV2(pkt, out)
+	}
+
+	wants, capabilities := []string{}, []string{}
+	for {
+		kind, payload, err := pkt.Read()
+		if err == io.EOF && len(wants) == 0 {
+			// the client only wanted the advertisement
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid upload-pack request: %w", err)
+		}
+		if kind != pktData {
+			break
+		}
+		fields := strings.Fields(string(payload))
+		if len(fields) < 2 || fields[0] != "want" {
+			return fmt.Errorf("upload-pack: unsupported request line %q", strings.TrimSpace(string(payload)))
+		}
+		wants = append(wants, fields[1])
+		if len(wants) == 1 {
+			capabilities = fields[2:]
+		}
+	}
+	if len(wants) == 0 {
+		return nil
+	}
+
+	common, done := []string{}, false
+	for !done {
+		kind, payload, err := pkt.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid upload-pack request: %w", err)
+		}
+		line := strings.TrimSuffix(string(payload), "\n")
+		switch {
+		case kind == pktFlush:
+			// the end of a negotiation round: answer it and let the client
+			// come back with "done"
+			if len(common) > 0 {
+				out.WriteString("ACK " + common[0] + "\n")
+			} else {
+				out.WriteString("NAK\n")
+			}
+			return out.err
+		case line == "done":
+			done = true
+		case strings.HasPrefix(line, "have "):
+			if have := strings.TrimPrefix(line, "have "); r.HasObject(have) {
+				common = append(common, have)
+			}
+		default:
+			return fmt.Errorf("upload-pack: unsupported request line %q", line)
+		}
+	}
+	if len(common) > 0 {
+		out.WriteString("ACK " + common[0] + "\n")
+	} else {
+		out.WriteString("NAK\n")
+	}
+
+	pack, err := r.packForFetch(wants, common, hasCapability(capabilities, "include-tag"))
+	if err != nil {
+		return err
+	}
+	switch {
+	case hasCapability(capabilities, "side-band-64k"):
+		return writeSideband(out, pack, maxPktPayload-1)
+	case hasCapability(capabilities, "side-band"):
+		return writeSideband(out, pack, 999)
+	default:
+		if out.err != nil {
+			return out.err
+		}
+		_, err := w.Write(pack)
+		return err
+	}
+}
+
+// serveV2 runs protocol v2 commands until the end of req.
+func (r *Repository) serveV2(pkt *pktReader, out *pktWriter) error {
+	for {
+		kind, payload, err := pkt.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid upload-pack request: %w", err)
+		}
+		if kind != pktData {
+			continue
+		}
+		command, found := strings.CutPrefix(strings.TrimSuffix(string(payload), "\n"), "command=")
+		if !found {
+			return fmt.Errorf("upload-pack: expected a command, got %q", payload)
+		}
+
+		// capabilities come before the delimiter and arguments after it
+		args, inArgs := []string{}, false
+		for {
+			kind, payload, err := pkt.Read()
+			if err != nil {
+				return fmt.Errorf("invalid upload-pack request: %w", err)
+			}
+			if kind == pktFlush {
+				break
+			}
+			if kind == pktDelim {
+				inArgs = true
+				continue
+			}
+			if inArgs {
+				args = append(args, strings.TrimSuffix(string(payload), "\n"))
+			}
+		}
+
+		switch command {
+		case "ls-refs":
+			err = r.serveLsRefs(args, out)
+		case "fetch":
+			err = r.serveFetch(args, out)
+		default:
+			err = fmt.Errorf("upload-pack: unknown command '%s'", command)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (r *Repository) serveLsRefs(args []string, out *pktWriter) error {
+	peel, symrefs, prefixes := false, false, []string{}
+	for _, arg := range args {
+		switch {
+		case arg == "peel":
+			peel = true
+		case arg == "symrefs":
+			symrefs = true
+		case strings.HasPrefix(arg, "ref-prefix "):
+			prefixes = append(prefixes, strings.TrimPrefix(arg, "ref-prefix "))
+		}
+	}
+
+	refs, err := r.advertisedRefs(true)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		matched := len(prefixes) == 0
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(ref.Name, prefix) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		line := ref.Hash + " " + ref.Name
+		if symrefs && ref.symref != "" {
+			line += " symref-target:" + ref.symref
+		}
+		if peel && ref.peeled != "" {
+			line += " peeled:" + ref.peeled
+		}
+		out.WriteString(line + "\n")
+	}
+	return out.Flush()
+}
+
+func (r *Repository) serveFetch(args []string, out *pktWriter) error {
+	wants, common := []string{}, []string{}
+	done, includeTag := false, false
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "want "):
+			wants = append(wants, strings.TrimPrefix(arg, "want "))
+		case strings.HasPrefix(arg, "have "):
+			if have := strings.TrimPrefix(arg, "have "); r.HasObject(have) {
+				common = append(common, have)
+			}
+		case arg == "done":
+			done = true
+		case arg == "include-tag":
+			includeTag = true
+		case arg == "ofs-delta", arg == "thin-pack", arg == "no-progress":
+			// our packs never contain deltas or progress
+		default:
+			return fmt.Errorf("upload-pack: unsupported fetch argument '%s'", arg)
+		}
+	}
+
+	if !done {
+		out.WriteString("acknowledgments\n")
+		for _, have := range common {
+			out.WriteString("ACK " + have + "\n")
+		}
+		if len(common) == 0 {
+			out.WriteString("NAK\n")
+		}
+		out.WriteString("ready\n")
+		out.Delim()
+	}
+	pack, err := r.packForFetch(wants, common, includeTag)
+	if err != nil {
+		return err
+	}
+	out.WriteString("packfile\n")
+	return writeSideband(out, pack, maxPktPayload-1)
+}
+
+// packForFetch builds a pack of the objects reachable from wants but not
+// from common. With includeTag, annotated tags pointing into the pack are
+// added too.
+func (r *Repository) packForFetch(wants, common []string, includeTag bool) ([]byte, error) {
+	if err := r.checkWants(wants); err != nil {
+		return nil, err
+	}
+	objects, err := r.reachableObjects(wants, common)
+	if err != nil {
+		return nil, err
+	}
+
+	if includeTag {
+		included := map[string]bool{}
+		for _, object := range objects {
+			included[object] = true
+		}
+		tags, err := r.advertisedRefs(false)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range tags {
+			if tag.peeled == "" || !included[tag.peeled] || included[tag.Hash] {
+				continue
+			}
+			tagObjects, err := r.reachableObjects([]string{tag.Hash}, objects)
+			if err != nil {
+				return nil, err
+			}
+			for _, object := range tagObjects {
+				included[object] = true
+			}
+			objects = append(objects, tagObjects...)
+		}
+	}
+	return r.createPack(objects)
+}
+
+// checkWants refuses wants that are neither advertised ref tips nor
+// reachable from them. Anything else may be an object that was never
+// published or has since been dropped from history, which must not leak
+// to whoever knows its hash.
+func (r *Repository) checkWants(wants []string) error {
+	refs, err := r.advertisedRefs(true)
+	if err != nil {
+		return err
+	}
+	tips := map[string]bool{}
+	for _, ref := range refs {
+		tips[ref.Hash] = true
+	}
+	others := []string{}
+	for _, want := range wants {
+		if !tips[want] {
+			others = append(others, want)
+		}
+	}
+	if len(others) == 0 {
+		return nil
+	}
+
+	tipList := make([]string, 0, len(tips))
+	for tip := range tips {
+		tipList = append(tipList, tip)
+	}
+	objects, err := r.reachableObjects(tipList, nil)
+	if err != nil {
+		return err
+	}
+	reachable := make(map[string]bool, len(objects))
+	for _, object := range objects {
+		reachable[object] = true
+	}
+	for _, want := range others {
+		if !reachable[want] {
+			return fmt.Errorf("upload-pack: not our ref %s", want)
+		}
+	}
+	return nil
+}
+
+// writeSideband sends data on band 1 in packets of at most size bytes,
+// followed by a flush.
+func writeSideband(out *pktWriter, data []byte, size int) error {
+	for len(data) > 0 {
+		n := min(size, len(data))
+		out.Write(append([]byte{1}, data[:n]...))
+		data = data[n:]
+	}
+	return out.Flush()
+}
+
+// receiveCommand is one ref update requested by a client of receive-pack.
+type receiveCommand struct {
+	old, new, ref string
+}
+
+// ReceivePack applies one receive-pack request read from req, the server
+// side of push: it stores the pack, updates the refs and writes the
+// report-status.
+func (r *Repository) ReceivePack(req io.Reader, w io.Writer) error {
+	reader := bufio.NewReader(req)
+	pkt := newPktReader(reader)
+
+	commands, capabilities := []receiveCommand{}, []string{}
+	for {
+		kind, payload, err := pkt.Read()
+		if err == io.EOF && len(commands) == 0 {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid receive-pack request: %w", err)
+		}
+		if kind != pktData {
+			break
+		}
+		line, caps, hasCaps := strings.Cut(strings.TrimSuffix(string(payload), "\n"), "\x00")
+		if hasCaps {
+			capabilities = strings.Fields(caps)
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 || !isHash(fields[0]) || !isHash(fields[1]) {
+			return fmt.Errorf("receive-pack: invalid command %q", line)
+		}
+		commands = append(commands, receiveCommand{old: fields[0], new: fields[1], ref: fields[2]})
+	}
+	if len(commands) == 0 {
+		return nil
+	}
+
+	unpack := "ok"
+	pack, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	if len(pack) > 0 {
+		if err := r.storePack(pack, "receive.unpackLimit"); err != nil {
+			unpack = err.Error()
+		}
+	}
+
+	var report bytes.Buffer
+	reportPkt := newPktWriter(&report)
+	reportPkt.WriteString("unpack " + unpack + "\n")
+	for _, command := range commands {
+		reason := "unpacker error"
+		if unpack == "ok" {
+			if reason, err = r.receiveUpdate(command); err != nil {
+				return err
+			}
+		}
+		if reason == "" {
+			reportPkt.WriteString("ok " + command.ref + "\n")
+		} else {
+			reportPkt.WriteString("ng " + command.ref + " " + reason + "\n")
+		}
+	}
+	if err := reportPkt.Flush(); err != nil {
+		return err
+	}
+
+	if hasCapability(capabilities, "side-band-64k") {
+		return writeSideband(newPktWriter(w), report.Bytes(), maxPktPayload-1)
+	}
+	_, err = w.Write(report.Bytes())
+	return err
+}
+
+// receiveUpdate applies a pushed ref update and returns why it was refused,
+// or "" if it was applied.
+func (r *Repository) receiveUpdate(command receiveCommand) (string, error) {
+	if !strings.HasPrefix(command.ref, "refs/") || !ValidRefName(command.ref) {
+		return "funny refname", nil
+	}
+	current, err := r.ReadRef(command.ref)
+	if errors.Is(err, ErrRefNotFound) {
+		current = zeroHash
+	} else if err != nil {
+		return "", err
+	}
+	if current != command.old {
+		return "failed to lock", nil
+	}
+
+	config, err := r.Config()
+	if err != nil {
+		return "", err
+	}
+	head, err := r.HeadBranch()
+	if err != nil {
+		return "", err
+	}
+	checkedOut := !r.IsBare() && command.ref == head
+
+	if command.new == zeroHash {
+		if config.GetBool("receive.denyDeletes", false) {
+			return "deletion prohibited", nil
+		}
+		if checkedOut {
+			return "deletion of the current branch prohibited", nil
+		}
+		return r.applyReceivedUpdate(command)
+	}
+
+	if !r.HasObject(command.new) {
+		return "missing necessary objects", nil
+	}
+	if checkedOut {
+		switch value, _ := config.Get("receive.denyCurrentBranch"); value {
+		case "ignore", "warn", "false":
+		default:
+			return "branch is currently checked out", nil
+		}
+	}
+	if current != zeroHash && config.GetBool("receive.denyNonFastForwards", false) && !r.fastForward(current, command.new) {
+		return "non-fast-forward", nil
+	}
+	return r.applyReceivedUpdate(command)
+}
+
+// applyReceivedUpdate moves the ref of command, unless it changed since it
+// was advertised.
+func (r *Repository) applyReceivedUpdate(command receiveCommand) (string, error) {
+	err := r.UpdateRef(command.ref, command.new, command.old, "push")
+	if errors.Is(err, ErrRefMismatch) || errors.Is(err, ErrLocked) {
+		return "failed to lock", nil
+	}
+	return "", err
+}
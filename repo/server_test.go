@@ -0,0 +1,81 @@
+package repo
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// uploadPackRequest is a protocol v0 request for wants, ending with done.
+func uploadPackRequest(wants ...string) io.Reader {
+	var req bytes.Buffer
+	pkt := newPktWriter(&req)
+	for _, want := range wants {
+		pkt.WriteString("want " + want + "\n")
+	}
+	pkt.Flush()
+	pkt.WriteString("done\n")
+	return &req
+}
+
+// uploadPackV2Request is a protocol v2 fetch command for wants.
+func uploadPackV2Request(wants ...string) io.Reader {
+	var req bytes.Buffer
+	pkt := newPktWriter(&req)
+	pkt.WriteString("command=fetch\n")
+	pkt.Delim()
+	for _, want := range wants {
+		pkt.WriteString("want " + want + "\n")
+	}
+	pkt.WriteString("done\n")
+	pkt.Flush()
+	return &req
+}
+
+func TestUploadPackWants(t *testing.T) {
+	r := initTestRepo(t)
+	first := commitFile(t, r, "a", "1\n")
+	second := commitFile(t, r, "a", "2\n")
+	if err := r.WriteRef("refs/heads/gone", second, "branch"); err != nil {
+		t.Fatal(err)
+	}
+	dropped := commitFile(t, r, "secret", "password\n")
+	if err := r.UpdateRef("HEAD", second, dropped, "drop"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.UpdateRef("refs/heads/gone", ZeroHash, second, "delete"); err != nil {
+		t.Fatal(err)
+	}
+	secret, err := r.ResolveRevision(dropped + ":secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"tip", second, true},
+		{"reachable commit", first, true},
+		{"unreachable commit", dropped, false},
+		{"unreachable blob", secret, false},
+		{"missing object", strings.Repeat("1", 40), false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			for version, req := range map[int]io.Reader{
+				0: uploadPackRequest(test.want),
+				2: uploadPackV2Request(test.want),
+			} {
+				err := r.UploadPack(req, io.Discard, version)
+				if test.ok && err != nil {
+					t.Errorf("protocol v%d: UploadPack refused %s: %v", version, test.want, err)
+				}
+				if !test.ok && (err == nil || !strings.Contains(err.Error(), "not our ref")) {
+					t.Errorf("protocol v%d: UploadPack returned %v, want a not our ref error", version, err)
+				}
+			}
+		})
+	}
+}
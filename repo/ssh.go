@@ -0,0 +1,161 @@
+package repo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// isSSHURL reports whether remote is an ssh:// or scp-like URL.
+func isSSHURL(remote string) bool {
+	for _, scheme := range []string{"ssh://", "git+ssh://", "ssh+git://"} {
+		if strings.HasPrefix(remote, scheme) {
+			return true
+		}
+	}
+	return isSCPURL(remote)
+}
+
+// isSCPURL reports whether remote has the scp-like [user@]host:path form,
+// which has a colon before any slash.
+func isSCPURL(remote string) bool {
+	if strings.Contains(remote, "://") {
+		return false
+	}
+	colon := strings.Index(remote, ":")
+	slash := strings.Index(remote, "/")
+	return colon > 0 && (slash == -1 || colon < slash)
+}
+
+// sshTransport runs git-upload-pack and git-receive-pack on a remote host
+// through ssh. Every exchange starts a new connection, which makes ssh as
+// stateless as smart HTTP.
+type sshTransport struct {
+	// host is [user@]host.
+	host string
+	port string
+	path string
+	// command is the shell command from GIT_SSH_COMMAND or
+	// core.sshCommand, and program the ssh binary used without it.
+	command string
+	program string
+}
+
+// openSSHTransport parses an ssh URL and picks the ssh command to run:
+// GIT_SSH_COMMAND, core.sshCommand, GIT_SSH or ssh, in that order.
+func (r *Repository) openSSHTransport(remote string) (*sshTransport, error) {
+	t := &sshTransport{program: "ssh"}
+	if isSCPURL(remote) {
+		t.host, t.path, _ = strings.Cut(remote, ":")
+	} else {
+		_, rest, _ := strings.Cut(remote, "://")
+		parsed, err := url.Parse("ssh://" + rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ssh URL '%s': %w", remote, err)
+		}
+		t.host = parsed.Hostname()
+		if parsed.User != nil {
+			t.host = parsed.User.Username() + "@" + t.host
+		}
+		t.port = parsed.Port()
+		t.path = parsed.Path
+		// ssh://host/~user/repo is relative to a home directory
+		if strings.HasPrefix(t.path, "/~") {
+			t.path = t.path[1:]
+		}
+	}
+	if t.host == "" || t.path == "" {
+		return nil, fmt.Errorf("invalid ssh URL '%s'", remote)
+	}
+	if strings.HasPrefix(t.host, "-") {
+		return nil, fmt.Errorf("strange hostname '%s' blocked", t.host)
+	}
+
+	t.command = os.Getenv("GIT_SSH_COMMAND")
+	if t.command == "" {
+		t.command = r.configValue("core.sshCommand")
+	}
+	if program := os.Getenv("GIT_SSH"); program != "" {
+		t.program = program
+	}
+	return t, nil
+}
+
+func (t *sshTransport) advertise(service, protocol string) ([]byte, error) {
+	// a lone flush tells the service we only wanted its advertisement
+	advertisement, _, err := t.run(service, protocol, []byte("0000"))
+	return advertisement, err
+}
+
+func (t *sshTransport) request(service, protocol string, data []byte) ([]byte, error) {
+	_, response, err := t.run(service, protocol, data)
+	return response, err
+}
+
+// run starts service on the remote, sends it data and returns the
+// advertisement it sent first and the response that followed.
+func (t *sshTransport) run(service, protocol string, data []byte) ([]byte, []byte, error) {
+	args := []string{}
+	if protocol != "" {
+		args = append(args, "-o", "SendEnv=GIT_PROTOCOL")
+	}
+	if t.port != "" {
+		args = append(args, "-p", t.port)
+	}
+	args = append(args, t.host, service+" "+shellQuote(t.path))
+
+	var cmd *exec.Cmd
+	if t.command != "" {
+		cmd = exec.Command("sh", "-c", t.command+` "$@"`, t.command)
+		cmd.Args = append(cmd.Args, args...)
+	} else {
+		cmd = exec.Command(t.program, args...)
+	}
+	cmd.Env = os.Environ()
+	if protocol != "" {
+		cmd.Env = append(cmd.Env, "GIT_PROTOCOL="+protocol)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Stdin = bytes.NewReader(data)
+
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return nil, nil, fmt.Errorf("could not read from remote repository %s: %s", t.host, message)
+	}
+	return splitAdvertisement(stdout.Bytes())
+}
+
+// splitAdvertisement splits the output of a service after the flush that
+// ends its advertisement.
+func splitAdvertisement(output []byte) ([]byte, []byte, error) {
+	pkt := newPktReader(bytes.NewReader(output))
+	consumed := 0
+	for {
+		kind, payload, err := pkt.Read()
+		if err == io.EOF {
+			return nil, nil, errors.New("remote closed the connection before its advertisement")
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid advertisement: %w", err)
+		}
+		consumed += 4 + len(payload)
+		if kind == pktFlush {
+			return output[:consumed], output[consumed:], nil
+		}
+	}
+}
+
+// shellQuote quotes s for the remote shell ssh runs commands with.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,120 @@
+package repo
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// buildGogit builds the gogit command, which serves the repositories at
+// the other end of the fake ssh connections.
+func buildGogit(t *testing.T) string {
+	t.Helper()
+	goBinary, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go is needed to build gogit")
+	}
+	binary := filepath.Join(t.TempDir(), "gogit")
+	cmd := exec.Command(goBinary, "build", "-buildvcs=false", "-o", binary, ".")
+	cmd.Dir = ".."
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("building gogit: %v\n%s", err, output)
+	}
+	return binary
+}
+
+// setupFakeSSH makes ssh URLs run testdata/fake-ssh instead of ssh, and
+// returns the file it logs its arguments to.
+func setupFakeSSH(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is needed to run the fake ssh")
+	}
+	gogit := buildGogit(t)
+	script, err := filepath.Abs(filepath.Join("testdata", "fake-ssh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := filepath.Join(t.TempDir(), "ssh.log")
+	t.Setenv("GIT_SSH_COMMAND", script)
+	t.Setenv("GOGIT", gogit)
+	t.Setenv("FAKE_SSH_LOG", log)
+	return log
+}
+
+// readSSHLog returns the argument lines the fake ssh logged and empties
+// the log.
+func readSSHLog(t *testing.T, log string) []string {
+	t.Helper()
+	data, err := os.ReadFile(log)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(log); err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestSSHCloneFetchPush(t *testing.T) {
+	// before setupTestEnv moves HOME, which holds the go build cache
+	log := setupFakeSSH(t)
+	remote, head := initRemoteRepo(t)
+	branch := "refs/heads/" + DefaultBranch
+	path := remote.GitDir()
+
+	for _, test := range []struct {
+		url string
+		// args is how the fake ssh must be called to reach the remote
+		args string
+	}{
+		{"ssh://git@example.com:2222" + path, "-p 2222 git@example.com git-upload-pack '" + path + "'"},
+		{"git@example.com:" + path, "git@example.com git-upload-pack '" + path + "'"},
+		{"ssh://example.com" + path, "example.com git-upload-pack '" + path + "'"},
+	} {
+		t.Run(test.url, func(t *testing.T) {
+			clone := cloneTestRepo(t, test.url)
+			assertRef(t, clone, "HEAD", head)
+			data, err := os.ReadFile(filepath.Join(clone.WorkTree(), "README"))
+			if err != nil || string(data) != "hello\n" {
+				t.Errorf("README of the clone has %q, %v", data, err)
+			}
+			for _, args := range readSSHLog(t, log) {
+				if !strings.HasSuffix(args, test.args) {
+					t.Errorf("ssh was run with %q, want it to end with %q", args, test.args)
+				}
+			}
+		})
+	}
+
+	first := cloneTestRepo(t, "git@example.com:"+path)
+	second := cloneTestRepo(t, "ssh://example.com"+path)
+	pushed := commitFile(t, first, "a", "a\n")
+	readSSHLog(t, log)
+	if _, err := first.Push(PushOptions{}); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	assertRef(t, remote, branch, pushed)
+	assertRef(t, first, "refs/remotes/origin/"+DefaultBranch, pushed)
+	for _, args := range readSSHLog(t, log) {
+		if !strings.HasSuffix(args, "git@example.com git-receive-pack '"+path+"'") {
+			t.Errorf("push ran ssh with %q", args)
+		}
+	}
+
+	if _, err := second.Fetch(FetchOptions{}); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	assertRef(t, second, "refs/remotes/origin/"+DefaultBranch, pushed)
+
+	// refusals of the remote come back through ssh too
+	commitFile(t, second, "b", "b\n")
+	setConfig(t, remote, "receive.denyNonFastForwards", "true")
+	result, err := second.Push(PushOptions{Force: true})
+	if err == nil || result == nil || result.Updates[0].Reason != "non-fast-forward" {
+		t.Errorf("forced Push returned %+v, %v, want a non-fast-forward refusal", result, err)
+	}
+	assertRef(t, remote, branch, pushed)
+}
@@ -0,0 +1,105 @@
+package repo
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Status lists how the working tree differs from the index.
+type Status struct {
+	Modified  []string
+	Untracked []string
+	Deleted   []string
+}
+
+// Status compares the working tree against the index.
+func (r *Repository) Status() (Status, error) {
+	if r.IsBare() {
+		return Status{}, ErrBareRepository
+	}
+	dirIndexes, err := r.getDirIndexes()
+	if err != nil {
+		return Status{}, err
+	}
+	indexes, err := r.ReadIndex()
+	if err != nil {
+		return Status{}, err
+	}
+	modified, untracked, deleted := compareIndexes(dirIndexes, indexes)
+	return Status{Modified: modified, Untracked: untracked, Deleted: deleted}, nil
+}
+
+func (r *Repository) getDirIndexes() ([]IndexEntry, error) {
+	var dirIndexes []IndexEntry
+	err := filepath.Walk(r.workTree, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Name() == ".git" && path != r.workTree {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open file %s: %w", path, err)
+		}
+		defer file.Close()
+
+		relPath, err := filepath.Rel(r.workTree, path)
+		if err != nil {
+			return err
+		}
+		hash := HashObject(file, "blob", int(info.Size()))
+		sha1, _ := hex.DecodeString(hash)
+		dirIndexes = append(dirIndexes, IndexEntry{Path: filepath.ToSlash(relPath), Sha1: sha1})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dirIndexes, nil
+}
+
+func compareIndexes(dirIndexes, indexes []IndexEntry) ([]string, []string, []string) {
+	sort.Slice(dirIndexes, func(i, j int) bool { return dirIndexes[i].Path < dirIndexes[j].Path })
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i].Path < indexes[j].Path })
+
+	modified, untracked, deleted := []string{}, []string{}, []string{}
+	i, j := 0, 0
+	for i < len(dirIndexes) && j < len(indexes) {
+		if dirIndexes[i].Path == indexes[j].Path {
+			if hex.EncodeToString(dirIndexes[i].Sha1) != hex.EncodeToString(indexes[j].Sha1) {
+				modified = append(modified, dirIndexes[i].Path)
+			}
+			i++
+			j++
+		} else if dirIndexes[i].Path < indexes[j].Path {
+			untracked = append(untracked, dirIndexes[i].Path)
+			i++
+		} else {
+			deleted = append(deleted, indexes[j].Path)
+			j++
+		}
+	}
+
+	for i < len(dirIndexes) {
+		untracked = append(untracked, dirIndexes[i].Path)
+		i++
+	}
+
+	for j < len(indexes) {
+		deleted = append(deleted, indexes[j].Path)
+		j++
+	}
+
+	return modified, untracked, deleted
+}
@@ -0,0 +1,332 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TagObject is a parsed annotated tag object.
+type TagObject struct {
+	// Object is the tagged object and Type its type.
+	Object string
+	Type   string
+	// Tag is the name of the tag, without refs/tags/.
+	Tag     string
+	Tagger  string
+	Message string
+}
+
+// Bytes serializes the tag in the format git stores it.
+func (tag *TagObject) Bytes() []byte {
+	var contents strings.Builder
+	fmt.Fprintf(&contents, "object %s\n", tag.Object)
+	fmt.Fprintf(&contents, "type %s\n", tag.Type)
+	fmt.Fprintf(&contents, "tag %s\n", tag.Tag)
+	if tag.Tagger != "" {
+		fmt.Fprintf(&contents, "tagger %s\n", tag.Tagger)
+	}
+	contents.WriteString("\n")
+	contents.WriteString(tag.Message)
+	return []byte(contents.String())
+}
+
+// ReadTag reads and parses an annotated tag object.
+func (r *Repository) ReadTag(tag string) (*TagObject, error) {
+	objectType, contents, err := r.ReadObject(tag)
+	if err != nil {
+		return nil, err
+	}
+	if objectType != "tag" {
+		return nil, fmt.Errorf("invalid tag object type %v: %v", tag, objectType)
+	}
+	return parseTag(contents)
+}
+
+func parseTag(contents []byte) (*TagObject, error) {
+	header, message, _ := strings.Cut(string(contents), "\n\n")
+	tag := &TagObject{Message: message}
+	for _, line := range strings.Split(header, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "object":
+			tag.Object = value
+		case "type":
+			tag.Type = value
+		case "tag":
+			tag.Tag = value
+		case "tagger":
+			tag.Tagger = value
+		}
+	}
+	if tag.Object == "" {
+		return nil, fmt.Errorf("invalid tag object: missing object")
+	}
+	return tag, nil
+}
+
+// parseTagTarget returns the object and type an annotated tag points to.
+func parseTagTarget(contents []byte) (string, string, error) {
+	tag, err := parseTag(contents)
+	if err != nil {
+		return "", "", err
+	}
+	return tag.Object, tag.Type, nil
+}
+
+// peel follows annotated tags from object to the first object that is not
+// a tag. It reports false if object is not a tag.
+func (r *Repository) peel(object string) (string, bool, error) {
+	peeled := false
+	for {
+		objectType, contents, err := r.ReadObject(object)
+		if err != nil {
+			return "", false, err
+		}
+		if objectType != "tag" {
+			return object, peeled, nil
+		}
+		if object, _, err = parseTagTarget(contents); err != nil {
+			return "", false, err
+		}
+		peeled = true
+	}
+}
+
+// TagOptions describes a tag to create.
+type TagOptions struct {
+	Name string
+	// Target is the revision tagged. Empty means HEAD.
+	Target string
+	// Annotated creates a tag object holding Message and the tagger,
+	// instead of a ref pointing straight at the target.
+	Annotated bool
+	Message   string
+	// Force replaces an existing tag of the same name.
+	Force bool
+}
+
+// CreateTag creates refs/tags/<opts.Name> and returns what the tag replaced,
+// or "" for a new tag.
+func (r *Repository) CreateTag(opts TagOptions) (string, error) {
+	ref := "refs/tags/" + opts.Name
+	// a leading dash would read as an option on the command line
+	if strings.HasPrefix(opts.Name, "-") || !ValidRefName(ref) {
+		return "", fmt.Errorf("'%s' is not a valid tag name", opts.Name)
+	}
+	previous, err := r.ReadRef(ref)
+	if err == nil && !opts.Force {
+		return "", fmt.Errorf("tag '%s' already exists", opts.Name)
+	}
+	if err != nil && !errors.Is(err, ErrRefNotFound) {
+		return "", err
+	}
+
+	target := opts.Target
+	if target == "" {
+		target = "HEAD"
+	}
+	hash, err := r.ResolveRevision(target)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve '%s' as a valid ref", target)
+	}
+
+	if opts.Annotated {
+		objectType, _, err := r.ReadObject(hash)
+		if err != nil {
+			return "", err
+		}
+		tagger, err := r.signature(time.Now())
+		if err != nil {
+			return "", err
+		}
+		message := opts.Message
+		if message != "" && !strings.HasSuffix(message, "\n") {
+			message += "\n"
+		}
+		tag := &TagObject{Object: hash, Type: objectType, Tag: opts.Name, Tagger: tagger, Message: message}
+		if hash, err = r.WriteObject("tag", tag.Bytes()); err != nil {
+			return "", err
+		}
+	}
+	if err := r.WriteRef(ref, hash, "tag: tagging "+hash); err != nil {
+		return "", err
+	}
+	if previous == hash {
+		return "", nil
+	}
+	return previous, nil
+}
+
+// DeleteTag removes refs/tags/<name> and returns what it pointed to.
+func (r *Repository) DeleteTag(name string) (string, error) {
+	ref := "refs/tags/" + name
+	hash, err := r.ReadRef(ref)
+	if errors.Is(err, ErrRefNotFound) {
+		return "", fmt.Errorf("tag '%s' not found", name)
+	}
+	if err != nil {
+		return "", err
+	}
+	return hash, r.DeleteRef(ref)
+}
+
+// Tag is a tag as listed by ListTags.
+type Tag struct {
+	// Name is the tag name without refs/tags/.
+	Name string
+	// Hash is what the ref points to, the tag object for annotated tags.
+	Hash string
+	// Object is the parsed tag object, nil for lightweight tags.
+	Object *TagObject
+}
+
+// ListTags returns the tags whose names match one of the glob patterns,
+// or all tags if there are none, ordered by sortKey. Keys are refname,
+// version:refname (or v:refname), creatordate, taggerdate and objectname,
+// reversed with a leading "-". An empty key uses tag.sort, then refname.
+func (r *Repository) ListTags(patterns []string, sortKey string) ([]Tag, error) {
+	if sortKey == "" {
+		sortKey = r.configValue("tag.sort")
+	}
+	matchers := []*regexp.Regexp{}
+	for _, pattern := range patterns {
+		matcher, err := globRegexp(pattern)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, matcher)
+	}
+
+	refs, err := r.ListRefs("refs/tags/")
+	if err != nil {
+		return nil, err
+	}
+	tags := []Tag{}
+	for _, ref := range refs {
+		tag := Tag{Name: strings.TrimPrefix(ref.Name, "refs/tags/"), Hash: ref.Hash}
+		matched := len(matchers) == 0
+		for _, matcher := range matchers {
+			matched = matched || matcher.MatchString(tag.Name)
+		}
+		if !matched {
+			continue
+		}
+		if objectType, contents, err := r.ReadObject(ref.Hash); err == nil && objectType == "tag" {
+			if tag.Object, err = parseTag(contents); err != nil {
+				return nil, err
+			}
+		}
+		tags = append(tags, tag)
+	}
+	return tags, r.sortTags(tags, sortKey)
+}
+
+// sortTags orders tags by key, see ListTags.
+func (r *Repository) sortTags(tags []Tag, key string) error {
+	key, reverse := strings.CutPrefix(key, "-")
+	var less func(a, b Tag) bool
+	switch key {
+	case "", "refname":
+		less = func(a, b Tag) bool { return a.Name < b.Name }
+	case "version:refname", "v:refname":
+		less = func(a, b Tag) bool { return versionLess(a.Name, b.Name) }
+	case "objectname":
+		less = func(a, b Tag) bool { return a.Hash < b.Hash }
+	case "creatordate", "taggerdate":
+		dates := map[string]int64{}
+		for _, tag := range tags {
+			switch {
+			case tag.Object != nil:
+				dates[tag.Name] = signatureTime(tag.Object.Tagger)
+			case key == "creatordate":
+				if commit, err := r.ReadCommit(tag.Hash); err == nil {
+					dates[tag.Name] = signatureTime(commit.Committer)
+				}
+			}
+		}
+		less = func(a, b Tag) bool { return dates[a.Name] < dates[b.Name] }
+	default:
+		return fmt.Errorf("unsupported sort key '%s'", key)
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		if reverse {
+			return less(tags[j], tags[i])
+		}
+		return less(tags[i], tags[j])
+	})
+	return nil
+}
+
+// versionLess compares names as versions: runs of digits compare by
+// value, so v1.10 sorts after v1.9.
+func versionLess(a, b string) bool {
+	for a != "" && b != "" {
+		digitsA := len(a) - len(strings.TrimLeft(a, "0123456789"))
+		digitsB := len(b) - len(strings.TrimLeft(b, "0123456789"))
+		if digitsA > 0 && digitsB > 0 {
+			numberA, _ := strconv.ParseUint(a[:digitsA], 10, 64)
+			numberB, _ := strconv.ParseUint(b[:digitsB], 10, 64)
+			if numberA != numberB {
+				return numberA < numberB
+			}
+			a, b = a[digitsA:], b[digitsB:]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+// signatureTime returns the timestamp of an author, committer or tagger
+// line, "name <email> timestamp timezone", or 0.
+func signatureTime(signature string) int64 {
+	fields := strings.Fields(signature[strings.LastIndex(signature, ">")+1:])
+	if len(fields) == 0 {
+		return 0
+	}
+	timestamp, _ := strconv.ParseInt(fields[0], 10, 64)
+	return timestamp
+}
+
+// globRegexp compiles a shell glob where * and ? also match slashes, as
+// tag and branch patterns do.
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end == -1 {
+				expr.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+	matcher, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+	}
+	return matcher, nil
+}
@@ -0,0 +1,139 @@
+package repo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCreateTag(t *testing.T) {
+	r := initTestRepo(t)
+	first := commitFile(t, r, "a", "1\n")
+	second := commitFile(t, r, "a", "2\n")
+
+	if previous, err := r.CreateTag(TagOptions{Name: "light", Target: first}); err != nil || previous != "" {
+		t.Fatalf("CreateTag: %q, %v", previous, err)
+	}
+	if hash, _ := r.ReadRef("refs/tags/light"); hash != first {
+		t.Errorf("light is at %s, want %s", hash, first)
+	}
+	if _, err := r.CreateTag(TagOptions{Name: "light"}); err == nil {
+		t.Error("an existing tag was replaced without force")
+	}
+	if previous, err := r.CreateTag(TagOptions{Name: "light", Force: true}); err != nil || previous != first {
+		t.Errorf("forced CreateTag: %q, %v", previous, err)
+	}
+	for _, name := range []string{"bad..name", "bad name", "-", "a.lock"} {
+		if _, err := r.CreateTag(TagOptions{Name: name}); err == nil {
+			t.Errorf("tag %q was created", name)
+		}
+	}
+
+	if _, err := r.CreateTag(TagOptions{Name: "v1.0", Annotated: true, Message: "release"}); err != nil {
+		t.Fatal(err)
+	}
+	hash, _ := r.ReadRef("refs/tags/v1.0")
+	tag, err := r.ReadTag(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.Object != second || tag.Type != "commit" || tag.Tag != "v1.0" || tag.Message != "release\n" ||
+		!strings.HasPrefix(tag.Tagger, "Test User <test@example.com> ") {
+		t.Errorf("tag object is %+v", tag)
+	}
+	if peeled, isTag, err := r.peel(hash); err != nil || !isTag || peeled != second {
+		t.Errorf("peel returned %s, %v, %v", peeled, isTag, err)
+	}
+	if resolved, err := r.ResolveRevision("v1.0^{commit}"); err != nil || resolved != second {
+		t.Errorf("v1.0^{commit} is %s, %v", resolved, err)
+	}
+
+	if deleted, err := r.DeleteTag("light"); err != nil || deleted != second {
+		t.Errorf("DeleteTag returned %s, %v", deleted, err)
+	}
+	if _, err := r.DeleteTag("light"); err == nil {
+		t.Error("a missing tag was deleted")
+	}
+}
+
+func TestListTags(t *testing.T) {
+	r := initTestRepo(t)
+	commitFile(t, r, "a", "1\n")
+	for _, name := range []string{"v1.10", "v1.9", "v1.2", "other"} {
+		if _, err := r.CreateTag(TagOptions{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names := func(tags []Tag) string {
+		list := []string{}
+		for _, tag := range tags {
+			list = append(list, tag.Name)
+		}
+		return strings.Join(list, " ")
+	}
+
+	for _, test := range []struct {
+		patterns []string
+		sort     string
+		want     string
+	}{
+		{nil, "", "other v1.10 v1.2 v1.9"},
+		{nil, "version:refname", "other v1.2 v1.9 v1.10"},
+		{nil, "-v:refname", "v1.10 v1.9 v1.2 other"},
+		{[]string{"v1.*"}, "v:refname", "v1.2 v1.9 v1.10"},
+		{[]string{"v1.?", "oth*"}, "", "other v1.2 v1.9"},
+	} {
+		tags, err := r.ListTags(test.patterns, test.sort)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := names(tags); got != test.want {
+			t.Errorf("ListTags(%v, %q) = %s, want %s", test.patterns, test.sort, got, test.want)
+		}
+	}
+	if _, err := r.ListTags(nil, "size"); err == nil {
+		t.Error("an unknown sort key was accepted")
+	}
+
+	config, err := r.ConfigFile(ScopeLocal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Set("tag.sort", "-refname")
+	if err := config.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if tags, err := r.ListTags(nil, ""); err != nil || names(tags) != "v1.9 v1.2 v1.10 other" {
+		t.Errorf("with tag.sort the tags are %s, %v", names(tags), err)
+	}
+}
+
+func TestPushFollowTags(t *testing.T) {
+	r := initTestRepo(t)
+	first := commitFile(t, r, "a", "1\n")
+	if _, err := r.CreateTag(TagOptions{Name: "annotated", Annotated: true, Message: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.CreateTag(TagOptions{Name: "light"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.CreateTag(TagOptions{Name: "pushed", Annotated: true, Message: "p"}); err != nil {
+		t.Fatal(err)
+	}
+	pushed, _ := r.ReadRef("refs/tags/pushed")
+	remoteRefs := map[string]string{"refs/tags/pushed": pushed}
+
+	updates, err := r.planPush(PushOptions{FollowTags: true}, remoteRefs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dsts := []string{}
+	for _, update := range updates {
+		dsts = append(dsts, update.Dst)
+	}
+	if strings.Join(dsts, " ") != "refs/heads/master refs/tags/annotated" {
+		t.Errorf("pushed refs are %v", dsts)
+	}
+	if updates[0].New != first {
+		t.Errorf("master is pushed as %s", updates[0].New)
+	}
+}
@@ -0,0 +1,165 @@
+package repo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// transport carries the git services of a remote repository. Every
+// exchange is stateless, the way smart HTTP works: the advertisement is
+// fetched on its own and each request gets one complete response.
+type transport interface {
+	// advertise returns what service, git-upload-pack or
+	// git-receive-pack, sends before reading a request.
+	advertise(service, protocol string) ([]byte, error)
+	// request sends a request to service and returns its response.
+	request(service, protocol string, data []byte) ([]byte, error)
+}
+
+// openTransport returns the transport for a remote URL. http and https
+// URLs use smart HTTP, ssh:// and scp-like [user@]host:path URLs run the
+// services through ssh, while file:// URLs and paths are served in-process
+// from the repository on disk.
+func (r *Repository) openTransport(remote, userName, password string) (transport, error) {
+	switch {
+	case isHTTPURL(remote):
+		credential, err := CredentialForURL(remote)
+		if err != nil {
+			return nil, err
+		}
+		if userName != "" {
+			credential.UserName = userName
+		}
+		if password != "" {
+			credential.Password = password
+		}
+		return &httpTransport{repo: r, url: remote, credential: credential}, nil
+	case isSSHURL(remote):
+		return r.openSSHTransport(remote)
+	case strings.HasPrefix(remote, "file://"):
+		return openLocalTransport(strings.TrimPrefix(remote, "file://"))
+	case isLocalURL(remote):
+		return openLocalTransport(remote)
+	default:
+		scheme, _, _ := strings.Cut(remote, "://")
+		return nil, fmt.Errorf("unsupported protocol '%s'", scheme)
+	}
+}
+
+// isHTTPURL reports whether remote is served over smart HTTP.
+func isHTTPURL(remote string) bool {
+	return strings.HasPrefix(remote, "http://") || strings.HasPrefix(remote, "https://")
+}
+
+// isLocalURL reports whether remote is a path rather than a URL.
+func isLocalURL(remote string) bool {
+	return !strings.Contains(remote, "://") && !isSCPURL(remote)
+}
+
+// httpTransport talks to a smart HTTP server. Credentials are only filled
+// in once the server asks for them with a 401.
+type httpTransport struct {
+	repo       *Repository
+	url        string
+	credential *Credential
+	// filled is set once the credential helpers or the user were asked.
+	filled   bool
+	approved bool
+}
+
+func (t *httpTransport) advertise(service, protocol string) ([]byte, error) {
+	return t.authenticated(func() ([]byte, error) {
+		return gitGetPack(t.url+"/info/refs?service="+service, t.credential.UserName, t.credential.Password, protocol)
+	})
+}
+
+func (t *httpTransport) request(service, protocol string, data []byte) ([]byte, error) {
+	return t.authenticated(func() ([]byte, error) {
+		return gitServiceRequest(t.url, service, t.credential.UserName, t.credential.Password, protocol, data)
+	})
+}
+
+// authenticated sends a request, filling the credential and sending it
+// again if the server answers 401. Credentials that work are approved to
+// the credential helpers and refused ones rejected, so helpers only keep
+// what is valid.
+func (t *httpTransport) authenticated(send func() ([]byte, error)) ([]byte, error) {
+	body, err := send()
+	if !errors.Is(err, errHTTPUnauthorized) {
+		if err == nil && t.filled && !t.approved {
+			if config, err := t.repo.Config(); err == nil {
+				ApproveCredential(config, t.credential)
+			}
+			t.approved = true
+		}
+		return body, err
+	}
+
+	config, err := t.repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	if !t.filled {
+		t.filled = true
+		if err := FillCredential(config, t.credential); err != nil {
+			return nil, err
+		}
+		return t.authenticated(send)
+	}
+	RejectCredential(config, t.credential)
+	return nil, fmt.Errorf("authentication failed for '%s'", t.url)
+}
+
+// localTransport serves a repository on disk with the server side of the
+// protocol, without spawning any process.
+type localTransport struct {
+	repo *Repository
+}
+
+// openLocalTransport opens the repository at path for serving.
+func openLocalTransport(path string) (*localTransport, error) {
+	r, err := OpenDir(path)
+	if err != nil {
+		return nil, err
+	}
+	return &localTransport{repo: r}, nil
+}
+
+func (t *localTransport) advertise(service, protocol string) ([]byte, error) {
+	var response bytes.Buffer
+	if err := t.repo.AdvertiseRefs(&response, service, ParseProtocolVersion(protocol)); err != nil {
+		return nil, err
+	}
+	return response.Bytes(), nil
+}
+
+func (t *localTransport) request(service, protocol string, data []byte) ([]byte, error) {
+	var response bytes.Buffer
+	var err error
+	switch service {
+	case "git-upload-pack":
+		err = t.repo.UploadPack(bytes.NewReader(data), &response, ParseProtocolVersion(protocol))
+	case "git-receive-pack":
+		err = t.repo.ReceivePack(bytes.NewReader(data), &response)
+	default:
+		err = fmt.Errorf("unknown service '%s'", service)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return response.Bytes(), nil
+}
+
+// ParseProtocolVersion returns the protocol version requested by a
+// Git-Protocol header or GIT_PROTOCOL value such as "version=2", or 0.
+func ParseProtocolVersion(protocol string) int {
+	version := 0
+	for _, parameter := range strings.Split(protocol, ":") {
+		if parameter == "version=2" {
+			version = 2
+		}
+	}
+	return version
+}
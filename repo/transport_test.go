@@ -0,0 +1,294 @@
+package repo
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setConfig sets key in the repository configuration of r.
+func setConfig(t *testing.T, r *Repository, key, value string) {
+	t.Helper()
+	config, err := r.ConfigFile(ScopeLocal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Set(key, value); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Save(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// cloneTestRepo clones url into a temporary directory.
+func cloneTestRepo(t *testing.T, url string) *Repository {
+	t.Helper()
+	r, err := Clone(CloneOptions{URL: url, Directory: filepath.Join(t.TempDir(), "clone")})
+	if err != nil {
+		t.Fatalf("Clone %s: %v", url, err)
+	}
+	return r
+}
+
+// initRemoteRepo creates a bare repository with one commit on the default
+// branch, pushed there from a repository with a working tree.
+func initRemoteRepo(t *testing.T) (*Repository, string) {
+	t.Helper()
+	source := initTestRepo(t)
+	head := commitFile(t, source, "README", "hello\n")
+	remote, err := InitBare(filepath.Join(t.TempDir(), "remote.git"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	branch := "refs/heads/" + DefaultBranch
+	if _, err := source.Push(PushOptions{Remote: remote.GitDir(), Refspecs: []string{branch}}); err != nil {
+		t.Fatalf("Push to %s: %v", remote.GitDir(), err)
+	}
+	return remote, head
+}
+
+// assertRef fails the test unless ref of r points to hash.
+func assertRef(t *testing.T, r *Repository, ref, hash string) {
+	t.Helper()
+	got, err := r.ReadRef(ref)
+	if err != nil {
+		t.Fatalf("reading %s: %v", ref, err)
+	}
+	if got != hash {
+		t.Errorf("%s is %s, want %s", ref, got, hash)
+	}
+}
+
+func TestLocalCloneFetchPush(t *testing.T) {
+	remote, head := initRemoteRepo(t)
+	branch := "refs/heads/" + DefaultBranch
+	assertRef(t, remote, branch, head)
+
+	for _, url := range []string{remote.GitDir(), "file://" + remote.GitDir()} {
+		t.Run(url, func(t *testing.T) {
+			clone := cloneTestRepo(t, url)
+			assertRef(t, clone, "HEAD", head)
+			assertRef(t, clone, "refs/remotes/origin/"+DefaultBranch, head)
+			data, err := os.ReadFile(filepath.Join(clone.WorkTree(), "README"))
+			if err != nil || string(data) != "hello\n" {
+				t.Errorf("README of the clone has %q, %v", data, err)
+			}
+		})
+	}
+
+	first := cloneTestRepo(t, remote.GitDir())
+	second := cloneTestRepo(t, "file://"+remote.GitDir())
+	pushed := commitFile(t, first, "a", "a\n")
+	result, err := first.Push(PushOptions{})
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if len(result.Updates) != 1 || result.Updates[0].Status != RefUpdateOK {
+		t.Errorf("push updates are %+v", result.Updates)
+	}
+	assertRef(t, remote, branch, pushed)
+	assertRef(t, first, "refs/remotes/origin/"+DefaultBranch, pushed)
+
+	fetched, err := second.Fetch(FetchOptions{})
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(fetched.Updates) != 1 || fetched.Updates[0].Old != head || fetched.Updates[0].New != pushed {
+		t.Errorf("fetch updates are %+v", fetched.Updates)
+	}
+	assertRef(t, second, "refs/remotes/origin/"+DefaultBranch, pushed)
+	if !second.HasObject(pushed) {
+		t.Error("the fetched commit is missing")
+	}
+}
+
+func TestPushRejectsNonFastForward(t *testing.T) {
+	remote, _ := initRemoteRepo(t)
+	branch := "refs/heads/" + DefaultBranch
+	first := cloneTestRepo(t, remote.GitDir())
+	second := cloneTestRepo(t, remote.GitDir())
+	pushed := commitFile(t, first, "a", "a\n")
+	if _, err := first.Push(PushOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	diverged := commitFile(t, second, "b", "b\n")
+	if _, err := second.Fetch(FetchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := second.Push(PushOptions{})
+	if !errors.Is(err, ErrPushRejected) {
+		t.Fatalf("Push returned %v, want ErrPushRejected", err)
+	}
+	if update := result.Updates[0]; update.Status != RefUpdateRejected || update.Reason != "non-fast-forward" {
+		t.Errorf("update is %+v, want a non-fast-forward rejection", update)
+	}
+	assertRef(t, remote, branch, pushed)
+
+	// the remote refuses forced updates too if it is configured to
+	setConfig(t, remote, "receive.denyNonFastForwards", "true")
+	result, err = second.Push(PushOptions{Force: true})
+	if !errors.Is(err, ErrPushRejected) {
+		t.Fatalf("forced Push returned %v, want ErrPushRejected", err)
+	}
+	if update := result.Updates[0]; update.Status != RefUpdateRemoteRejected || update.Reason != "non-fast-forward" {
+		t.Errorf("update is %+v, want a non-fast-forward refusal", update)
+	}
+	assertRef(t, remote, branch, pushed)
+
+	setConfig(t, remote, "receive.denyNonFastForwards", "false")
+	if _, err := second.Push(PushOptions{Force: true}); err != nil {
+		t.Fatalf("forced Push: %v", err)
+	}
+	assertRef(t, remote, branch, diverged)
+}
+
+func TestPushDeniesCurrentBranch(t *testing.T) {
+	remote := initTestRepo(t)
+	commitFile(t, remote, "README", "hello\n")
+	branch, err := remote.HeadBranch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := remote.ReadRef(branch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone := cloneTestRepo(t, remote.WorkTree())
+	pushed := commitFile(t, clone, "a", "a\n")
+
+	result, err := clone.Push(PushOptions{})
+	if !errors.Is(err, ErrPushRejected) {
+		t.Fatalf("Push returned %v, want ErrPushRejected", err)
+	}
+	if update := result.Updates[0]; update.Status != RefUpdateRemoteRejected || update.Reason != "branch is currently checked out" {
+		t.Errorf("update is %+v, want a refusal of the checked out branch", update)
+	}
+	assertRef(t, remote, branch, head)
+
+	// other branches can be pushed
+	if _, err := clone.Push(PushOptions{Refspecs: []string{"HEAD:refs/heads/topic"}}); err != nil {
+		t.Fatalf("Push to topic: %v", err)
+	}
+	assertRef(t, remote, "refs/heads/topic", pushed)
+
+	setConfig(t, remote, "receive.denyCurrentBranch", "ignore")
+	if _, err := clone.Push(PushOptions{}); err != nil {
+		t.Fatalf("Push with receive.denyCurrentBranch=ignore: %v", err)
+	}
+	assertRef(t, remote, branch, pushed)
+}
+
+func TestReceiveUpdateRejectsInvalidRefNames(t *testing.T) {
+	r := initTestRepo(t)
+	head := commitFile(t, r, "README", "hello\n")
+	for _, ref := range []string{"refs/heads/a..b", "refs/heads/x.lock", "HEAD", "heads/main"} {
+		reason, err := r.receiveUpdate(receiveCommand{ref: ref, old: zeroHash, new: head})
+		if err != nil {
+			t.Fatalf("receiving %s: %v", ref, err)
+		}
+		if reason != "funny refname" {
+			t.Errorf("receiving %s was refused with %q, want funny refname", ref, reason)
+		}
+	}
+}
+
+// writeTestTree writes a tree with one entry, which may have a name gogit
+// itself would never write.
+func writeTestTree(t *testing.T, r *Repository, mode int, name, hash string) string {
+	t.Helper()
+	raw, err := hex.DecodeString(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := r.WriteObject("tree", []byte(fmt.Sprintf("%o %s\x00%s", mode, name, raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestCloneRefusesInvalidPaths(t *testing.T) {
+	setupTestEnv(t)
+	for _, name := range []string{"..", ".", ".git", ".GIT", "../evil", "a/../../evil", ""} {
+		t.Run(fmt.Sprintf("%q", name), func(t *testing.T) {
+			remote, err := InitBare(filepath.Join(t.TempDir(), "remote.git"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			blob, err := remote.WriteObject("blob", []byte("pwned\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the crafted entry is a directory holding a config file, or the
+			// file itself if its name has a slash
+			tree := writeTestTree(t, remote, 0o100644, name, blob)
+			if !strings.Contains(name, "/") {
+				inner := writeTestTree(t, remote, 0o100644, "config", blob)
+				tree = writeTestTree(t, remote, 0o40000, name, inner)
+			}
+			commit, err := remote.WriteObject("commit", []byte("tree "+tree+"\n"+
+				"author A <a@example.com> 0 +0000\ncommitter A <a@example.com> 0 +0000\n\nevil\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := remote.WriteRef("refs/heads/"+DefaultBranch, commit, "crafted"); err != nil {
+				t.Fatal(err)
+			}
+
+			parent := t.TempDir()
+			_, err = Clone(CloneOptions{URL: remote.GitDir(), Directory: filepath.Join(parent, "clone")})
+			if !errors.Is(err, ErrInvalidPath) {
+				t.Errorf("Clone returned %v, want ErrInvalidPath", err)
+			}
+			for _, file := range []string{"evil", "config"} {
+				if _, err := os.Stat(filepath.Join(parent, file)); err == nil {
+					t.Errorf("the clone wrote %s outside of its working tree", file)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchRefusesCheckedOutBranch(t *testing.T) {
+	remote, head := initRemoteRepo(t)
+	branch := "refs/heads/" + DefaultBranch
+	clone := cloneTestRepo(t, remote.GitDir())
+	other := cloneTestRepo(t, remote.GitDir())
+	pushed := commitFile(t, other, "a", "a\n")
+	if _, err := other.Push(PushOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	refspec := branch + ":" + branch
+	_, err := clone.Fetch(FetchOptions{Refspecs: []string{refspec}})
+	if err == nil || !strings.Contains(err.Error(), "refusing to fetch into branch") {
+		t.Errorf("Fetch into the checked out branch returned %v", err)
+	}
+	assertRef(t, clone, branch, head)
+
+	if _, err := clone.Fetch(FetchOptions{Refspecs: []string{branch + ":refs/heads/other"}}); err != nil {
+		t.Fatalf("Fetch into another branch: %v", err)
+	}
+	assertRef(t, clone, "refs/heads/other", pushed)
+
+	if _, err := clone.Fetch(FetchOptions{Refspecs: []string{refspec}, UpdateHeadOK: true}); err != nil {
+		t.Fatalf("Fetch with UpdateHeadOK: %v", err)
+	}
+	assertRef(t, clone, branch, pushed)
+
+	// a bare repository has no working tree to fall behind
+	mirror, err := Clone(CloneOptions{URL: remote.GitDir(), Directory: filepath.Join(t.TempDir(), "mirror.git"), Bare: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mirror.Fetch(FetchOptions{Refspecs: []string{refspec}}); err != nil {
+		t.Fatalf("Fetch into a bare repository: %v", err)
+	}
+	assertRef(t, mirror, branch, pushed)
+}
@@ -0,0 +1,201 @@
+package repo
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CreateTree writes the tree objects of the current index, one for every
+// directory, and returns the hash of the top one.
+func (r *Repository) CreateTree() (string, error) {
+	entries, err := r.ReadIndex()
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		if entry.Stage() != 0 {
+			return "", fmt.Errorf("%s: unmerged entry, cannot write a tree", entry.Path)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return r.writeTree(entries, "")
+}
+
+// writeTree writes the tree of the directory prefix, which ends with a
+// slash unless it is the root, from the sorted index entries below it and
+// returns its hash. Subdirectories are written first, as their own trees.
+func (r *Repository) writeTree(entries []IndexEntry, prefix string) (string, error) {
+	tree := []TreeEntry{}
+	for i := 0; i < len(entries); {
+		name, _, isDir := strings.Cut(strings.TrimPrefix(entries[i].Path, prefix), "/")
+		if !isDir {
+			tree = append(tree, TreeEntry{Mode: entries[i].Mode, Name: name, Hash: entries[i].Hash()})
+			i++
+			continue
+		}
+		// the paths of a directory follow each other in sorted order
+		dir := prefix + name + "/"
+		end := i + 1
+		for end < len(entries) && strings.HasPrefix(entries[end].Path, dir) {
+			end++
+		}
+		hash, err := r.writeTree(entries[i:end], dir)
+		if err != nil {
+			return "", err
+		}
+		tree = append(tree, TreeEntry{Mode: 0o40000, Name: name, Hash: hash})
+		i = end
+	}
+	// git sorts a subtree as if its name ended with a slash
+	sortName := func(entry TreeEntry) string {
+		if entry.IsTree() {
+			return entry.Name + "/"
+		}
+		return entry.Name
+	}
+	sort.Slice(tree, func(i, j int) bool { return sortName(tree[i]) < sortName(tree[j]) })
+
+	var content bytes.Buffer
+	for _, entry := range tree {
+		hash, err := hex.DecodeString(entry.Hash)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&content, "%o %s\x00", entry.Mode, entry.Name)
+		content.Write(hash)
+	}
+	return r.WriteObject("tree", content.Bytes())
+}
+
+// TreeEntry is one entry of a tree object.
+type TreeEntry struct {
+	Mode int
+	Name string
+	Hash string
+}
+
+// IsTree reports whether the entry is a subtree.
+func (entry TreeEntry) IsTree() bool {
+	return entry.Mode == 0o40000
+}
+
+// IsSubmodule reports whether the entry is a gitlink to a commit.
+func (entry TreeEntry) IsSubmodule() bool {
+	return entry.Mode == 0o160000
+}
+
+// Type returns the type of the object the entry points to.
+func (entry TreeEntry) Type() string {
+	if entry.IsTree() {
+		return "tree"
+	}
+	if entry.IsSubmodule() {
+		return "commit"
+	}
+	return "blob"
+}
+
+// ReadTree returns the entries of a tree object.
+func (r *Repository) ReadTree(tree string) ([]TreeEntry, error) {
+	objectType, contents, err := r.ReadObject(tree)
+	if err != nil {
+		return nil, err
+	}
+	if objectType != "tree" {
+		return nil, fmt.Errorf("invalid tree object type: %v", objectType)
+	}
+	return parseTree(contents)
+}
+
+// parseTree parses the "<mode> <name>\x00<20 byte hash>" records of a tree.
+func parseTree(content []byte) ([]TreeEntry, error) {
+	var entries []TreeEntry
+	i := 0
+	for i < len(content) {
+		end := bytes.IndexByte(content[i:], 0)
+		if end == -1 {
+			return nil, fmt.Errorf("invalid tree object: unterminated entry")
+		}
+		end = i + end
+
+		if end+21 > len(content) {
+			return nil, fmt.Errorf("invalid tree object: truncated entry")
+		}
+		modeText, name, found := strings.Cut(string(content[i:end]), " ")
+		if !found {
+			return nil, fmt.Errorf("invalid tree object: malformed entry")
+		}
+		mode, err := strconv.ParseInt(modeText, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tree object: bad mode %q", modeText)
+		}
+		entries = append(entries, TreeEntry{
+			Mode: int(mode),
+			Name: name,
+			Hash: hex.EncodeToString(content[end+1 : end+21]),
+		})
+		i = end + 21
+	}
+
+	return entries, nil
+}
+
+// TreeFile is a tree entry with its path from the root of the tree.
+type TreeFile struct {
+	TreeEntry
+	Path string
+}
+
+// ListTreeOptions selects what ListTree returns.
+type ListTreeOptions struct {
+	// Paths limits the listing to the entries MatchPathspec selects. A
+	// path ending with a slash lists the contents of that directory.
+	Paths []string
+	// Recursive descends into subtrees, listing their contents instead of
+	// them.
+	Recursive bool
+	// ShowTrees also lists the subtrees descended into.
+	ShowTrees bool
+}
+
+// ListTree returns the entries of tree as ls-tree shows them, in tree
+// order.
+func (r *Repository) ListTree(tree string, opts ListTreeOptions) ([]TreeFile, error) {
+	files := []TreeFile{}
+	err := r.listTree(tree, "", opts, &files)
+	return files, err
+}
+
+func (r *Repository) listTree(tree, prefix string, opts ListTreeOptions, files *[]TreeFile) error {
+	entries, err := r.ReadTree(tree)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := prefix + entry.Name
+		// a path below the entry makes us look inside it
+		inside := false
+		for _, spec := range opts.Paths {
+			inside = inside || strings.HasPrefix(spec, name+"/")
+		}
+		selected := MatchPathspec(opts.Paths, name)
+		if !inside && !selected {
+			continue
+		}
+		if !entry.IsTree() || (!inside && !opts.Recursive) {
+			*files = append(*files, TreeFile{TreeEntry: entry, Path: name})
+			continue
+		}
+		if opts.ShowTrees {
+			*files = append(*files, TreeFile{TreeEntry: entry, Path: name})
+		}
+		if err := r.listTree(entry.Hash, name+"/", opts, files); err != nil {
+			return err
+		}
+	}
+	return nil
+}
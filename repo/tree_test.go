@@ -0,0 +1,49 @@
+package repo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestListTree(t *testing.T) {
+	r := initTestRepo(t)
+	commitFile(t, r, "a", "1\n")
+	commitFile(t, r, "d/b", "2\n")
+	commitFile(t, r, "d/e/c", "3\n")
+	commitFile(t, r, "z", "4\n")
+	tree, err := r.ResolveRevision("HEAD^{tree}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		opts ListTreeOptions
+		want string
+	}{
+		{ListTreeOptions{}, "a d z"},
+		{ListTreeOptions{Recursive: true}, "a d/b d/e/c z"},
+		{ListTreeOptions{Recursive: true, ShowTrees: true}, "a d d/b d/e d/e/c z"},
+		{ListTreeOptions{Paths: []string{"d"}}, "d"},
+		{ListTreeOptions{Paths: []string{"d/"}}, "d/b d/e"},
+		{ListTreeOptions{Paths: []string{"d/e/c", "z"}}, "d/e/c z"},
+		{ListTreeOptions{Paths: []string{"d/e/c"}, ShowTrees: true}, "d d/e d/e/c"},
+		{ListTreeOptions{Paths: []string{"missing"}}, ""},
+	} {
+		files, err := r.ListTree(tree, test.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths := []string{}
+		for _, file := range files {
+			paths = append(paths, file.Path)
+		}
+		if got := strings.Join(paths, " "); got != test.want {
+			t.Errorf("ListTree(%+v) = %s, want %s", test.opts, got, test.want)
+		}
+	}
+
+	files, err := r.ListTree(tree, ListTreeOptions{Paths: []string{"d/b"}})
+	if err != nil || len(files) != 1 || files[0].Type() != "blob" || files[0].Mode != 0100644 {
+		t.Errorf("d/b is listed as %+v, %v", files, err)
+	}
+}
@@ -0,0 +1,268 @@
+package repo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// uploadPackSession talks to the upload-pack service of a remote, in
+// protocol v2 if the server speaks it and v0 otherwise.
+type uploadPackSession struct {
+	transport transport
+	// version is 2 or 0.
+	version int
+	// capabilities are the v2 capabilities, or the v0 ones of adv.
+	capabilities []string
+	// adv is the full v0 ref advertisement, nil in protocol v2.
+	adv *Advertisement
+}
+
+// connectUploadPack starts an upload-pack session. Protocol v2 is requested
+// unless protocol.version is set to 0 or 1.
+func (r *Repository) connectUploadPack(url, userName, password string) (*uploadPackSession, error) {
+	t, err := r.openTransport(url, userName, password)
+	if err != nil {
+		return nil, err
+	}
+	protocol := protocolV2
+	if version := r.configValue("protocol.version"); version == "0" || version == "1" {
+		protocol = ""
+	}
+	body, err := t.advertise("git-upload-pack", protocol)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &uploadPackSession{transport: t}
+	if capabilities, ok := parseV2Capabilities(body); ok {
+		session.version = 2
+		session.capabilities = capabilities
+		return session, nil
+	}
+	adv, err := parseAdvertisement(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ref advertisement from %s: %w", url, err)
+	}
+	session.adv = adv
+	session.capabilities = adv.Capabilities
+	return session, nil
+}
+
+// parseV2Capabilities returns the capability advertisement of a protocol v2
+// server, or false if body is a v0/v1 ref advertisement.
+func parseV2Capabilities(body []byte) ([]string, bool) {
+	pkt := newPktReader(bytes.NewReader(body))
+	line, err := pkt.ReadLine()
+	if err == nil && strings.HasPrefix(line, "# service=") {
+		// skip the smart HTTP header and the flush after it
+		if _, err = pkt.ReadLine(); err == io.EOF {
+			line, err = pkt.ReadLine()
+		}
+	}
+	if err != nil || line != "version 2" {
+		return nil, false
+	}
+	capabilities := []string{}
+	for {
+		line, err := pkt.ReadLine()
+		if err != nil {
+			return capabilities, true
+		}
+		capabilities = append(capabilities, line)
+	}
+}
+
+// supports reports whether the v2 server advertised command, optionally
+// with feature among its values, as in "fetch=shallow wait-for-done".
+func (s *uploadPackSession) supports(command, feature string) bool {
+	for _, capability := range s.capabilities {
+		name, values, _ := strings.Cut(capability, "=")
+		if name != command {
+			continue
+		}
+		return feature == "" || hasCapability(strings.Fields(values), feature)
+	}
+	return false
+}
+
+// command sends a v2 command with its arguments and returns the response.
+func (s *uploadPackSession) command(name string, args []string) ([]byte, error) {
+	var request bytes.Buffer
+	pkt := newPktWriter(&request)
+	pkt.WriteString("command=" + name + "\n")
+	pkt.WriteString("agent=" + agent + "\n")
+	if s.supports("object-format", "") {
+		pkt.WriteString("object-format=sha1\n")
+	}
+	pkt.Delim()
+	for _, arg := range args {
+		pkt.WriteString(arg + "\n")
+	}
+	if err := pkt.Flush(); err != nil {
+		return nil, err
+	}
+	return s.transport.request("git-upload-pack", protocolV2, request.Bytes())
+}
+
+// listRefs returns the refs of the remote that start with one of prefixes,
+// or all refs if there are none. In protocol v2 only the matching refs are
+// sent by the server.
+func (s *uploadPackSession) listRefs(prefixes []string) (*Advertisement, error) {
+	if s.version != 2 {
+		return s.adv.filter(prefixes), nil
+	}
+
+	args := []string{"peel", "symrefs"}
+	for _, prefix := range prefixes {
+		args = append(args, "ref-prefix "+prefix)
+	}
+	body, err := s.command("ls-refs", args)
+	if err != nil {
+		return nil, err
+	}
+
+	adv := &Advertisement{Peeled: map[string]string{}, Symrefs: map[string]string{}, Capabilities: s.capabilities}
+	pkt := newPktReader(bytes.NewReader(body))
+	for {
+		line, err := pkt.ReadLine()
+		if err == io.EOF {
+			return adv, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid ls-refs response: %w", err)
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !isHash(fields[0]) {
+			return nil, fmt.Errorf("invalid ls-refs line %q", line)
+		}
+		name := fields[1]
+		adv.Refs = append(adv.Refs, Ref{Name: name, Hash: fields[0]})
+		for _, attribute := range fields[2:] {
+			if target, found := strings.CutPrefix(attribute, "symref-target:"); found {
+				adv.Symrefs[name] = target
+			} else if peeled, found := strings.CutPrefix(attribute, "peeled:"); found {
+				adv.Peeled[name] = peeled
+			}
+		}
+	}
+}
+
+// fetchPack asks the remote for a pack with the objects reachable from
+// wants but not from haves. Progress messages go to progress.
+func (s *uploadPackSession) fetchPack(wants, haves []string, progress io.Writer) ([]byte, error) {
+	if s.version == 2 {
+		return s.fetchPackV2(wants, haves, progress)
+	}
+
+	var request bytes.Buffer
+	pkt := newPktWriter(&request)
+	sideband := hasCapability(s.capabilities, "side-band-64k")
+	requested := []string{}
+	for _, capability := range []string{"side-band-64k", "ofs-delta"} {
+		if hasCapability(s.capabilities, capability) {
+			requested = append(requested, capability)
+		}
+	}
+	requested = append(requested, "agent="+agent)
+	for i, want := range wants {
+		if i == 0 {
+			pkt.WriteString("want " + want + " " + strings.Join(requested, " ") + "\n")
+		} else {
+			pkt.WriteString("want " + want + "\n")
+		}
+	}
+	pkt.Flush()
+	for _, have := range haves {
+		pkt.WriteString("have " + have + "\n")
+	}
+	if err := pkt.WriteString("done\n"); err != nil {
+		return nil, err
+	}
+	body, err := s.transport.request("git-upload-pack", "", request.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	// the pack follows the ACK and NAK lines of the negotiation
+	reader := newPktReader(bytes.NewReader(body))
+	for {
+		header, err := reader.reader.Peek(7)
+		if err != nil || (string(header[4:]) != "ACK" && string(header[4:]) != "NAK") {
+			break
+		}
+		if _, err := reader.ReadLine(); err != nil {
+			return nil, fmt.Errorf("invalid upload-pack response: %w", err)
+		}
+	}
+	if !sideband {
+		return io.ReadAll(reader.reader)
+	}
+	return demuxSideband(reader, progress)
+}
+
+func (s *uploadPackSession) fetchPackV2(wants, haves []string, progress io.Writer) ([]byte, error) {
+	args := []string{"ofs-delta"}
+	for _, want := range wants {
+		args = append(args, "want "+want)
+	}
+	for _, have := range haves {
+		args = append(args, "have "+have)
+	}
+	args = append(args, "done")
+	body, err := s.command("fetch", args)
+	if err != nil {
+		return nil, err
+	}
+
+	// skip any sections before the packfile, e.g. shallow-info
+	pkt := newPktReader(bytes.NewReader(body))
+	for {
+		kind, payload, err := pkt.Read()
+		if err != nil {
+			return nil, fmt.Errorf("invalid fetch response: %w", err)
+		}
+		if kind == pktFlush {
+			return nil, errors.New("invalid fetch response: no packfile section")
+		}
+		if kind == pktData && strings.TrimSuffix(string(payload), "\n") == "packfile" {
+			return demuxSideband(pkt, progress)
+		}
+	}
+}
+
+// filter returns the refs of adv that start with one of prefixes, or adv
+// itself if there are none.
+func (adv *Advertisement) filter(prefixes []string) *Advertisement {
+	if len(prefixes) == 0 {
+		return adv
+	}
+	return adv.filterFunc(func(name string) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// filterFunc returns the refs of adv for which keep is true.
+func (adv *Advertisement) filterFunc(keep func(name string) bool) *Advertisement {
+	filtered := &Advertisement{Peeled: map[string]string{}, Symrefs: map[string]string{}, Capabilities: adv.Capabilities}
+	for _, ref := range adv.Refs {
+		if !keep(ref.Name) {
+			continue
+		}
+		filtered.Refs = append(filtered.Refs, ref)
+		if peeled, found := adv.Peeled[ref.Name]; found {
+			filtered.Peeled[ref.Name] = peeled
+		}
+		if target, found := adv.Symrefs[ref.Name]; found {
+			filtered.Symrefs[ref.Name] = target
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,150 @@
+package repo
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// v2Server serves canned upload-pack responses: protocol v2 with an
+// ls-refs command if the client asks for it and v2 is set, a v0 ref
+// advertisement otherwise. It returns the URL and the ls-refs arguments
+// of each request.
+func v2Server(t *testing.T, v2 bool) (string, *[][]string) {
+	t.Helper()
+	main := strings.Repeat("1", 40)
+	tag := strings.Repeat("2", 40)
+	peeled := strings.Repeat("3", 40)
+	requests := [][]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var response bytes.Buffer
+		pkt := newPktWriter(&response)
+		if req.Method == http.MethodGet {
+			pkt.WriteString("# service=git-upload-pack\n")
+			pkt.Flush()
+			if v2 && req.Header.Get("Git-Protocol") == protocolV2 {
+				pkt.WriteString("version 2\n")
+				pkt.WriteString("agent=test\n")
+				pkt.WriteString("ls-refs=unborn\n")
+				pkt.WriteString("fetch=shallow wait-for-done\n")
+			} else {
+				pkt.WriteString(main + " HEAD\x00side-band-64k symref=HEAD:refs/heads/main\n")
+				pkt.WriteString(main + " refs/heads/main\n")
+				pkt.WriteString(tag + " refs/tags/v1\n")
+				pkt.WriteString(peeled + " refs/tags/v1^{}\n")
+			}
+			pkt.Flush()
+			w.Write(response.Bytes())
+			return
+		}
+
+		body, _ := io.ReadAll(req.Body)
+		reader := newPktReader(bytes.NewReader(body))
+		args := []string{}
+		for {
+			kind, payload, err := reader.Read()
+			if err != nil || kind == pktFlush {
+				break
+			}
+			if kind == pktData {
+				args = append(args, strings.TrimSuffix(string(payload), "\n"))
+			}
+		}
+		requests = append(requests, args)
+		pkt.WriteString(main + " HEAD symref-target:refs/heads/main\n")
+		pkt.WriteString(main + " refs/heads/main\n")
+		pkt.WriteString(tag + " refs/tags/v1 peeled:" + peeled + "\n")
+		pkt.Flush()
+		w.Write(response.Bytes())
+	}))
+	t.Cleanup(server.Close)
+	return server.URL + "/repo.git", &requests
+}
+
+func TestParseV2Capabilities(t *testing.T) {
+	var body bytes.Buffer
+	pkt := newPktWriter(&body)
+	pkt.WriteString("version 2\n")
+	pkt.WriteString("ls-refs\n")
+	pkt.WriteString("fetch=shallow filter\n")
+	pkt.Flush()
+	capabilities, ok := parseV2Capabilities(body.Bytes())
+	if !ok || len(capabilities) != 2 {
+		t.Fatalf("capabilities are %v, %v", capabilities, ok)
+	}
+	session := &uploadPackSession{version: 2, capabilities: capabilities}
+	for _, test := range []struct {
+		command, feature string
+		want             bool
+	}{
+		{"ls-refs", "", true},
+		{"fetch", "filter", true},
+		{"fetch", "wait-for-done", false},
+		{"object-format", "", false},
+	} {
+		if got := session.supports(test.command, test.feature); got != test.want {
+			t.Errorf("supports(%q, %q) = %v", test.command, test.feature, got)
+		}
+	}
+
+	body.Reset()
+	pkt.WriteString(strings.Repeat("1", 40) + " HEAD\x00side-band-64k\n")
+	pkt.Flush()
+	if _, ok := parseV2Capabilities(body.Bytes()); ok {
+		t.Error("a v0 advertisement was taken for v2")
+	}
+}
+
+func TestListRefs(t *testing.T) {
+	r := initTestRepo(t)
+	for _, test := range []struct {
+		name    string
+		v2      bool
+		version int
+	}{{"v2", true, 2}, {"v0 fallback", false, 0}} {
+		url, requests := v2Server(t, test.v2)
+		session, err := r.connectUploadPack(url, "", "")
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if session.version != test.version {
+			t.Errorf("%s: session speaks version %d", test.name, session.version)
+		}
+		adv, err := session.listRefs([]string{"refs/tags/"})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if test.v2 {
+			if len(*requests) != 1 || !strings.Contains(strings.Join((*requests)[0], "\n"), "command=ls-refs\n") ||
+				!strings.Contains(strings.Join((*requests)[0], "\n"), "ref-prefix refs/tags/") {
+				t.Errorf("%s: requests are %q", test.name, *requests)
+			}
+			// the server decides what it sends in v2
+			if len(adv.Refs) != 3 || adv.Symrefs["HEAD"] != "refs/heads/main" {
+				t.Errorf("%s: refs are %+v, symrefs %v", test.name, adv.Refs, adv.Symrefs)
+			}
+		} else if len(adv.Refs) != 1 || len(*requests) != 0 {
+			t.Errorf("%s: refs are %+v after %d requests", test.name, adv.Refs, len(*requests))
+		}
+		if adv.Peeled["refs/tags/v1"] != strings.Repeat("3", 40) {
+			t.Errorf("%s: peeled are %v", test.name, adv.Peeled)
+		}
+	}
+
+	// protocol.version = 0 never asks for v2
+	url, _ := v2Server(t, true)
+	config, err := r.ConfigFile(ScopeLocal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Set("protocol.version", "0")
+	if err := config.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if session, err := r.connectUploadPack(url, "", ""); err != nil || session.version != 0 {
+		t.Errorf("with protocol.version 0 the session is %+v, %v", session, err)
+	}
+}
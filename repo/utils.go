@@ -0,0 +1,86 @@
+package repo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const zeroHash = "0000000000000000000000000000000000000000"
+
+func (r *Repository) getUserName() (string, error) {
+	name := os.Getenv("GOGIT_USERNAME")
+	if name == "" {
+		name = r.configValue("user.name")
+	}
+	if name == "" {
+		return "", errors.New("username not set, use GOGIT_USERNAME or user.name")
+	}
+	return name, nil
+}
+
+func (r *Repository) getEmail() (string, error) {
+	email := os.Getenv("GOGIT_EMAIL")
+	if email == "" {
+		email = r.configValue("user.email")
+	}
+	if email == "" {
+		return "", errors.New("email not set, use GOGIT_EMAIL or user.email")
+	}
+	return email, nil
+}
+
+// signature returns the "name <email> timestamp timezone" line identifying
+// the user at when, as used for authors, committers and taggers.
+func (r *Repository) signature(when time.Time) (string, error) {
+	name, err := r.getUserName()
+	if err != nil {
+		return "", err
+	}
+	email, err := r.getEmail()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s <%s> %s", name, email, signatureDate(when)), nil
+}
+
+// signatureDate formats when as signatures record it: seconds since the
+// epoch and the offset from UTC.
+func signatureDate(when time.Time) string {
+	return fmt.Sprintf("%d %s", when.Unix(), when.Format("-0700"))
+}
+
+// configValue returns the value of key, or "" if it is not set or the
+// configuration cannot be read.
+func (r *Repository) configValue(key string) string {
+	config, err := r.Config()
+	if err != nil {
+		return ""
+	}
+	value, _ := config.Get(key)
+	return value
+}
+
+func paddInteger(n int, size int) []byte {
+	if size == 2 {
+		b := make([]byte, size)
+		binary.BigEndian.PutUint16(b, uint16(n))
+		return b
+	}
+
+	b := make([]byte, size)
+	binary.BigEndian.PutUint32(b, uint32(n))
+	return b
+}
+
+// setCorrectMode reduces the permissions of a file mode to the two git
+// records: 755 if anyone may execute the file, 644 otherwise.
+func setCorrectMode(mode int) int {
+	const mask = 0o777
+	if mode&0o111 != 0 {
+		return mode&^mask | 0o755
+	}
+	return mode&^mask | 0o644
+}
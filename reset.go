@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tamimehsan/gogit/repo"
+)
+
+const resetUsage = "usage: gogit reset [--soft | --mixed | --hard] [-q] [<commit>]\n" +
+	"   or: gogit reset [-q] [<commit>] [--] <paths>..."
+
+func runReset(args []string) {
+	resetCmd := flag.NewFlagSet("reset", flag.ExitOnError)
+	soft := resetCmd.Bool("soft", false, "Only move the current branch")
+	mixed := resetCmd.Bool("mixed", false, "Also reset the index, the default")
+	hard := resetCmd.Bool("hard", false, "Also reset the index and the tracked files of the working tree")
+	quiet := resetCmd.Bool("q", false, "Only report errors")
+	resetCmd.BoolVar(quiet, "quiet", false, "Only report errors")
+	args = parseArgs(resetCmd, args)
+
+	mode, modes := repo.ResetMixed, 0
+	for _, selected := range []struct {
+		set  bool
+		mode repo.ResetMode
+	}{{*soft, repo.ResetSoft}, {*mixed, repo.ResetMixed}, {*hard, repo.ResetHard}} {
+		if selected.set {
+			mode = selected.mode
+			modes++
+		}
+	}
+	if modes > 1 {
+		usageError(resetUsage)
+	}
+
+	r := openRepo()
+	revision, paths := splitResetArgs(r, args)
+	if len(paths) > 0 {
+		switch {
+		case *soft:
+			check(errors.New("cannot do soft reset with paths"))
+		case *hard:
+			check(errors.New("cannot do hard reset with paths"))
+		}
+		specs := make([]string, 0, len(paths))
+		for _, path := range paths {
+			rel, err := r.Rel(path)
+			check(err)
+			specs = append(specs, rel)
+		}
+		check(r.ResetPaths(revision, specs))
+	} else {
+		check(r.Reset(revision, mode))
+	}
+	if *quiet {
+		return
+	}
+	switch {
+	case mode == repo.ResetHard:
+		head, err := r.ResolveRevision("HEAD")
+		check(err)
+		commit, err := r.ReadCommit(head)
+		check(err)
+		short, err := r.AbbreviateHash(head, 7)
+		check(err)
+		subject, _, _ := strings.Cut(strings.TrimSpace(commit.Message), "\n")
+		fmt.Printf("HEAD is now at %s %s\n", short, subject)
+	case mode == repo.ResetMixed:
+		printUnstaged(r)
+	}
+}
+
+// splitResetArgs tells the commit from the paths among the arguments of
+// reset. Without "--" the first argument is the commit if it names one.
+func splitResetArgs(r *repo.Repository, args []string) (string, []string) {
+	for i, arg := range args {
+		if arg != "--" {
+			continue
+		}
+		if i > 1 {
+			usageError(resetUsage)
+		}
+		if i == 1 {
+			return args[0], args[2:]
+		}
+		return "", args[1:]
+	}
+	if len(args) == 0 {
+		return "", nil
+	}
+	if _, err := r.ResolveRevision(args[0]); err == nil {
+		return args[0], args[1:]
+	}
+	for _, path := range args {
+		if _, err := os.Lstat(path); err != nil {
+			check(fmt.Errorf("ambiguous argument '%s': unknown revision or path not in the working tree.", path))
+		}
+	}
+	return "", args
+}
+
+// printUnstaged lists the tracked files that differ from the index, as git
+// does after resetting it.
+func printUnstaged(r *repo.Repository) {
+	status, err := r.Status()
+	check(err)
+	changes := map[string]string{}
+	paths := []string{}
+	for _, path := range status.Modified {
+		changes[path] = "M"
+		paths = append(paths, path)
+	}
+	for _, path := range status.Deleted {
+		changes[path] = "D"
+		paths = append(paths, path)
+	}
+	if len(paths) == 0 {
+		return
+	}
+	fmt.Println("Unstaged changes after reset:")
+	cwd, err := os.Getwd()
+	check(err)
+	sort.Strings(paths)
+	for _, path := range paths {
+		rel, err := filepath.Rel(cwd, filepath.Join(r.WorkTree(), path))
+		check(err)
+		fmt.Printf("%s\t%s\n", changes[path], filepath.ToSlash(rel))
+	}
+}
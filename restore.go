@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/tamimehsan/gogit/repo"
+)
+
+func runRestore(args []string) {
+	restoreCmd := flag.NewFlagSet("restore", flag.ExitOnError)
+	opts := repo.RestoreOptions{}
+	restoreCmd.StringVar(&opts.Source, "s", "", "Restore from this tree-ish instead of the index or HEAD")
+	restoreCmd.StringVar(&opts.Source, "source", "", "Restore from this tree-ish instead of the index or HEAD")
+	restoreCmd.BoolVar(&opts.Staged, "S", false, "Restore the index")
+	restoreCmd.BoolVar(&opts.Staged, "staged", false, "Restore the index")
+	restoreCmd.BoolVar(&opts.Worktree, "W", false, "Restore the working tree, the default")
+	restoreCmd.BoolVar(&opts.Worktree, "worktree", false, "Restore the working tree, the default")
+	args = parseArgs(restoreCmd, args)
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		usageError("usage: gogit restore [--staged] [--worktree] [--source=<tree-ish>] [--] <pathspec>...")
+	}
+
+	r := openRepo()
+	paths := make([]string, 0, len(args))
+	for _, arg := range args {
+		rel, err := r.Rel(arg)
+		check(err)
+		paths = append(paths, rel)
+	}
+	check(r.Restore(paths, opts))
+}
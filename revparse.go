@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tamimehsan/gogit/repo"
+)
+
+// runRevParse prints the object names of revisions and answers questions
+// about the repository. Options are taken in order like git does, so
+// --short and friends apply to the revisions after them.
+func runRevParse(args []string) {
+	r := openRepo()
+	verify, quiet, short := false, false, 0
+	format := ""
+	revisions := []string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			revisions = append(revisions, args[i+1:]...)
+			break
+		}
+		switch {
+		case arg == "--verify":
+			verify = true
+		case arg == "-q" || arg == "--quiet":
+			quiet = true
+		case arg == "--short":
+			short = 7
+		case strings.HasPrefix(arg, "--short="):
+			n, err := strconv.Atoi(strings.TrimPrefix(arg, "--short="))
+			if err != nil {
+				usageError("usage: gogit rev-parse --short[=<length>] <revision>")
+			}
+			short = n
+		case arg == "--abbrev-ref" || arg == "--symbolic-full-name":
+			format = arg
+		case arg == "--git-dir":
+			// like git, the path is relative at the top of the working tree
+			if wd, err := os.Getwd(); err == nil && r.GitDir() == filepath.Join(wd, ".git") {
+				fmt.Println(".git")
+			} else {
+				fmt.Println(r.GitDir())
+			}
+		case arg == "--show-toplevel":
+			if r.IsBare() {
+				check(repo.ErrBareRepository)
+			}
+			fmt.Println(r.WorkTree())
+		case arg == "--is-inside-work-tree":
+			fmt.Println(!r.IsBare())
+		case arg == "--is-bare-repository":
+			fmt.Println(r.IsBare())
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			// unknown options are passed through, as git does for scripts
+			fmt.Println(arg)
+		default:
+			revisions = append(revisions, arg)
+		}
+	}
+
+	if verify && len(revisions) != 1 {
+		if quiet {
+			os.Exit(1)
+		}
+		check(errors.New("Needed a single revision"))
+	}
+	for _, revision := range revisions {
+		lines, err := parseRevisionArg(r, revision, format, short)
+		if err != nil {
+			if quiet {
+				os.Exit(1)
+			}
+			if verify {
+				check(errors.New("Needed a single revision"))
+			}
+			if errors.Is(err, repo.ErrUnknownRevision) {
+				err = fmt.Errorf("ambiguous argument '%s': unknown revision or path not in the working tree.", revision)
+			}
+			check(err)
+		}
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+	}
+}
+
+// parseRevisionArg returns what rev-parse prints for one argument, which
+// may also be a range a..b or an exclusion ^a.
+func parseRevisionArg(r *repo.Repository, arg, format string, short int) ([]string, error) {
+	if from, to, isRange := strings.Cut(arg, ".."); isRange && !strings.Contains(to, ".") {
+		if from == "" {
+			from = "HEAD"
+		}
+		if to == "" {
+			to = "HEAD"
+		}
+		included, err := formatRevision(r, to, format, short)
+		if err != nil {
+			return nil, err
+		}
+		excluded, err := formatRevision(r, from, format, short)
+		if err != nil {
+			return nil, err
+		}
+		return []string{included, "^" + excluded}, nil
+	}
+	if excluded, found := strings.CutPrefix(arg, "^"); found {
+		line, err := formatRevision(r, excluded, format, short)
+		return []string{"^" + line}, err
+	}
+	line, err := formatRevision(r, arg, format, short)
+	return []string{line}, err
+}
+
+// formatRevision resolves a revision and prints it as its object name,
+// abbreviated to short characters if set, or as its ref name.
+func formatRevision(r *repo.Repository, revision, format string, short int) (string, error) {
+	hash, err := r.ResolveRevision(revision)
+	if err != nil {
+		return "", err
+	}
+	switch format {
+	case "--symbolic-full-name", "--abbrev-ref":
+		name, err := r.SymbolicFullName(revision)
+		if err != nil {
+			return "", err
+		}
+		if format == "--abbrev-ref" {
+			name = repo.ShortRefName(name)
+		}
+		return name, nil
+	}
+	if short > 0 {
+		return r.AbbreviateHash(hash, short)
+	}
+	return hash, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/tamimehsan/gogit/repo"
+)
+
+// runServe serves the repositories below a directory over smart HTTP.
+// Pushing requires a --users file, or http.receivepack set in the
+// repository pushed to.
+func runServe(args []string) {
+	serveCmd := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := serveCmd.String("listen", ":8080", "The address to listen on")
+	realm := serveCmd.String("realm", "", "The realm sent to clients asked for credentials")
+	users := serveCmd.String("users", "", "An htpasswd file of the users allowed to access the repositories")
+	args = parseArgs(serveCmd, args)
+	if len(args) != 1 {
+		usageError("usage: gogit serve [--listen <address>] [--realm <realm>] [--users <htpasswd file>] <directory>")
+	}
+
+	info, err := os.Stat(args[0])
+	check(err)
+	if !info.IsDir() {
+		check(fmt.Errorf("'%s' is not a directory", args[0]))
+	}
+	backend := &repo.HTTPBackend{Root: args[0], Realm: *realm}
+	if *users != "" {
+		passwords, err := repo.ReadPasswordFile(*users)
+		check(err)
+		backend.Authorize = repo.BasicAuth(passwords)
+	}
+
+	fmt.Fprintf(os.Stderr, "Serving %s on %s\n", args[0], *listen)
+	check(http.ListenAndServe(*listen, backend))
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/tamimehsan/gogit/repo"
+)
+
+// runService serves git-upload-pack or git-receive-pack for the repository
+// given in args over stdin and stdout, the way sshd runs them for clients.
+// The protocol version is taken from GIT_PROTOCOL.
+func runService(name string, args []string) {
+	serviceCmd := flag.NewFlagSet(name, flag.ExitOnError)
+	args = parseArgs(serviceCmd, args)
+	if len(args) != 1 {
+		usageError("usage: gogit " + name + " <directory>")
+	}
+
+	r, err := repo.OpenDir(args[0])
+	check(err)
+	version := repo.ParseProtocolVersion(os.Getenv("GIT_PROTOCOL"))
+	check(r.AdvertiseRefs(os.Stdout, "git-"+name, version))
+	if name == "upload-pack" {
+		check(r.UploadPack(os.Stdin, os.Stdout, version))
+	} else {
+		check(r.ReceivePack(os.Stdin, os.Stdout))
+	}
+}
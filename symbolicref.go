@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tamimehsan/gogit/repo"
+)
+
+const symbolicRefUsage = "usage: gogit symbolic-ref [-q] [--short] [--no-recurse] <name>\n" +
+	"   or: gogit symbolic-ref [-m <reason>] <name> <ref>\n" +
+	"   or: gogit symbolic-ref (-d | --delete) [-q] <name>"
+
+func runSymbolicRef(args []string) {
+	symbolicRefCmd := flag.NewFlagSet("symbolic-ref", flag.ExitOnError)
+	quiet := symbolicRefCmd.Bool("q", false, "Exit with status 1 instead of an error if the ref is not symbolic")
+	symbolicRefCmd.BoolVar(quiet, "quiet", false, "Exit with status 1 instead of an error if the ref is not symbolic")
+	short := symbolicRefCmd.Bool("short", false, "Show the target without refs/heads/ and similar prefixes")
+	noRecurse := symbolicRefCmd.Bool("no-recurse", false, "Show the target itself, even if it is symbolic too")
+	deleteRef := symbolicRefCmd.Bool("d", false, "Delete the symbolic ref")
+	symbolicRefCmd.BoolVar(deleteRef, "delete", false, "Delete the symbolic ref")
+	message := symbolicRefCmd.String("m", "", "The reason for the update, recorded in the reflog")
+	args = parseArgs(symbolicRefCmd, args)
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+
+	r := openRepo()
+	switch {
+	case *deleteRef && len(args) == 1:
+		name := args[0]
+		if _, err := r.SymbolicRef(name); err != nil {
+			if *quiet && errors.Is(err, repo.ErrNotSymbolicRef) {
+				os.Exit(1)
+			}
+			check(fmt.Errorf("cannot delete %s, not a symbolic ref", name))
+		}
+		if name == "HEAD" {
+			check(fmt.Errorf("deleting '%s' is not allowed", name))
+		}
+		check(r.DeleteRef(name))
+	case !*deleteRef && len(args) == 1:
+		name := args[0]
+		target, err := r.SymbolicRef(name)
+		if *quiet && errors.Is(err, repo.ErrNotSymbolicRef) {
+			os.Exit(1)
+		}
+		if errors.Is(err, repo.ErrNotSymbolicRef) {
+			check(fmt.Errorf("ref %s is not a symbolic ref", name))
+		}
+		check(err)
+		if !*noRecurse {
+			target, err = r.ResolveRefName(target)
+			check(err)
+		}
+		if *short {
+			target = repo.ShortRefName(target)
+		}
+		fmt.Println(target)
+	case !*deleteRef && len(args) == 2:
+		name, target := args[0], args[1]
+		if name == "HEAD" && !strings.HasPrefix(target, "refs/") {
+			check(errors.New("refusing to point HEAD outside of refs/"))
+		}
+		if !repo.ValidRefName(target) {
+			check(fmt.Errorf("refusing to set '%s' to invalid ref '%s'", name, target))
+		}
+		check(r.WriteSymbolicRef(name, target, *message))
+	default:
+		usageError(symbolicRefUsage)
+	}
+}
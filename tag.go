@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tamimehsan/gogit/repo"
+)
+
+func runTag(args []string) {
+	tagCmd := flag.NewFlagSet("tag", flag.ExitOnError)
+	list := tagCmd.Bool("l", false, "List tags matching the given patterns")
+	tagCmd.BoolVar(list, "list", false, "List tags matching the given patterns")
+	deleteTags := tagCmd.Bool("d", false, "Delete the given tags")
+	tagCmd.BoolVar(deleteTags, "delete", false, "Delete the given tags")
+	opts := repo.TagOptions{}
+	tagCmd.BoolVar(&opts.Annotated, "a", false, "Create an annotated tag")
+	tagCmd.BoolVar(&opts.Force, "f", false, "Replace an existing tag")
+	tagCmd.BoolVar(&opts.Force, "force", false, "Replace an existing tag")
+	var messages stringsFlag
+	tagCmd.Var(&messages, "m", "The message of an annotated tag, each one a paragraph")
+	messageFile := tagCmd.String("F", "", "Read the message of an annotated tag from a file, - for stdin")
+	sortKey := tagCmd.String("sort", "", "Sort listed tags by refname, version:refname, creatordate, taggerdate or objectname")
+	args = parseArgs(tagCmd, args)
+
+	r := openRepo()
+	switch {
+	case *deleteTags:
+		if len(args) == 0 {
+			usageError("usage: gogit tag -d <tagname>...")
+		}
+		failed := false
+		for _, name := range args {
+			hash, err := r.DeleteTag(name)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "error:", err)
+				failed = true
+				continue
+			}
+			fmt.Printf("Deleted tag '%s' (was %s)\n", name, hash[:7])
+		}
+		if failed {
+			os.Exit(1)
+		}
+	case *list || len(args) == 0:
+		tags, err := r.ListTags(args, *sortKey)
+		check(err)
+		for _, tag := range tags {
+			fmt.Println(tag.Name)
+		}
+	default:
+		if len(args) > 2 {
+			usageError("usage: gogit tag [-a] [-f] [-m <msg> | -F <file>] <tagname> [<commit>]")
+		}
+		opts.Name = args[0]
+		if len(args) == 2 {
+			opts.Target = args[1]
+		}
+		switch {
+		case len(messages) > 0:
+			opts.Message = strings.Join(messages, "\n\n")
+		case *messageFile != "":
+			data, err := readInput(*messageFile)
+			check(err)
+			opts.Message = string(data)
+		case opts.Annotated:
+			check(errors.New("no tag message given, use -m or -F"))
+		}
+		opts.Annotated = opts.Annotated || opts.Message != "" || *messageFile != ""
+		previous, err := r.CreateTag(opts)
+		check(err)
+		if previous != "" {
+			fmt.Printf("Updated tag '%s' (was %s)\n", opts.Name, previous[:7])
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/tamimehsan/gogit/repo"
+)
+
+func runUpdateRef(args []string) {
+	updateRefCmd := flag.NewFlagSet("update-ref", flag.ExitOnError)
+	message := updateRefCmd.String("m", "", "The reason for the update, recorded in the reflog")
+	deleteRef := updateRefCmd.Bool("d", false, "Delete the ref")
+	args = parseArgs(updateRefCmd, args)
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	switch {
+	case *deleteRef && (len(args) < 1 || len(args) > 2):
+		usageError("usage: gogit update-ref [-m <reason>] -d <ref> [<old-value>]")
+	case !*deleteRef && (len(args) < 2 || len(args) > 3):
+		usageError("usage: gogit update-ref [-m <reason>] <ref> <new-value> [<old-value>]")
+	}
+
+	r := openRepo()
+	name := args[0]
+	// like git, refs live under refs/ unless they are pseudo refs like HEAD
+	if !repo.ValidRefName(name) || (!strings.HasPrefix(name, "refs/") && name != strings.ToUpper(name)) {
+		check(fmt.Errorf("refusing to update ref with bad name '%s'", name))
+	}
+	args = args[1:]
+	newValue := repo.ZeroHash
+	if !*deleteRef {
+		newValue = resolveRefValue(r, args[0])
+		args = args[1:]
+	}
+	oldValue := ""
+	if len(args) == 1 {
+		oldValue = resolveRefValue(r, args[0])
+	}
+	check(r.UpdateRef(name, newValue, oldValue, *message))
+}
+
+// resolveRefValue returns the object value names, with the zero hash for
+// an empty value as git update-ref does.
+func resolveRefValue(r *repo.Repository, value string) string {
+	if value == "" || value == repo.ZeroHash {
+		return repo.ZeroHash
+	}
+	object, err := r.ResolveRevision(value)
+	if err != nil {
+		check(fmt.Errorf("%s: not a valid SHA1", value))
+	}
+	return object
+}